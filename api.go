@@ -0,0 +1,435 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Address of the local API when listen is not set
+	defaultAPIListen = "127.0.0.1:8765"
+
+	// Limit for request bodies; requests are a few fields of JSON
+	maxAPIRequestSize = 64 << 10
+)
+
+// APIConfig is the [api] table: a local HTTP server with prices, alerts and
+// control of the running app
+type APIConfig struct {
+	Enabled bool   `toml:"enabled,omitempty"`
+	Listen  string `toml:"listen,omitempty"` // host:port, default "127.0.0.1:8765"
+}
+
+// listenAddr returns the address to serve on, "" when the API is disabled.
+func (c *APIConfig) listenAddr() string {
+	if c == nil || !c.Enabled {
+		return ""
+	}
+	if c.Listen == "" {
+		return defaultAPIListen
+	}
+	return c.Listen
+}
+
+// apiPrice is a cached price in API responses and events
+type apiPrice struct {
+	Pair          string    `json:"pair"`
+	Price         float64   `json:"price"`
+	Formatted     string    `json:"formatted"` // Price as shown in the menu bar
+	ChangePercent float64   `json:"change_percent"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Stale         bool      `json:"stale"`
+}
+
+// apiAlert is an alert in API requests and responses
+type apiAlert struct {
+	Number    int     `json:"number,omitempty"` // 1-based position, usable in DELETE /alerts/{ref}
+	ID        string  `json:"id,omitempty"`
+	Pair      string  `json:"pair"`
+	Target    float64 `json:"target"`
+	Condition string  `json:"condition"`
+	Active    *bool   `json:"active,omitempty"` // Defaults to true in POST /alerts
+	Source    string  `json:"source,omitempty"` // "config" or "shared" (read-only)
+}
+
+var (
+	// Running API server, restarted when [api] changes. The server only runs
+	// in the tray app and the daemon, which set apiAllowed.
+	apiMutex   sync.Mutex
+	apiAllowed bool
+	apiServer  *http.Server
+	apiAddr    string
+
+	// Subscribers of GET /events
+	apiEvents = &eventHub{subscribers: make(map[chan apiEvent]struct{})}
+)
+
+// --- Server ---
+
+// startAPI enables the API for this process and starts it if the config
+// asks for it.
+func startAPI() {
+	apiMutex.Lock()
+	apiAllowed = true
+	apiMutex.Unlock()
+
+	addPriceListener(func(pair string, entry PriceEntry) {
+		apiEvents.publish("price", newAPIPrice(pair, entry))
+	})
+	addAlertListener(countAlert)
+	addAlertListener(func(alert Alert, price float64) {
+		apiEvents.publish("alert", map[string]any{
+			"alert":   newAPIAlert(0, alert),
+			"price":   price,
+			"message": alertMessage(alert, price),
+		})
+	})
+	updateAPIServer()
+}
+
+// updateAPIServer starts, stops or moves the server to match the active
+// config. Called whenever the config is loaded.
+func updateAPIServer() {
+	configMutex.RLock()
+	addr := activeConfig.API.listenAddr()
+	configMutex.RUnlock()
+
+	apiMutex.Lock()
+	defer apiMutex.Unlock()
+	if !apiAllowed || addr == apiAddr {
+		return
+	}
+
+	if apiServer != nil {
+		// Close instead of Shutdown: event streams never finish on their own
+		apiServer.Close()
+		apiServer = nil
+		log.Printf("API server on %s stopped.", apiAddr)
+	}
+	apiAddr = ""
+	if addr == "" {
+		return
+	}
+
+	// apiAddr stays empty on failure, so the next config change tries again
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Printf("Error starting API server: %v", err)
+		showErrorAlert("API Error", fmt.Sprintf("Could not start the local API on %s.\nError: %v", addr, err))
+		return
+	}
+	server := &http.Server{Handler: newAPIHandler(), ReadHeaderTimeout: 10 * time.Second}
+	apiServer, apiAddr = server, addr
+	log.Printf("API server listening on http://%s", ln.Addr())
+	go func() {
+		if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("API server error: %v", err)
+		}
+	}()
+}
+
+// newAPIHandler routes the API endpoints.
+func newAPIHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /prices", handleAPIPrices)
+	mux.HandleFunc("GET /pairs/{symbol}", handleAPIPair)
+	mux.HandleFunc("GET /alerts", handleAPIAlerts)
+	mux.HandleFunc("POST /alerts", handleAPIAddAlert)
+	mux.HandleFunc("DELETE /alerts/{ref}", handleAPIRemoveAlert)
+	mux.HandleFunc("POST /pin", handleAPIPin)
+	mux.HandleFunc("POST /select", handleAPISelect)
+	mux.HandleFunc("GET /events", handleAPIEvents)
+	mux.Handle("GET /metrics", metricsHandler())
+	return checkAPIRequest(mux)
+}
+
+// checkAPIRequest keeps web pages away from the API, which has no
+// authentication: browsers send an Origin header with cross-site requests,
+// a page reaching it by DNS rebinding uses its own host name, and only a
+// JSON body cannot be sent by a plain HTML form.
+func checkAPIRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Header.Get("Origin") != "":
+			writeAPIError(w, http.StatusForbidden, errors.New("requests from web pages are not allowed"))
+		case !apiHostAllowed(r.Host):
+			writeAPIError(w, http.StatusForbidden, fmt.Errorf("host %q is not allowed; use localhost or an IP address", r.Host))
+		case r.Method == http.MethodPost && !isJSONContentType(r.Header.Get("Content-Type")):
+			writeAPIError(w, http.StatusUnsupportedMediaType, errors.New("the request body must be sent as Content-Type: application/json"))
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// apiHostAllowed reports whether the Host header names localhost or is an IP
+// address, which DNS rebinding cannot produce.
+func apiHostAllowed(hostport string) bool {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = hostport
+	}
+	host = strings.ToLower(strings.TrimSuffix(strings.Trim(host, "[]"), "."))
+	return host == "localhost" || strings.HasSuffix(host, ".localhost") || net.ParseIP(host) != nil
+}
+
+func isJSONContentType(value string) bool {
+	mediaType, _, err := mime.ParseMediaType(value)
+	return err == nil && mediaType == "application/json"
+}
+
+// --- Handlers ---
+
+// handleAPIPrices returns the cached price of each configured pair that has one.
+func handleAPIPrices(w http.ResponseWriter, r *http.Request) {
+	prices := []apiPrice{}
+	for _, pair := range commandPairs(nil) {
+		if entry, ok := getCachedPrice(pair); ok {
+			prices = append(prices, newAPIPrice(pair, entry))
+		}
+	}
+	writeJSON(w, http.StatusOK, prices)
+}
+
+// handleAPIPair returns the cached price of one pair and its alerts.
+func handleAPIPair(w http.ResponseWriter, r *http.Request) {
+	pair := strings.ToUpper(r.PathValue("symbol"))
+	entry, ok := getCachedPrice(pair)
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, fmt.Errorf("no price for %s", pair))
+		return
+	}
+
+	alerts := []apiAlert{}
+	for i, a := range activeAlerts() {
+		if a.Pair == pair {
+			alerts = append(alerts, newAPIAlert(i+1, a))
+		}
+	}
+	writeJSON(w, http.StatusOK, struct {
+		apiPrice
+		Pinned bool       `json:"pinned"`
+		Alerts []apiAlert `json:"alerts"`
+	}{newAPIPrice(pair, entry), isPinned(pair), alerts})
+}
+
+func handleAPIAlerts(w http.ResponseWriter, r *http.Request) {
+	alerts := []apiAlert{}
+	for i, a := range activeAlerts() {
+		alerts = append(alerts, newAPIAlert(i+1, a))
+	}
+	writeJSON(w, http.StatusOK, alerts)
+}
+
+// handleAPIAddAlert adds the alert in the body to the config file.
+func handleAPIAddAlert(w http.ResponseWriter, r *http.Request) {
+	var req apiAlert
+	if err := readJSON(w, r, &req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+	alert := Alert{ID: req.ID, Pair: strings.ToUpper(req.Pair), Target: req.Target, Condition: strings.ToLower(req.Condition), Active: req.Active == nil || *req.Active}
+	if err := checkNewAlert(alert); err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := addAlert(alert); err != nil {
+		writeAPIError(w, http.StatusConflict, err)
+		return
+	}
+	log.Printf("Alert added from the API: %s %s %s", alert.Pair, alert.Condition, formatPairPrice(alert.Pair, alert.Target))
+	loadAndSetConfig()
+	writeJSON(w, http.StatusCreated, newAPIAlert(0, alert))
+}
+
+// handleAPIRemoveAlert removes an alert by id or by number.
+func handleAPIRemoveAlert(w http.ResponseWriter, r *http.Request) {
+	alert, err := findAlert(activeAlerts(), r.PathValue("ref"))
+	if err != nil {
+		writeAPIError(w, http.StatusNotFound, err)
+		return
+	}
+	if alert.Shared {
+		writeAPIError(w, http.StatusForbidden, errors.New("the alert comes from the shared watchlist and is read-only"))
+		return
+	}
+	if err := removeAlert(func(a Alert) bool { return a == alert }); err != nil {
+		writeAPIError(w, http.StatusConflict, err)
+		return
+	}
+	log.Printf("Alert removed from the API: %s %s %s", alert.Pair, alert.Condition, formatPairPrice(alert.Pair, alert.Target))
+	loadAndSetConfig()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAPIPin pins {"pair": "..."} to the menu bar; an empty pair unpins.
+func handleAPIPin(w http.ResponseWriter, r *http.Request) {
+	pair, ok := readAPIPair(w, r, true)
+	if !ok {
+		return
+	}
+	if err := setPinnedPair(pair); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"pinned": pair})
+}
+
+// handleAPISelect displays {"pair": "..."} until the next rotation.
+func handleAPISelect(w http.ResponseWriter, r *http.Request) {
+	pair, ok := readAPIPair(w, r, false)
+	if !ok {
+		return
+	}
+	selectPair(pair)
+	writeJSON(w, http.StatusOK, map[string]string{"selected": pair})
+}
+
+// handleAPIEvents streams price ticks and triggered alerts as Server-Sent
+// Events named "price" and "alert".
+func handleAPIEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeAPIError(w, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+	events := apiEvents.subscribe()
+	defer apiEvents.unsubscribe(events)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(30 * time.Second)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case e := <-events:
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Name, e.Data)
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// --- Helpers ---
+
+func newAPIPrice(pair string, entry PriceEntry) apiPrice {
+	return apiPrice{
+		Pair:          pair,
+		Price:         entry.Price,
+		Formatted:     formatPairPrice(pair, entry.Price),
+		ChangePercent: entry.ChangePercent,
+		UpdatedAt:     entry.UpdatedAt,
+		Stale:         isStale(entry),
+	}
+}
+
+func newAPIAlert(number int, a Alert) apiAlert {
+	active := a.Active
+	return apiAlert{Number: number, ID: a.ID, Pair: a.Pair, Target: a.Target, Condition: a.Condition, Active: &active, Source: alertSource(a)}
+}
+
+// readAPIPair reads {"pair": "..."} and checks that the pair is configured.
+// An empty pair is accepted when allowEmpty is set.
+func readAPIPair(w http.ResponseWriter, r *http.Request, allowEmpty bool) (string, bool) {
+	var req struct {
+		Pair string `json:"pair"`
+	}
+	if err := readJSON(w, r, &req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return "", false
+	}
+	switch {
+	case req.Pair == "" && allowEmpty:
+		return "", true
+	case req.Pair == "":
+		writeAPIError(w, http.StatusBadRequest, errors.New("missing pair"))
+		return "", false
+	}
+	pair, err := configuredPair(req.Pair)
+	if err != nil {
+		writeAPIError(w, http.StatusNotFound, err)
+		return "", false
+	}
+	return pair, true
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIRequestSize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error writing API response: %v", err)
+	}
+}
+
+func writeAPIError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// --- Events ---
+
+// apiEvent is a Server-Sent Event with JSON data
+type apiEvent struct {
+	Name string
+	Data []byte
+}
+
+// eventHub fans events out to the connected event streams
+type eventHub struct {
+	mu          sync.Mutex
+	subscribers map[chan apiEvent]struct{}
+}
+
+func (h *eventHub) subscribe() chan apiEvent {
+	ch := make(chan apiEvent, 64)
+	h.mu.Lock()
+	h.subscribers[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch
+}
+
+func (h *eventHub) unsubscribe(ch chan apiEvent) {
+	h.mu.Lock()
+	delete(h.subscribers, ch)
+	h.mu.Unlock()
+}
+
+// publish sends an event to every subscriber, dropping it for subscribers
+// that are not keeping up rather than blocking the price loop.
+func (h *eventHub) publish(name string, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("Error encoding %s event: %v", name, err)
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers {
+		select {
+		case ch <- apiEvent{Name: name, Data: data}:
+		default:
+		}
+	}
+}
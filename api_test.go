@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setAPITestState installs a config and price cache for the handlers.
+func setAPITestState(t *testing.T) {
+	configMutex.Lock()
+	old := activeConfig
+	activeConfig = &Config{
+		Pairs:  []string{"BTCUSDC", "ETHUSDC"},
+		Alerts: []Alert{{ID: "btc-100k", Pair: "BTCUSDC", Target: 100000, Condition: "above", Active: true}},
+	}
+	configMutex.Unlock()
+
+	latestPricesMutex.Lock()
+	latestPrices["BTCUSDC"] = PriceEntry{Price: 92430.5, ChangePercent: 1.5, UpdatedAt: time.Now()}
+	latestPricesMutex.Unlock()
+
+	t.Cleanup(func() {
+		configMutex.Lock()
+		activeConfig = old
+		configMutex.Unlock()
+		latestPricesMutex.Lock()
+		delete(latestPrices, "BTCUSDC")
+		latestPricesMutex.Unlock()
+	})
+}
+
+func TestAPIPrices(t *testing.T) {
+	setAPITestState(t)
+	server := httptest.NewServer(newAPIHandler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/prices")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var prices []apiPrice
+	if err := json.NewDecoder(resp.Body).Decode(&prices); err != nil {
+		t.Fatal(err)
+	}
+	// ETHUSDC has no price yet and is left out
+	if len(prices) != 1 || prices[0].Pair != "BTCUSDC" || prices[0].Price != 92430.5 || prices[0].Stale {
+		t.Errorf("prices = %+v", prices)
+	}
+}
+
+func TestAPIPair(t *testing.T) {
+	setAPITestState(t)
+	server := httptest.NewServer(newAPIHandler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/pairs/btcusdc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var pair struct {
+		apiPrice
+		Alerts []apiAlert `json:"alerts"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&pair); err != nil {
+		t.Fatal(err)
+	}
+	if pair.Pair != "BTCUSDC" || len(pair.Alerts) != 1 || pair.Alerts[0].ID != "btc-100k" || pair.Alerts[0].Number != 1 {
+		t.Errorf("pair = %+v", pair)
+	}
+
+	resp, err = http.Get(server.URL + "/pairs/ETHUSDC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("pair without a price: status %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestAPIRejectsBadRequests(t *testing.T) {
+	setAPITestState(t)
+	server := httptest.NewServer(newAPIHandler())
+	defer server.Close()
+
+	tests := []struct {
+		method, path, body string
+		status             int
+	}{
+		{"POST", "/select", `{"pair": "SOLUSDC"}`, http.StatusNotFound},
+		{"POST", "/select", `{"pair": ""}`, http.StatusBadRequest},
+		{"POST", "/pin", `{"symbol": "BTCUSDC"}`, http.StatusBadRequest},
+		{"POST", "/alerts", `{"pair": "BTCUSDC", "target": 1, "condition": "abve"}`, http.StatusBadRequest},
+		{"POST", "/alerts", `{"id": "btc-100k", "pair": "BTCUSDC", "target": 1, "condition": "above"}`, http.StatusBadRequest},
+		{"DELETE", "/alerts/9", "", http.StatusNotFound},
+		{"GET", "/select", "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, server.URL+tt.path, strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s %s %s: status %d, want %d", tt.method, tt.path, tt.body, resp.StatusCode, tt.status)
+		}
+	}
+}
+
+// Web pages cannot use the API: not cross-site, not by DNS rebinding and not
+// with a form post.
+func TestAPIRejectsWebPages(t *testing.T) {
+	setAPITestState(t)
+	server := httptest.NewServer(newAPIHandler())
+	defer server.Close()
+
+	tests := []struct {
+		name, method, path string
+		header             map[string]string
+		status             int
+	}{
+		{"local script", "GET", "/prices", nil, http.StatusOK},
+		{"localhost", "GET", "/prices", map[string]string{"Host": "localhost:8765"}, http.StatusOK},
+		{"IPv6", "GET", "/prices", map[string]string{"Host": "[::1]:8765"}, http.StatusOK},
+		{"cross-site", "GET", "/prices", map[string]string{"Origin": "https://example.com"}, http.StatusForbidden},
+		{"same origin", "POST", "/select", map[string]string{"Origin": "http://127.0.0.1:8765", "Content-Type": "application/json"}, http.StatusForbidden},
+		{"DNS rebinding", "GET", "/alerts", map[string]string{"Host": "attacker.example:8765"}, http.StatusForbidden},
+		{"form post", "POST", "/select", map[string]string{"Content-Type": "text/plain"}, http.StatusUnsupportedMediaType},
+		{"no content type", "POST", "/select", nil, http.StatusUnsupportedMediaType},
+		{"JSON with charset", "POST", "/select", map[string]string{"Content-Type": "application/json; charset=utf-8"}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, server.URL+tt.path, strings.NewReader(`{"pair": "BTCUSDC"}`))
+		for k, v := range tt.header {
+			if k == "Host" {
+				req.Host = v
+			} else {
+				req.Header.Set(k, v)
+			}
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, resp.StatusCode, tt.status)
+		}
+	}
+}
+
+func TestAPIEvents(t *testing.T) {
+	setAPITestState(t)
+	server := httptest.NewServer(newAPIHandler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("content type %q", ct)
+	}
+
+	// The headers are sent after subscribing, so the event is not missed
+	apiEvents.publish("price", newAPIPrice("BTCUSDC", PriceEntry{Price: 93000, UpdatedAt: time.Now()}))
+
+	lines := bufio.NewScanner(resp.Body)
+	var got []string
+	for len(got) < 2 && lines.Scan() {
+		if line := lines.Text(); line != "" {
+			got = append(got, line)
+		}
+	}
+	if len(got) != 2 || got[0] != "event: price" || !strings.Contains(got[1], `"price":93000`) {
+		t.Errorf("event = %q", got)
+	}
+}
+
+// setAPITestConfigFile loads doc from a temporary config file, which the
+// write endpoints change, and returns its path.
+func setAPITestConfigFile(t *testing.T, doc string) string {
+	setAPITestState(t) // Restores the config
+	path := filepath.Join(writeTestConfigFiles(t, map[string]string{"config.toml": doc}), "config.toml")
+	setTestConfigFile(t, path)
+	loadAndSetConfig()
+	return path
+}
+
+// apiJSON sends body as JSON and returns the status and the decoded response.
+func apiJSON(t *testing.T, method, url, body string) (int, map[string]any) {
+	req, _ := http.NewRequest(method, url, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var v map[string]any
+	json.NewDecoder(resp.Body).Decode(&v)
+	return resp.StatusCode, v
+}
+
+const testAPIConfig = `version = 2
+pairs = ["BTCUSDC", "ETHUSDC"]
+
+[[alerts]]
+id = "btc-100k"
+pair = "BTCUSDC"
+target = 100000.0
+condition = "above"
+active = true
+`
+
+func TestAPIAlertWrites(t *testing.T) {
+	path := setAPITestConfigFile(t, testAPIConfig)
+	server := httptest.NewServer(newAPIHandler())
+	defer server.Close()
+
+	status, body := apiJSON(t, "POST", server.URL+"/alerts", `{"id": "eth-2k", "pair": "ethusdc", "target": 2000, "condition": "Below"}`)
+	if status != http.StatusCreated || body["pair"] != "ETHUSDC" || body["condition"] != "below" || body["active"] != true {
+		t.Fatalf("POST /alerts: %d %v", status, body)
+	}
+	data, _ := os.ReadFile(path)
+	if want := testAPIConfig + "\n[[alerts]]\nid = \"eth-2k\"\npair = \"ETHUSDC\"\ntarget = 2000.0\ncondition = \"below\"\nactive = true\n"; string(data) != want {
+		t.Errorf("config after adding:\n%s", data)
+	}
+	if alerts := activeAlerts(); len(alerts) != 2 || alerts[1].ID != "eth-2k" {
+		t.Errorf("loaded alerts = %+v", alerts)
+	}
+
+	if status, body := apiJSON(t, "DELETE", server.URL+"/alerts/btc-100k", ""); status != http.StatusNoContent {
+		t.Fatalf("DELETE /alerts/btc-100k: %d %v", status, body)
+	}
+	if data, _ := os.ReadFile(path); strings.Contains(string(data), "btc-100k") || !strings.Contains(string(data), "eth-2k") {
+		t.Errorf("config after removing:\n%s", data)
+	}
+	if alerts := activeAlerts(); len(alerts) != 1 || alerts[0].ID != "eth-2k" {
+		t.Errorf("loaded alerts = %+v", alerts)
+	}
+}
+
+func TestAPIPinWrites(t *testing.T) {
+	path := setAPITestConfigFile(t, testAPIConfig)
+	server := httptest.NewServer(newAPIHandler())
+	defer server.Close()
+	displayed := getPair()
+	t.Cleanup(func() { setPair(displayed) })
+
+	status, body := apiJSON(t, "POST", server.URL+"/pin", `{"pair": "ethusdc"}`)
+	if status != http.StatusOK || body["pinned"] != "ETHUSDC" {
+		t.Fatalf("POST /pin: %d %v", status, body)
+	}
+	if data, _ := os.ReadFile(path); !strings.Contains(string(data), "pinned_pair = \"ETHUSDC\"\n") {
+		t.Errorf("config after pinning:\n%s", data)
+	}
+	if !isPinned("ETHUSDC") || getPair() != "ETHUSDC" {
+		t.Errorf("pinned %v, displayed %s; want ETHUSDC", isPinned("ETHUSDC"), getPair())
+	}
+
+	if status, body := apiJSON(t, "POST", server.URL+"/pin", `{"pair": ""}`); status != http.StatusOK || body["pinned"] != "" {
+		t.Fatalf("unpin: %d %v", status, body)
+	}
+	if data, _ := os.ReadFile(path); string(data) != testAPIConfig {
+		t.Errorf("config after unpinning:\n%s", data)
+	}
+	if isPinned("ETHUSDC") {
+		t.Error("still pinned")
+	}
+
+	// A failed write leaves the pin alone
+	os.Remove(path)
+	os.Mkdir(path, 0755)
+	if status, _ := apiJSON(t, "POST", server.URL+"/pin", `{"pair": "BTCUSDC"}`); status != http.StatusInternalServerError || isPinned("BTCUSDC") {
+		t.Errorf("failed write: status %d, pinned %v", status, isPinned("BTCUSDC"))
+	}
+}
+
+// A server that could not start is retried on the next config change.
+func TestUpdateAPIServerRetries(t *testing.T) {
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := taken.Addr().String()
+	configMutex.Lock()
+	old := activeConfig
+	activeConfig = &Config{API: &APIConfig{Enabled: true, Listen: addr}}
+	configMutex.Unlock()
+	apiMutex.Lock()
+	apiAllowed = true
+	apiMutex.Unlock()
+	t.Cleanup(func() {
+		configMutex.Lock()
+		activeConfig = old
+		configMutex.Unlock()
+		apiMutex.Lock()
+		if apiServer != nil {
+			apiServer.Close()
+		}
+		apiAllowed, apiServer, apiAddr = false, nil, ""
+		apiMutex.Unlock()
+	})
+
+	updateAPIServer()
+	apiMutex.Lock()
+	running, listening := apiServer != nil, apiAddr
+	apiMutex.Unlock()
+	if running || listening != "" {
+		t.Fatalf("address in use: server %v on %q", running, listening)
+	}
+
+	taken.Close()
+	updateAPIServer()
+	apiMutex.Lock()
+	running, listening = apiServer != nil, apiAddr
+	apiMutex.Unlock()
+	if !running || listening != addr {
+		t.Errorf("after the address was freed: server %v on %q, want %s", running, listening, addr)
+	}
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	binance_connector "github.com/binance/binance-connector-go"
+	"github.com/binance/binance-connector-go/handlers"
+)
+
+const (
+	// Defaults used when the [backfill] section (or one of its keys) is missing
+	defaultBackfillInterval = "1h"
+	defaultBackfillDays     = 30
+
+	// Binance returns at most 1000 klines per request
+	klinesPageLimit = 1000
+
+	// Pause between kline requests, keeps us well below the request weight limit
+	backfillRequestPause = 250 * time.Millisecond
+
+	// Back off when the used request weight in the current minute gets above this
+	// (Binance allows 6000 per minute per IP)
+	backfillWeightThreshold = 4000
+)
+
+// BackfillConfig controls the historical klines backfill job
+type BackfillConfig struct {
+	Enabled  *bool  `toml:"enabled,omitempty"`  // Default true
+	Interval string `toml:"interval,omitempty"` // Binance kline interval, e.g. "1h"
+	Days     int    `toml:"days,omitempty"`     // How far back to fetch when start is empty
+	Start    string `toml:"start,omitempty"`    // "2006-01-02", overrides days
+	End      string `toml:"end,omitempty"`      // "2006-01-02", default now
+}
+
+// Kline intervals supported by Binance with their nominal duration
+var klineIntervals = map[string]time.Duration{
+	"1m":  time.Minute,
+	"3m":  3 * time.Minute,
+	"5m":  5 * time.Minute,
+	"15m": 15 * time.Minute,
+	"30m": 30 * time.Minute,
+	"1h":  time.Hour,
+	"2h":  2 * time.Hour,
+	"4h":  4 * time.Hour,
+	"6h":  6 * time.Hour,
+	"8h":  8 * time.Hour,
+	"12h": 12 * time.Hour,
+	"1d":  24 * time.Hour,
+	"3d":  3 * 24 * time.Hour,
+	"1w":  7 * 24 * time.Hour,
+	"1M":  30 * 24 * time.Hour,
+}
+
+var (
+	// Channel to trigger a backfill run (e.g. after pairs were added to the config)
+	backfillChan = make(chan struct{}, 1)
+)
+
+// backfillPlan is the resolved backfill configuration
+type backfillPlan struct {
+	Enabled  bool
+	Interval string
+	Start    time.Time
+	End      time.Time // Zero means "up to now"
+	Pairs    []string
+}
+
+// --- Backfill Job ---
+
+// runBackfill backfills history at startup, whenever triggerBackfill is called
+// and then once per interval so the store keeps up with new candles.
+func runBackfill() {
+	log.Println("History backfill goroutine started.")
+	client := newRateLimitedClient()
+
+	for {
+		wait := time.Hour
+		plan, err := getBackfillPlan()
+		if err != nil {
+			log.Printf("Backfill disabled: %v", err)
+		} else if plan.Enabled {
+			backfillAll(client, plan)
+			wait = clampDuration(klineIntervals[plan.Interval], time.Minute, time.Hour)
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-backfillChan:
+			log.Println("Backfill triggered.")
+		}
+	}
+}
+
+// triggerBackfill requests a backfill run without blocking.
+func triggerBackfill() {
+	select {
+	case backfillChan <- struct{}{}:
+	default:
+		// Run already pending
+	}
+}
+
+func getBackfillPlan() (backfillPlan, error) {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	plan := backfillPlan{Enabled: true, Interval: defaultBackfillInterval}
+	if activeConfig == nil {
+		plan.Enabled = false
+		return plan, nil
+	}
+	plan.Pairs = append(plan.Pairs, activeConfig.Pairs...)
+
+	days := defaultBackfillDays
+	bf := activeConfig.Backfill
+	if bf != nil {
+		if bf.Enabled != nil {
+			plan.Enabled = *bf.Enabled
+		}
+		if bf.Interval != "" {
+			plan.Interval = bf.Interval
+		}
+		if bf.Days > 0 {
+			days = bf.Days
+		}
+	}
+
+	if _, ok := klineIntervals[plan.Interval]; !ok {
+		return plan, fmt.Errorf("unsupported interval %q", plan.Interval)
+	}
+
+	plan.Start = time.Now().AddDate(0, 0, -days)
+	if bf != nil && bf.Start != "" {
+		start, err := time.ParseInLocation("2006-01-02", bf.Start, time.Local)
+		if err != nil {
+			return plan, fmt.Errorf("invalid start date %q: %w", bf.Start, err)
+		}
+		plan.Start = start
+	}
+	if bf != nil && bf.End != "" {
+		end, err := time.ParseInLocation("2006-01-02", bf.End, time.Local)
+		if err != nil {
+			return plan, fmt.Errorf("invalid end date %q: %w", bf.End, err)
+		}
+		plan.End = end
+	}
+	return plan, nil
+}
+
+func backfillAll(client *binance_connector.Client, plan backfillPlan) {
+	for _, pair := range plan.Pairs {
+		n, err := backfillPair(client, pair, plan.Interval, plan.Start, plan.End)
+		if err != nil {
+			log.Printf("Error backfilling %s %s history: %v", pair, plan.Interval, err)
+			continue
+		}
+		if n > 0 {
+			log.Printf("Backfilled %d %s candles for %s", n, plan.Interval, pair)
+		}
+	}
+}
+
+// backfillPair fetches closed klines for pair from start (or from the last
+// stored candle, if newer) up to end and appends them to the history store.
+// Because every page is stored as soon as it arrives, an interrupted run
+// resumes where it stopped.
+func backfillPair(client *binance_connector.Client, pair, interval string, start, end time.Time) (int, error) {
+	last, err := lastHistoryTime(pair, interval)
+	if err != nil {
+		return 0, err
+	}
+	if !last.IsZero() && !last.Before(start) {
+		start = last.Add(time.Millisecond)
+	}
+
+	total := 0
+	for {
+		now := time.Now()
+		if !end.IsZero() && !start.Before(end) {
+			return total, nil
+		}
+		if !start.Before(now) {
+			return total, nil
+		}
+
+		candles, nextStart, err := fetchKlines(client, pair, interval, start, end)
+		if err != nil {
+			var apiErr *handlers.APIError
+			if errors.As(err, &apiErr) && waitForRateLimit() {
+				continue
+			}
+			return total, err
+		}
+
+		n, err := appendHistory(pair, interval, candles)
+		total += n
+		if err != nil {
+			return total, err
+		}
+
+		// No more closed candles available
+		if nextStart.IsZero() || !nextStart.After(start) {
+			return total, nil
+		}
+		start = nextStart
+
+		waitForRateLimit()
+		time.Sleep(backfillRequestPause)
+	}
+}
+
+// fetchKlines fetches one page of klines starting at start. Only closed candles
+// are returned; nextStart is where the following page begins (zero when the
+// page was the last one).
+func fetchKlines(client *binance_connector.Client, pair, interval string, start, end time.Time) ([]Candle, time.Time, error) {
+	svc := client.NewKlinesService().
+		Symbol(pair).
+		Interval(interval).
+		Limit(klinesPageLimit).
+		StartTime(uint64(start.UnixMilli()))
+	if !end.IsZero() {
+		svc = svc.EndTime(uint64(end.UnixMilli()))
+	}
+
+	res, err := svc.Do(context.Background())
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	now := time.Now()
+	var candles []Candle
+	var nextStart time.Time
+	for _, k := range res {
+		closeTime := time.UnixMilli(int64(k.CloseTime))
+		if closeTime.After(now) {
+			// Candle still open, fetch it on a later run
+			break
+		}
+		c, err := klineToCandle(k)
+		if err != nil {
+			return candles, time.Time{}, fmt.Errorf("invalid kline for %s: %w", pair, err)
+		}
+		candles = append(candles, c)
+		nextStart = closeTime.Add(time.Millisecond)
+	}
+
+	if len(res) < klinesPageLimit || len(candles) < len(res) {
+		nextStart = time.Time{}
+	}
+	return candles, nextStart, nil
+}
+
+func klineToCandle(k *binance_connector.KlinesResponse) (Candle, error) {
+	var values [5]float64
+	for i, s := range []string{k.Open, k.High, k.Low, k.Close, k.Volume} {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return Candle{}, err
+		}
+		values[i] = v
+	}
+	return Candle{
+		Time:   time.UnixMilli(int64(k.OpenTime)),
+		Open:   values[0],
+		High:   values[1],
+		Low:    values[2],
+		Close:  values[3],
+		Volume: values[4],
+	}, nil
+}
+
+// --- Rate Limiting ---
+
+var (
+	// Request weight state reported by Binance in the response headers
+	rateLimitMutex  sync.Mutex
+	usedWeight      int
+	usedWeightAt    time.Time
+	rateLimitedTill time.Time
+)
+
+// rateLimitTransport records the X-MBX-USED-WEIGHT-1M and Retry-After headers
+// of every response so the backfill job can slow down before being banned.
+type rateLimitTransport struct {
+	next http.RoundTripper
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		return res, err
+	}
+
+	rateLimitMutex.Lock()
+	defer rateLimitMutex.Unlock()
+
+	if w, err := strconv.Atoi(res.Header.Get("X-Mbx-Used-Weight-1m")); err == nil {
+		usedWeight = w
+		usedWeightAt = time.Now()
+	}
+	// 429: too many requests, 418: IP banned after ignoring 429s
+	if res.StatusCode == http.StatusTooManyRequests || res.StatusCode == http.StatusTeapot {
+		retryAfter := time.Minute
+		if secs, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil {
+			retryAfter = time.Duration(secs) * time.Second
+		}
+		rateLimitedTill = time.Now().Add(retryAfter)
+		log.Printf("Binance rate limit hit (%s), backing off for %s", res.Status, retryAfter)
+	}
+	return res, nil
+}
+
+func newRateLimitedClient() *binance_connector.Client {
+	client := binance_connector.NewClient("", "", binanceBaseURL)
+	client.HTTPClient = &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &rateLimitTransport{next: http.DefaultTransport},
+	}
+	return client
+}
+
+// waitForRateLimit sleeps while Binance asked us to back off or the used
+// weight is close to the limit. It reports whether it waited.
+func waitForRateLimit() bool {
+	rateLimitMutex.Lock()
+	var wait time.Duration
+	if until := time.Until(rateLimitedTill); until > 0 {
+		wait = until
+	} else if usedWeight >= backfillWeightThreshold && time.Since(usedWeightAt) < time.Minute {
+		// Weight is counted per wall clock minute
+		wait = usedWeightAt.Truncate(time.Minute).Add(time.Minute).Sub(time.Now())
+	}
+	rateLimitMutex.Unlock()
+
+	if wait <= 0 {
+		return false
+	}
+	log.Printf("Backfill paused for %s to respect Binance rate limits", wait.Round(time.Second))
+	time.Sleep(wait)
+	return true
+}
+
+func clampDuration(d, min, max time.Duration) time.Duration {
+	if d < min {
+		return min
+	}
+	if d > max {
+		return max
+	}
+	return d
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	binance_connector "github.com/binance/binance-connector-go"
+)
+
+// klinesServer serves one-minute klines from testHistoryStart up to the
+// requested end time. fail is called with the 1-based request number and may
+// write an error response instead.
+type klinesServer struct {
+	mu     sync.Mutex
+	starts []int64 // startTime of each request
+	fail   func(w http.ResponseWriter, request int) bool
+}
+
+func (s *klinesServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	start, _ := strconv.ParseInt(q.Get("startTime"), 10, 64)
+	end, _ := strconv.ParseInt(q.Get("endTime"), 10, 64)
+	limit, _ := strconv.Atoi(q.Get("limit"))
+
+	s.mu.Lock()
+	s.starts = append(s.starts, start)
+	request := len(s.starts)
+	s.mu.Unlock()
+	if s.fail != nil && s.fail(w, request) {
+		return
+	}
+
+	const minute = int64(time.Minute / time.Millisecond)
+	base := testHistoryStart.UnixMilli()
+	open := max(base, base+(start-base+minute-1)/minute*minute)
+	klines := [][]any{}
+	for ; len(klines) < limit && open <= end; open += minute {
+		p := strconv.FormatInt(100+(open-base)/minute, 10)
+		klines = append(klines, []any{open, p, p, p, p, "1", open + minute - 1, "1", 1, "1", "1", "0"})
+	}
+	json.NewEncoder(w).Encode(klines)
+}
+
+func (s *klinesServer) requestStarts() []int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]int64(nil), s.starts...)
+}
+
+// newTestBackfillClient returns a client for server that records rate limits
+// like the one of the backfill job.
+func newTestBackfillClient(server *httptest.Server) *binance_connector.Client {
+	client := binance_connector.NewClient("", "", server.URL)
+	client.HTTPClient = &http.Client{Transport: &rateLimitTransport{next: http.DefaultTransport}}
+	return client
+}
+
+func resetRateLimit(t *testing.T) {
+	t.Cleanup(func() {
+		rateLimitMutex.Lock()
+		usedWeight, usedWeightAt, rateLimitedTill = 0, time.Time{}, time.Time{}
+		rateLimitMutex.Unlock()
+	})
+}
+
+// A run interrupted after the first page resumes after the last stored
+// candle instead of starting over.
+func TestBackfillResumesAfterInterrupt(t *testing.T) {
+	setTestCacheDir(t)
+	resetRateLimit(t)
+	klines := &klinesServer{fail: func(w http.ResponseWriter, request int) bool {
+		if request != 2 {
+			return false
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"code": -1000, "msg": "An unknown error occurred."}`))
+		return true
+	}}
+	server := httptest.NewServer(klines)
+	defer server.Close()
+	client := newTestBackfillClient(server)
+	end := testHistoryStart.Add(2500 * time.Minute)
+
+	n, err := backfillPair(client, "BTCUSDC", "1m", testHistoryStart, end)
+	if err == nil || n != klinesPageLimit {
+		t.Fatalf("interrupted run: %d candles, err %v; want one page and an error", n, err)
+	}
+
+	n, err = backfillPair(client, "BTCUSDC", "1m", testHistoryStart, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2501-klinesPageLimit {
+		t.Errorf("resumed run stored %d candles, want %d", n, 2501-klinesPageLimit)
+	}
+	resumedAt := klines.requestStarts()[2]
+	if want := testHistoryStart.Add((klinesPageLimit-1)*time.Minute).UnixMilli() + 1; resumedAt != want {
+		t.Errorf("resumed at %d, want %d (after the last stored candle)", resumedAt, want)
+	}
+
+	candles, err := loadHistory("BTCUSDC", "1m", time.Time{}, time.Time{})
+	if err != nil || len(candles) != 2501 {
+		t.Fatalf("stored %d candles, %v; want 2501", len(candles), err)
+	}
+	for i, c := range candles {
+		if want := testHistoryStart.Add(time.Duration(i) * time.Minute); !c.Time.Equal(want) {
+			t.Fatalf("candle %d at %v, want %v", i, c.Time, want)
+		}
+	}
+}
+
+// A 429 response with Retry-After pauses the backfill, which then retries
+// the same page.
+func TestBackfillWaitsForRateLimit(t *testing.T) {
+	setTestCacheDir(t)
+	resetRateLimit(t)
+	klines := &klinesServer{fail: func(w http.ResponseWriter, request int) bool {
+		if request != 1 {
+			return false
+		}
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"code": -1003, "msg": "Too many requests."}`))
+		return true
+	}}
+	server := httptest.NewServer(klines)
+	defer server.Close()
+
+	began := time.Now()
+	n, err := backfillPair(newTestBackfillClient(server), "BTCUSDC", "1m", testHistoryStart, testHistoryStart.Add(10*time.Minute))
+	if err != nil || n != 11 {
+		t.Fatalf("backfill: %d candles, %v", n, err)
+	}
+	if waited := time.Since(began); waited < time.Second {
+		t.Errorf("retried after %s, want the 1s Retry-After", waited)
+	}
+	if starts := klines.requestStarts(); len(starts) != 2 || starts[0] != starts[1] {
+		t.Errorf("request start times %v, want the same page twice", starts)
+	}
+}
+
+func TestWaitForRateLimitWeight(t *testing.T) {
+	resetRateLimit(t)
+	rateLimitMutex.Lock()
+	usedWeight, usedWeightAt = backfillWeightThreshold-1, time.Now()
+	rateLimitMutex.Unlock()
+	if waitForRateLimit() {
+		t.Error("waited below the weight threshold")
+	}
+
+	// Weight reported in a past minute no longer counts
+	rateLimitMutex.Lock()
+	usedWeight, usedWeightAt = backfillWeightThreshold, time.Now().Add(-2*time.Minute)
+	rateLimitMutex.Unlock()
+	if waitForRateLimit() {
+		t.Error("waited for the weight of a past minute")
+	}
+}
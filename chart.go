@@ -0,0 +1,589 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	defaultChartType  = "line"
+	defaultChartRange = "7d"
+
+	// Size of exported charts in pixels
+	chartWidth  = 960
+	chartHeight = 540
+
+	// Charts rendered only to be opened are deleted after this long; the
+	// viewer has loaded them by then
+	tempChartMaxAge = time.Hour
+)
+
+// ChartConfig controls the locally rendered charts
+type ChartConfig struct {
+	Type           string `toml:"type,omitempty"`            // "line" (default) or "candles"
+	Interval       string `toml:"interval,omitempty"`        // Kline interval, defaults to the backfill interval
+	Range          string `toml:"range,omitempty"`           // e.g. "24h", "7d", "4w" (default "7d")
+	MovingAverages []int  `toml:"moving_averages,omitempty"` // Simple moving average periods, e.g. [20, 50]
+	HideAlerts     bool   `toml:"hide_alerts,omitempty"`     // Do not draw alert levels
+}
+
+// chartData is everything needed to draw a chart, independent of the output format
+type chartData struct {
+	Pair           string
+	Interval       string
+	Type           string
+	Candles        []Candle
+	MovingAverages map[int][]float64 // Period -> value per candle (NaN until enough data)
+	AlertLevels    []Alert
+}
+
+// chartLayout maps prices and candle indexes to pixel coordinates
+type chartLayout struct {
+	Left, Right, Top, Bottom float64
+	Min, Max                 float64
+	Count                    int
+}
+
+// Chart colors
+var (
+	chartBackground = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	chartGrid       = color.NRGBA{R: 0xe5, G: 0xe7, B: 0xeb, A: 0xff}
+	chartText       = color.NRGBA{R: 0x37, G: 0x41, B: 0x51, A: 0xff}
+	chartLine       = color.NRGBA{R: 0x25, G: 0x63, B: 0xeb, A: 0xff}
+	chartUp         = color.NRGBA{R: 0x16, G: 0xa3, B: 0x4a, A: 0xff}
+	chartDown       = color.NRGBA{R: 0xdc, G: 0x26, B: 0x26, A: 0xff}
+	chartAlert      = color.NRGBA{R: 0xea, G: 0x58, B: 0x0c, A: 0xff}
+	chartMAColors   = []color.NRGBA{
+		{R: 0x93, G: 0x33, B: 0xea, A: 0xff},
+		{R: 0x0d, G: 0x94, B: 0x88, A: 0xff},
+		{R: 0xca, G: 0x8a, B: 0x04, A: 0xff},
+	}
+)
+
+// --- Chart Data ---
+
+// getChartConfig returns the configured chart settings with defaults applied.
+func getChartConfig() ChartConfig {
+	configMutex.RLock()
+	cfg := ChartConfig{}
+	if activeConfig != nil && activeConfig.Chart != nil {
+		cfg = *activeConfig.Chart
+	}
+	configMutex.RUnlock()
+
+	if cfg.Type == "" {
+		cfg.Type = defaultChartType
+	}
+	if cfg.Range == "" {
+		cfg.Range = defaultChartRange
+	}
+	if cfg.Interval == "" {
+		cfg.Interval = defaultBackfillInterval
+		if plan, err := getBackfillPlan(); err == nil {
+			cfg.Interval = plan.Interval
+		}
+	}
+	return cfg
+}
+
+// buildChartData collects candles, moving averages and alert levels for pair.
+// Candles come from the history store, falling back to fetching klines when
+// the store does not cover the requested range yet.
+func buildChartData(pair string, cfg ChartConfig) (*chartData, error) {
+	if _, ok := klineIntervals[cfg.Interval]; !ok {
+		return nil, fmt.Errorf("unsupported chart interval %q", cfg.Interval)
+	}
+	span, err := parseChartRange(cfg.Range)
+	if err != nil {
+		return nil, err
+	}
+
+	from := time.Now().Add(-span)
+	candles, err := loadHistory(pair, cfg.Interval, from, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	step := klineIntervals[cfg.Interval]
+	if len(candles) < 2 || candles[0].Time.Sub(from) > step {
+		fetched, err := fetchChartKlines(pair, cfg.Interval, from)
+		if err != nil {
+			if len(candles) < 2 {
+				return nil, fmt.Errorf("no history for %s: %w", pair, err)
+			}
+		} else if len(fetched) > len(candles) {
+			candles = fetched
+		}
+	}
+	if len(candles) < 2 {
+		return nil, fmt.Errorf("not enough history for %s", pair)
+	}
+
+	data := &chartData{
+		Pair:           pair,
+		Interval:       cfg.Interval,
+		Type:           cfg.Type,
+		Candles:        candles,
+		MovingAverages: make(map[int][]float64),
+	}
+	for _, period := range cfg.MovingAverages {
+		if period > 1 {
+			data.MovingAverages[period] = movingAverage(candles, period)
+		}
+	}
+
+	if !cfg.HideAlerts {
+		configMutex.RLock()
+		for _, alert := range activeConfig.Alerts {
+			if alert.Pair == pair && alert.Active {
+				data.AlertLevels = append(data.AlertLevels, alert)
+			}
+		}
+		configMutex.RUnlock()
+	}
+	return data, nil
+}
+
+func fetchChartKlines(pair, interval string, from time.Time) ([]Candle, error) {
+	client := newRateLimitedClient()
+	var all []Candle
+	start := from
+	for {
+		candles, next, err := fetchKlines(client, pair, interval, start, time.Time{})
+		if err != nil {
+			return all, err
+		}
+		all = append(all, candles...)
+		if next.IsZero() {
+			return all, nil
+		}
+		start = next
+		waitForRateLimit()
+	}
+}
+
+// parseChartRange parses durations like "36h", "7d" or "4w".
+func parseChartRange(s string) (time.Duration, error) {
+	unit := map[byte]time.Duration{'d': 24 * time.Hour, 'w': 7 * 24 * time.Hour}
+	if len(s) > 1 {
+		if mult, ok := unit[s[len(s)-1]]; ok {
+			n, err := strconv.Atoi(s[:len(s)-1])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid chart range %q", s)
+			}
+			return time.Duration(n) * mult, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid chart range %q", s)
+	}
+	return d, nil
+}
+
+// movingAverage returns the simple moving average of closes; the first
+// period-1 values are NaN.
+func movingAverage(candles []Candle, period int) []float64 {
+	res := make([]float64, len(candles))
+	sum := 0.0
+	for i, c := range candles {
+		sum += c.Close
+		if i >= period {
+			sum -= candles[i-period].Close
+		}
+		if i < period-1 {
+			res[i] = math.NaN()
+		} else {
+			res[i] = sum / float64(period)
+		}
+	}
+	return res
+}
+
+// --- Layout ---
+
+func newChartLayout(data *chartData, width, height int) chartLayout {
+	l := chartLayout{Left: 16, Right: float64(width) - 88, Top: 40, Bottom: float64(height) - 32, Count: len(data.Candles)}
+
+	l.Min, l.Max = math.Inf(1), math.Inf(-1)
+	for _, c := range data.Candles {
+		l.Min = math.Min(l.Min, c.Low)
+		l.Max = math.Max(l.Max, c.High)
+	}
+	for _, alert := range data.AlertLevels {
+		l.Min = math.Min(l.Min, alert.Target)
+		l.Max = math.Max(l.Max, alert.Target)
+	}
+	pad := (l.Max - l.Min) * 0.05
+	if pad == 0 {
+		pad = math.Max(math.Abs(l.Max)*0.01, 1e-8)
+	}
+	l.Min -= pad
+	l.Max += pad
+	return l
+}
+
+// X returns the horizontal center of candle i.
+func (l chartLayout) X(i int) float64 {
+	slot := (l.Right - l.Left) / float64(l.Count)
+	return l.Left + slot*(float64(i)+0.5)
+}
+
+// Y returns the vertical position of price.
+func (l chartLayout) Y(price float64) float64 {
+	return l.Top + (l.Max-price)*(l.Bottom-l.Top)/(l.Max-l.Min)
+}
+
+// CandleWidth returns the body width of a candle.
+func (l chartLayout) CandleWidth() float64 {
+	return math.Max(1, (l.Right-l.Left)/float64(l.Count)*0.7)
+}
+
+// gridPrices returns evenly spaced prices for the horizontal grid lines.
+func (l chartLayout) gridPrices(n int) []float64 {
+	prices := make([]float64, n)
+	for i := range prices {
+		prices[i] = l.Min + (l.Max-l.Min)*float64(i+1)/float64(n+1)
+	}
+	return prices
+}
+
+func chartTitle(data *chartData) string {
+	first, last := data.Candles[0], data.Candles[len(data.Candles)-1]
+	change := (last.Close - first.Open) / first.Open * 100
+	return fmt.Sprintf("%s %s  %s (%s)  %s - %s", data.Pair, data.Interval, formatPairPrice(data.Pair, last.Close), formatChange(change),
+		first.Time.Format("2006-01-02 15:04"), last.Time.Format("2006-01-02 15:04"))
+}
+
+func chartMAColor(i int) color.NRGBA {
+	return chartMAColors[i%len(chartMAColors)]
+}
+
+// sortedPeriods returns the moving average periods in ascending order.
+func sortedPeriods(mas map[int][]float64) []int {
+	var periods []int
+	for p := range mas {
+		periods = append(periods, p)
+	}
+	sort.Ints(periods)
+	return periods
+}
+
+// --- PNG Rendering ---
+
+// renderChartPNG draws the chart as a PNG image.
+func renderChartPNG(data *chartData, width, height int) ([]byte, error) {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: chartBackground}, image.Point{}, draw.Src)
+	l := newChartLayout(data, width, height)
+
+	// Grid and price axis
+	for _, price := range l.gridPrices(5) {
+		y := l.Y(price)
+		drawChartSegment(img, l.Left, y, l.Right, y, 1, chartGrid)
+		drawText(img, int(l.Right)+6, int(y)+4, strconv.FormatFloat(price, 'f', chartDecimals(l), 64), chartText)
+	}
+
+	// Price series
+	if data.Type == "candles" {
+		half := l.CandleWidth() / 2
+		for i, c := range data.Candles {
+			col := chartUp
+			if c.Close < c.Open {
+				col = chartDown
+			}
+			x := l.X(i)
+			drawChartSegment(img, x, l.Y(c.High), x, l.Y(c.Low), 1, col)
+			top, bottom := l.Y(math.Max(c.Open, c.Close)), l.Y(math.Min(c.Open, c.Close))
+			rect := image.Rect(int(math.Round(x-half)), int(math.Round(top)), int(math.Round(x+half)), int(math.Round(bottom))+1)
+			draw.Draw(img, rect, &image.Uniform{C: col}, image.Point{}, draw.Src)
+		}
+	} else {
+		for i := 1; i < len(data.Candles); i++ {
+			drawChartSegment(img, l.X(i-1), l.Y(data.Candles[i-1].Close), l.X(i), l.Y(data.Candles[i].Close), 2, chartLine)
+		}
+	}
+
+	// Moving averages
+	legendX := int(l.Left)
+	for n, period := range sortedPeriods(data.MovingAverages) {
+		col := chartMAColor(n)
+		values := data.MovingAverages[period]
+		for i := 1; i < len(values); i++ {
+			if math.IsNaN(values[i-1]) || math.IsNaN(values[i]) {
+				continue
+			}
+			drawChartSegment(img, l.X(i-1), l.Y(values[i-1]), l.X(i), l.Y(values[i]), 1.5, col)
+		}
+		label := fmt.Sprintf("MA%d", period)
+		drawText(img, legendX, int(l.Top)-6, label, col)
+		legendX += len(label)*7 + 12
+	}
+
+	// Alert levels as dashed lines
+	for _, alert := range data.AlertLevels {
+		y := l.Y(alert.Target)
+		for x := l.Left; x < l.Right; x += 10 {
+			drawChartSegment(img, x, y, math.Min(x+6, l.Right), y, 1.5, chartAlert)
+		}
+		label := fmt.Sprintf("%s %s", alert.Condition, strconv.FormatFloat(alert.Target, 'f', -1, 64))
+		drawText(img, int(l.Right)+6, int(y)-4, label, chartAlert)
+	}
+
+	// Title and time axis
+	drawText(img, int(l.Left), 18, chartTitle(data), chartText)
+	first, last := data.Candles[0].Time, data.Candles[len(data.Candles)-1].Time
+	drawText(img, int(l.Left), height-12, first.Format("Jan 02 15:04"), chartText)
+	endLabel := last.Format("Jan 02 15:04")
+	drawText(img, int(l.Right)-len(endLabel)*7, height-12, endLabel, chartText)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// drawChartSegment draws like drawSegment, but composites the line over what
+// is already drawn so crossing lines of different colors (price, moving
+// averages, alert levels) both stay visible.
+func drawChartSegment(img *image.NRGBA, x0, y0, x1, y1, width float64, c color.NRGBA) {
+	pad := width/2 + 1
+	r := image.Rect(
+		int(math.Floor(math.Min(x0, x1)-pad)), int(math.Floor(math.Min(y0, y1)-pad)),
+		int(math.Ceil(math.Max(x0, x1)+pad))+1, int(math.Ceil(math.Max(y0, y1)+pad))+1,
+	).Intersect(img.Rect)
+	if r.Empty() {
+		return
+	}
+	layer := image.NewNRGBA(r)
+	drawSegment(layer, x0, y0, x1, y1, width, c)
+	for py := r.Min.Y; py < r.Max.Y; py++ {
+		for px := r.Min.X; px < r.Max.X; px++ {
+			if src := layer.NRGBAAt(px, py); src.A > 0 {
+				img.SetNRGBA(px, py, blendOver(img.NRGBAAt(px, py), src))
+			}
+		}
+	}
+}
+
+// blendOver composites src over dst. Where dst already has the same color
+// only the larger alpha is kept, so overlapping segment joints do not look
+// darker.
+func blendOver(dst, src color.NRGBA) color.NRGBA {
+	srcA := float64(src.A) / 0xff
+	dstA := float64(dst.A) / 0xff
+	if dst.R == src.R && dst.G == src.G && dst.B == src.B {
+		return color.NRGBA{R: src.R, G: src.G, B: src.B, A: max(src.A, dst.A)}
+	}
+
+	outA := srcA + dstA*(1-srcA)
+	if outA == 0 {
+		return color.NRGBA{}
+	}
+	mix := func(s, d uint8) uint8 {
+		return uint8(math.Round((float64(s)*srcA + float64(d)*dstA*(1-srcA)) / outA))
+	}
+	return color.NRGBA{R: mix(src.R, dst.R), G: mix(src.G, dst.G), B: mix(src.B, dst.B), A: uint8(math.Round(outA * 0xff))}
+}
+
+func drawText(img *image.NRGBA, x, y int, text string, c color.NRGBA) {
+	d := &font.Drawer{
+		Dst:  img,
+		Src:  &image.Uniform{C: c},
+		Face: basicfont.Face7x13,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(text)
+}
+
+// chartDecimals picks enough decimals to tell grid lines apart.
+func chartDecimals(l chartLayout) int {
+	step := (l.Max - l.Min) / 6
+	if step <= 0 {
+		return 2
+	}
+	d := int(math.Ceil(-math.Log10(step))) + 1
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// --- SVG Rendering ---
+
+// renderChartSVG draws the chart as a standalone SVG document.
+func renderChartSVG(data *chartData, width, height int) []byte {
+	l := newChartLayout(data, width, height)
+	var b strings.Builder
+
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="monospace" font-size="12">`+"\n", width, height, width, height)
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%" fill="%s"/>`+"\n", svgColor(chartBackground))
+
+	for _, price := range l.gridPrices(5) {
+		y := l.Y(price)
+		fmt.Fprintf(&b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s"/>`+"\n", l.Left, y, l.Right, y, svgColor(chartGrid))
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" fill="%s">%s</text>`+"\n", l.Right+6, y+4, svgColor(chartText), strconv.FormatFloat(price, 'f', chartDecimals(l), 64))
+	}
+
+	if data.Type == "candles" {
+		w := l.CandleWidth()
+		for i, c := range data.Candles {
+			col := chartUp
+			if c.Close < c.Open {
+				col = chartDown
+			}
+			x := l.X(i)
+			top, bottom := l.Y(math.Max(c.Open, c.Close)), l.Y(math.Min(c.Open, c.Close))
+			fmt.Fprintf(&b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s"/>`+"\n", x, l.Y(c.High), x, l.Y(c.Low), svgColor(col))
+			fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"/>`+"\n", x-w/2, top, w, math.Max(1, bottom-top), svgColor(col))
+		}
+	} else {
+		points := make([]string, len(data.Candles))
+		for i, c := range data.Candles {
+			points[i] = fmt.Sprintf("%.1f,%.1f", l.X(i), l.Y(c.Close))
+		}
+		fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="2"/>`+"\n", strings.Join(points, " "), svgColor(chartLine))
+	}
+
+	legendX := l.Left
+	for n, period := range sortedPeriods(data.MovingAverages) {
+		col := svgColor(chartMAColor(n))
+		var points []string
+		for i, v := range data.MovingAverages[period] {
+			if !math.IsNaN(v) {
+				points = append(points, fmt.Sprintf("%.1f,%.1f", l.X(i), l.Y(v)))
+			}
+		}
+		if len(points) > 1 {
+			fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="1.5"/>`+"\n", strings.Join(points, " "), col)
+		}
+		label := fmt.Sprintf("MA%d", period)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" fill="%s">%s</text>`+"\n", legendX, l.Top-6, col, label)
+		legendX += float64(len(label)*7 + 12)
+	}
+
+	for _, alert := range data.AlertLevels {
+		y := l.Y(alert.Target)
+		col := svgColor(chartAlert)
+		label := fmt.Sprintf("%s %s", alert.Condition, strconv.FormatFloat(alert.Target, 'f', -1, 64))
+		fmt.Fprintf(&b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s" stroke-width="1.5" stroke-dasharray="6 4"/>`+"\n", l.Left, y, l.Right, y, col)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" fill="%s">%s</text>`+"\n", l.Right+6, y-4, col, html.EscapeString(label))
+	}
+
+	first, last := data.Candles[0].Time, data.Candles[len(data.Candles)-1].Time
+	fmt.Fprintf(&b, `<text x="%.1f" y="18" fill="%s">%s</text>`+"\n", l.Left, svgColor(chartText), html.EscapeString(chartTitle(data)))
+	fmt.Fprintf(&b, `<text x="%.1f" y="%d" fill="%s">%s</text>`+"\n", l.Left, height-12, svgColor(chartText), first.Format("Jan 02 15:04"))
+	fmt.Fprintf(&b, `<text x="%.1f" y="%d" fill="%s" text-anchor="end">%s</text>`+"\n", l.Right, height-12, svgColor(chartText), last.Format("Jan 02 15:04"))
+	b.WriteString("</svg>\n")
+	return []byte(b.String())
+}
+
+func svgColor(c color.NRGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// --- Export ---
+
+// exportChart renders the chart for pair in format ("png" or "svg") into dir
+// and returns the path of the written file.
+func exportChart(pair, format, dir string) (string, error) {
+	data, err := buildChartData(pair, getChartConfig())
+	if err != nil {
+		return "", err
+	}
+
+	var out []byte
+	switch format {
+	case "png":
+		out, err = renderChartPNG(data, chartWidth, chartHeight)
+		if err != nil {
+			return "", err
+		}
+	case "svg":
+		out = renderChartSVG(data, chartWidth, chartHeight)
+	default:
+		return "", fmt.Errorf("unsupported chart format %q", format)
+	}
+
+	name := fmt.Sprintf("%s_%s_%s.%s", pair, data.Interval, time.Now().Format("20060102-150405"), format)
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, out, 0644); err != nil {
+		return "", fmt.Errorf("could not write chart: %w", err)
+	}
+	return path, nil
+}
+
+// getChartSaveDir returns ~/Downloads if it exists, otherwise the home directory.
+func getChartSaveDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	downloads := filepath.Join(home, "Downloads")
+	if info, err := os.Stat(downloads); err == nil && info.IsDir() {
+		return downloads, nil
+	}
+	return home, nil
+}
+
+// getChartTempDir returns the directory for charts that are only opened, not
+// saved, after deleting the ones opened before.
+func getChartTempDir() (string, error) {
+	dir, err := getCacheDir()
+	if err != nil {
+		return "", err
+	}
+	chartDir := filepath.Join(dir, "charts")
+	if err := os.MkdirAll(chartDir, 0755); err != nil {
+		return "", err
+	}
+	pruneTempCharts(chartDir, tempChartMaxAge)
+	return chartDir, nil
+}
+
+// pruneTempCharts deletes the charts in dir older than maxAge and returns how
+// many were deleted.
+func pruneTempCharts(dir string, maxAge time.Duration) int {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Error listing temporary charts: %v", err)
+		}
+		return 0
+	}
+	removed := 0
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		if !e.Type().IsRegular() || (ext != ".png" && ext != ".svg") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || time.Since(info.ModTime()) < maxAge {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, e.Name())); err != nil {
+			log.Printf("Error deleting temporary chart: %v", err)
+			continue
+		}
+		removed++
+	}
+	return removed
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseChartRange(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"36h", 36 * time.Hour},
+		{"90m", 90 * time.Minute},
+		{"7d", 7 * 24 * time.Hour},
+		{"4w", 4 * 7 * 24 * time.Hour},
+		{"0d", 0},
+		{"-1w", 0},
+		{"d", 0},
+		{"1.5d", 0},
+		{"", 0},
+		{"-2h", 0},
+	}
+	for _, tt := range tests {
+		got, err := parseChartRange(tt.in)
+		if (err != nil) != (tt.want == 0) || got != tt.want {
+			t.Errorf("parseChartRange(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestMovingAverage(t *testing.T) {
+	candles := make([]Candle, 5)
+	for i, c := range []float64{1, 2, 3, 4, 10} {
+		candles[i].Close = c
+	}
+	got := movingAverage(candles, 3)
+	want := []float64{math.NaN(), math.NaN(), 2, 3, 17.0 / 3}
+	for i := range want {
+		if math.IsNaN(want[i]) != math.IsNaN(got[i]) || (!math.IsNaN(want[i]) && math.Abs(got[i]-want[i]) > 1e-9) {
+			t.Errorf("movingAverage = %v, want %v", got, want)
+			break
+		}
+	}
+	if got := movingAverage(candles[:2], 3); !math.IsNaN(got[0]) || !math.IsNaN(got[1]) {
+		t.Errorf("fewer candles than the period: %v", got)
+	}
+}
+
+// Charts are built from the history store when it covers the range, so no
+// request is made here.
+func TestBuildChartData(t *testing.T) {
+	setTestCacheDir(t)
+	configMutex.Lock()
+	old := activeConfig
+	activeConfig = &Config{Pairs: []string{"BTCUSDC"}, Alerts: []Alert{
+		{Pair: "BTCUSDC", Target: 100000, Condition: "above", Active: true},
+		{Pair: "BTCUSDC", Target: 50000, Condition: "below", Active: false},
+		{Pair: "ETHUSDC", Target: 5000, Condition: "above", Active: true},
+	}}
+	configMutex.Unlock()
+	t.Cleanup(func() {
+		configMutex.Lock()
+		activeConfig = old
+		configMutex.Unlock()
+	})
+
+	start := time.Now().Truncate(time.Minute).Add(-3 * time.Hour)
+	if _, err := appendHistory("BTCUSDC", "1m", testCandles(start, 180)); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := buildChartData("BTCUSDC", ChartConfig{Type: "line", Interval: "1m", Range: "2h", MovingAverages: []int{5, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(data.Candles); n < 119 || n > 121 {
+		t.Errorf("%d candles, want the last 2h (about 120)", n)
+	}
+	if _, ok := data.MovingAverages[5]; !ok || len(data.MovingAverages) != 1 {
+		t.Errorf("moving averages %v, want period 5 only", sortedPeriods(data.MovingAverages))
+	}
+	if len(data.AlertLevels) != 1 || data.AlertLevels[0].Target != 100000 {
+		t.Errorf("alert levels = %+v, want the active BTCUSDC alert", data.AlertLevels)
+	}
+
+	data, err = buildChartData("BTCUSDC", ChartConfig{Interval: "1m", Range: "2h", HideAlerts: true})
+	if err != nil || len(data.AlertLevels) != 0 {
+		t.Errorf("hide_alerts: %+v, %v", data.AlertLevels, err)
+	}
+	if _, err := buildChartData("BTCUSDC", ChartConfig{Interval: "2m", Range: "2h"}); err == nil {
+		t.Error("unsupported interval accepted")
+	}
+	if _, err := buildChartData("BTCUSDC", ChartConfig{Interval: "1m", Range: "soon"}); err == nil {
+		t.Error("invalid range accepted")
+	}
+}
+
+// testChartData is a fixed chart with a moving average and an alert level.
+func testChartData(chartType string) *chartData {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	candles := make([]Candle, 12)
+	for i := range candles {
+		open := 100 + float64(i%5)*2 - float64(i/5)
+		close := open + float64(i%3) - 1
+		candles[i] = Candle{Time: start.Add(time.Duration(i) * time.Hour), Open: open, Close: close, High: math.Max(open, close) + 1, Low: math.Min(open, close) - 1}
+	}
+	return &chartData{
+		Pair:           "BTCUSDC",
+		Interval:       "1h",
+		Type:           chartType,
+		Candles:        candles,
+		MovingAverages: map[int][]float64{3: movingAverage(candles, 3)},
+		AlertLevels:    []Alert{{Pair: "BTCUSDC", Target: 106, Condition: "above", Active: true}},
+	}
+}
+
+// SVG output only depends on the chart data; compare it with
+// testdata/chart/<type>.svg ("go test -update" rewrites them).
+func TestRenderChartSVGGolden(t *testing.T) {
+	for _, chartType := range []string{"line", "candles"} {
+		t.Run(chartType, func(t *testing.T) {
+			got := renderChartSVG(testChartData(chartType), 480, 270)
+			golden := filepath.Join("testdata", "chart", chartType+".svg")
+			if *updateGolden {
+				if err := os.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("rendered SVG differs from %s:\n%s", golden, got)
+			}
+		})
+	}
+}
+
+func TestRenderChartPNG(t *testing.T) {
+	data := testChartData("candles")
+	first, err := renderChartPNG(data, 480, 270)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := renderChartPNG(data, 480, 270)
+	if !bytes.Equal(first, second) {
+		t.Error("rendering the same data twice gives different images")
+	}
+	img, err := png.Decode(bytes.NewReader(first))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := img.Bounds().Size(); size.X != 480 || size.Y != 270 {
+		t.Errorf("size %v, want 480x270", size)
+	}
+}
+
+func TestBlendOver(t *testing.T) {
+	red := color.NRGBA{R: 0xff, A: 0xff}
+	blue := color.NRGBA{B: 0xff, A: 0xff}
+	tests := []struct {
+		dst, src, want color.NRGBA
+	}{
+		// Same color: the larger alpha wins, joints are not darker
+		{color.NRGBA{R: 0xff, A: 0x80}, color.NRGBA{R: 0xff, A: 0x40}, color.NRGBA{R: 0xff, A: 0x80}},
+		// Opaque source covers
+		{blue, red, red},
+		// Half covered blue over red keeps both visible
+		{red, color.NRGBA{B: 0xff, A: 0x80}, color.NRGBA{R: 0x7f, B: 0x80, A: 0xff}},
+		// Over transparent, the source is kept
+		{color.NRGBA{}, color.NRGBA{G: 0xff, A: 0x40}, color.NRGBA{G: 0xff, A: 0x40}},
+	}
+	for _, tt := range tests {
+		if got := blendOver(tt.dst, tt.src); got != tt.want {
+			t.Errorf("blendOver(%v, %v) = %v, want %v", tt.dst, tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestPruneTempCharts(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-2 * tempChartMaxAge)
+	for name, mtime := range map[string]time.Time{
+		"BTCUSDC_1h_old.png": old,
+		"BTCUSDC_1h_old.svg": old,
+		"BTCUSDC_1h_new.png": time.Now(),
+		"notes.txt":          old,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(path, mtime, mtime)
+	}
+
+	if n := pruneTempCharts(dir, tempChartMaxAge); n != 2 {
+		t.Errorf("deleted %d charts, want 2", n)
+	}
+	entries, _ := os.ReadDir(dir)
+	var left []string
+	for _, e := range entries {
+		left = append(left, e.Name())
+	}
+	if !slices.Equal(left, []string{"BTCUSDC_1h_new.png", "notes.txt"}) {
+		t.Errorf("left %v", left)
+	}
+}
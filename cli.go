@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+const cliUsage = `Usage: criptomenu [--config file] [command]
+
+Without a command, CriptoMenu starts in the menu bar.
+
+Options:
+  --config file         Use this config file (also: CRIPTOMENU_CONFIG)
+  --select PAIR         Display this pair at startup; if the app is already running,
+                        the pair is selected there instead
+  --pin PAIR            Pin this pair at startup, or in the running app
+  --verbose             Print log messages of the commands below (always on for daemon)
+
+Commands:
+  price [pair...]       Print the current price and 24h change (default: the configured pairs)
+  watch [pair...]       Show a table of prices refreshed every --interval (default 10s)
+  alerts list           List the configured alerts
+  alerts add PAIR above|below TARGET [--id ID] [--inactive]
+                        Add an alert to the config file
+  alerts remove ID|NUMBER
+                        Remove an alert, by id or by its number in 'alerts list'
+  alerts test           Fetch the prices and show which alerts would trigger
+  tui                   Full-screen dashboard of your pairs with sparklines, alerts and log
+  bar [--format F] [--once]
+                        Print the rotating/pinned title for status bars, one line per
+                        update; F is plain, tmux, polybar, i3bar or waybar
+  ctl select PAIR       Display a pair in the running app until the next rotation
+  ctl pin [PAIR]        Pin a pair (default: the displayed one) in the running app
+  ctl unpin|refresh|reload-config|status
+                        Unpin, fetch prices now, reload the config or show the state
+                        of the running app
+  daemon [--notify]     Fetch prices and check alerts without the menu bar, printing
+                        triggered alerts; --notify also shows desktop notifications
+  config check [file]   Validate the config file (default: the file the app uses)
+  config path           Show where the config file is searched and which one is used
+  config show [file]    Print the effective config (includes, profile and environment merged)
+  help                  Show this help
+`
+
+// --- Command Line ---
+
+// runCommand runs a command line subcommand and returns the exit code.
+func runCommand(args []string) int {
+	switch args[0] {
+	case "config":
+		return runConfigCommand(args[1:])
+	case "price":
+		return runPriceCommand(args[1:])
+	case "watch":
+		return runWatchCommand(args[1:])
+	case "alerts":
+		return runAlertsCommand(args[1:])
+	case "daemon":
+		return runDaemon(args[1:])
+	case "tui":
+		return runTUI(args[1:])
+	case "bar":
+		return runBarCommand(args[1:])
+	case "ctl":
+		return runCtlCommand(args[1:])
+	case "help", "-h", "--help":
+		fmt.Print(cliUsage)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", args[0], cliUsage)
+		return 2
+	}
+}
+
+func runConfigCommand(args []string) int {
+	if len(args) == 1 && args[0] == "path" {
+		for _, line := range describeConfigCandidates() {
+			fmt.Println(line)
+		}
+		return 0
+	}
+	if len(args) == 0 || (args[0] != "check" && args[0] != "show") || len(args) > 2 {
+		fmt.Fprint(os.Stderr, cliUsage)
+		return 2
+	}
+
+	path := ""
+	if len(args) == 2 {
+		path = args[1]
+	} else {
+		var err error
+		if path, err = getConfigFilePath(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+	if args[0] == "show" {
+		return showEffectiveConfig(os.Stdout, path)
+	}
+	return checkConfigFile(os.Stdout, path)
+}
+
+// showEffectiveConfig prints the config at path merged with its layers.
+func showEffectiveConfig(w io.Writer, path string) int {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		data, _, err = migrateConfigData(data)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: error: %v\n", path, err)
+		return 1
+	}
+	cfg, layers, problems := loadConfigLayers(path, data)
+	if cfg == nil {
+		for _, p := range problems {
+			printConfigProblem(os.Stderr, path, p)
+		}
+		return 1
+	}
+	fmt.Fprint(w, effectiveConfigText(layers))
+	return 0
+}
+
+// checkConfigFile validates the config at path, printing one line per problem
+// as "file:line:column: severity: message". It returns 1 when there are errors.
+func checkConfigFile(w io.Writer, path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(w, "%s: error: %v\n", path, err)
+		return 1
+	}
+
+	// Check the file as the app will read it, after migrating older versions
+	migrated, from, err := migrateConfigData(data)
+	if err != nil {
+		fmt.Fprintf(w, "%s: error: %v\n", path, err)
+		return 1
+	}
+	_, _, problems := loadConfigLayers(path, migrated)
+	if from < currentConfigVersion {
+		fmt.Fprintf(w, "%s: note: config version %d will be migrated to %d when the app loads it\n", path, from, currentConfigVersion)
+
+		// Report positions in the file as written, not in the migrated text;
+		// migrations rename keys, so lines are compared case-insensitively
+		lower := func(b []byte) []string { return splitLines(bytes.ToLower(b)) }
+		mapping := lineMapping(lower(data), lower(migrated))
+		for i, p := range problems {
+			if p.File == "" && p.Line > 0 && p.Line <= len(mapping) {
+				problems[i].Line = mapping[p.Line-1] + 1
+			}
+		}
+	}
+	errorCount := 0
+	for _, p := range problems {
+		if p.Severity == severityError {
+			errorCount++
+		}
+		printConfigProblem(w, path, p)
+	}
+
+	if errorCount > 0 {
+		fmt.Fprintf(w, "%d error(s), %d warning(s)\n", errorCount, len(problems)-errorCount)
+		return 1
+	}
+	fmt.Fprintf(w, "%s: OK (%d warning(s))\n", path, len(problems))
+	return 0
+}
+
+// printConfigProblem prints p as "file:line:column: severity: message", using
+// path for problems of the config file itself.
+func printConfigProblem(w io.Writer, path string, p ConfigProblem) {
+	if p.File != "" {
+		path = p.File
+	}
+	if p.Line > 0 {
+		fmt.Fprintf(w, "%s:%d:%d: %s: %s\n", path, p.Line, p.Column, p.Severity, p.Message)
+	} else {
+		fmt.Fprintf(w, "%s: %s: %s\n", path, p.Severity, p.Message)
+	}
+}
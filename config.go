@@ -1,15 +1,14 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
-	"time"
-
-	"github.com/pelletier/go-toml/v2"
 )
 
 // Alert struct defines a price alert condition
@@ -19,13 +18,39 @@ type Alert struct {
 	Target    float64 `toml:"target"`
 	Condition string  `toml:"condition"` // "above", "below"
 	Active    bool    `toml:"active"`
+	Shared    bool    `toml:"-" json:"-"` // From the shared watchlist; never written to the config file
 }
 
 // Config struct to hold application preferences
 type Config struct {
-	Pairs      []string `toml:"Pairs"`
-	Alerts     []Alert  `toml:"Alerts"`
+	Version    int      `toml:"version,omitempty"` // Schema version, see migrate.go
+	Pairs      []string `toml:"pairs"`
+	Alerts     []Alert  `toml:"alerts"`
 	PinnedPair string   `toml:"pinned_pair,omitempty"`
+	StaleAfter string   `toml:"stale_after,omitempty"` // e.g. "5m"; cached prices older than this are marked stale
+
+	TitleTemplate string             `toml:"title_template,omitempty"` // Go text/template, see title.go
+	Aliases       map[string]string  `toml:"aliases,omitempty"`        // Pair -> display name, e.g. BTCUSDC = "₿"
+	Holdings      map[string]float64 `toml:"holdings,omitempty"`       // Pair -> amount of the base asset held
+
+	Backfill *BackfillConfig `toml:"backfill,omitempty"`
+	Icon     *IconConfig     `toml:"icon,omitempty"`
+	Chart    *ChartConfig    `toml:"chart,omitempty"`
+	Format   *FormatConfig   `toml:"format,omitempty"`
+
+	MarketChart *MarketChartConfig `toml:"market_chart,omitempty"`
+	API         *APIConfig         `toml:"api,omitempty"`
+	MQTT        *MQTTConfig        `toml:"mqtt,omitempty"`
+
+	// Shared pairs and alerts, see watchlist.go. SharedPairs is the number of
+	// pairs at the end of Pairs that were added from it.
+	Watchlist   *WatchlistConfig `toml:"watchlist,omitempty"`
+	SharedPairs int              `toml:"-"`
+
+	// Layering, see configlayers.go
+	Extends  []string                  `toml:"extends,omitempty"`  // Base files merged below this one
+	Profile  string                    `toml:"profile,omitempty"`  // Name of the active profile
+	Profiles map[string]map[string]any `toml:"profiles,omitempty"` // Name -> settings overriding the rest of the file
 }
 
 var (
@@ -36,124 +61,104 @@ var (
 
 // --- Config Helpers ---
 
-func getConfigFilePath() (string, error) {
-	// 1. Check CWD (works for 'go run' and terminal launch)
-	localPath, _ := filepath.Abs(".criptomenu.toml")
-	if _, err := os.Stat(localPath); err == nil {
-		return localPath, nil
-	}
-
-	// 2. Check relative to Executable (Traverse up)
-	exePath, err := os.Executable()
-	if err == nil {
-		dir := filepath.Dir(exePath)
-		// Traverse up to 5 levels (covers Content/MacOS/Bundle/Build/ProjectRoot)
-		for i := 0; i < 5; i++ {
-			checkPath := filepath.Join(dir, ".criptomenu.toml")
-			if _, err := os.Stat(checkPath); err == nil {
-				return checkPath, nil
-			}
-			parent := filepath.Dir(dir)
-			if parent == dir {
-				break // Hit root
-			}
-			dir = parent
-		}
-	}
-
-	// 3. Fallback to Home directory (Production/App mode)
-	home, err := os.UserHomeDir()
+// loadConfig reads and validates the config file. cfg is nil when the file
+// cannot be decoded; err is only set when it cannot be read.
+func loadConfig() (*Config, *configLayers, []ConfigProblem, error) {
+	path, err := getConfigFilePath()
 	if err != nil {
-		return "", err
+		return nil, nil, nil, err
 	}
-	return filepath.Join(home, ".criptomenu.toml"), nil
-}
 
-func loadConfig() (*Config, error) {
-	path, err := getConfigFilePath()
-	if err != nil {
-		return nil, err
+	// Upgrade files written by older releases before reading them
+	if err := migrateConfigFile(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		// Syntax errors are reported with their position by the check below
+		log.Printf("Error migrating config file: %v", err)
 	}
 
 	file, err := os.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("could not read config file: %w", err)
+		return nil, nil, nil, fmt.Errorf("could not read config file: %w", err)
 	}
+	rememberConfigContent(path, file)
 
-	var cfg Config
-	err = toml.Unmarshal(file, &cfg)
-	if err != nil {
-		return nil, fmt.Errorf("could not unmarshal config TOML: %w", err)
-	}
-	return &cfg, nil
+	// Merge included files, the active profile and environment overrides
+	cfg, layers, problems := loadConfigLayers(path, file)
+	return cfg, layers, problems, nil
 }
 
-func saveConfigInternal(cfg *Config) error {
-	path, err := getConfigFilePath()
-	if err != nil {
-		return err
-	}
+// loadAndSetConfig loads the config file and makes it the active config. It
+// returns false when the file could not be used and the previous (or default)
+// config stays active.
+func loadAndSetConfig() bool {
+	applied := true
+	cfg, layers, problems, err := loadConfig()
+	if errors.Is(err, fs.ErrNotExist) {
+		log.Println("Config file not found. Creating default with comments...")
 
-	data, err := toml.Marshal(cfg)
-	if err != nil {
-		return fmt.Errorf("could not marshal config to TOML: %w", err)
-	}
+		// Create the default file with comments
+		if createErr := createDefaultConfig(); createErr != nil {
+			log.Printf("Error creating default config: %v", createErr)
+		}
 
-	err = os.WriteFile(path, data, 0644)
+		// Load it back
+		cfg, layers, problems, err = loadConfig()
+	}
 	if err != nil {
-		return fmt.Errorf("could not write config file: %w", err)
+		problems = append(problems, ConfigProblem{Severity: severityError, Message: err.Error()})
 	}
-	return nil
-}
 
-func loadAndSetConfig() {
-	cfg, err := loadConfig()
-	if err != nil {
-		// Check if file does not exist (or wrapped "no such file" error)
-		if os.IsNotExist(err) || strings.Contains(err.Error(), "no such file") {
-			log.Println("Config file not found. Creating default with comments...")
-			
-			// Create the default file with comments
-			if createErr := createDefaultConfig(); createErr != nil {
-				log.Printf("Error creating default config: %v", createErr)
+	setConfigProblems(problems)
+	if hasConfigErrors(problems) {
+		var lines []string
+		for _, p := range problems {
+			if p.Severity == severityError {
+				lines = append(lines, p.String())
 			}
+		}
+		action := "Invalid entries are ignored."
+		if cfg == nil {
+			action = "Using the previous or default configuration."
+		}
+		showErrorAlert("Config Error", fmt.Sprintf("The config file has errors. %s\n%s", action, strings.Join(lines, "\n")))
+	}
 
-			// Load it back
-			cfg, err = loadConfig()
-			if err != nil {
-				log.Printf("Error loading newly created config: %v", err)
-				cfg = &Config{Pairs: []string{"BTCUSDC", "ETHUSDC"}} // Fallback
-			}
-		} else if strings.Contains(err.Error(), "toml:") || strings.Contains(err.Error(), "decode") {
-			// TOML syntax error
-			errMsg := fmt.Sprintf("Config file has invalid TOML. Using default.\nError: %v", err)
-			log.Printf(errMsg)
-			showErrorAlert("Config Error", errMsg)
-			
-			configMutex.RLock()
-			hasConfig := activeConfig != nil
-			configMutex.RUnlock()
-
-			if hasConfig {
-				return
-			}
-			// Startup with bad file -> Fallback
-			cfg = &Config{Pairs: []string{"BTCUSDC", "ETHUSDC"}}
-		} else {
-			// Other errors
-			errMsg := fmt.Sprintf("Error loading config. Using default.\nError: %v", err)
-			log.Printf(errMsg)
-			showErrorAlert("Config Error", errMsg)
-
-			cfg = &Config{Pairs: []string{"BTCUSDC", "ETHUSDC"}}
+	if cfg == nil {
+		configMutex.RLock()
+		hasConfig := activeConfig != nil
+		configMutex.RUnlock()
+
+		if hasConfig {
+			return false
 		}
+		// Startup with bad file -> Fallback
+		cfg = &Config{Pairs: []string{"BTCUSDC", "ETHUSDC"}}
+		layers = nil
+		applied = false
 	}
-	
-	if cfg != nil {
-		configMutex.Lock()
-		activeConfig = cfg
-		configMutex.Unlock()
+
+	// Shared watchlist entries are merged in as a read-only layer
+	applyWatchlist(cfg)
+
+	configMutex.Lock()
+	watchlistMoved := activeConfig != nil && activeConfig.Watchlist.source() != cfg.Watchlist.source()
+	activeConfig = cfg
+	configMutex.Unlock()
+	setActiveLayers(layers)
+
+	// Report pairs the exchange does not know or does not trade
+	validateConfiguredPairs()
+
+	updateWatchlistMenu()
+	if watchlistMoved {
+		triggerWatchlistRefresh() // Fetch the new watchlist now
 	}
+
+	// Start, stop or move the local API when [api] changed
+	updateAPIServer()
+
+	// Reconnect to the broker when [mqtt] changed, announce added pairs
+	updateMQTT()
+	return applied
 }
 
 func createDefaultConfig() error {
@@ -161,19 +166,92 @@ func createDefaultConfig() error {
 	if err != nil {
 		return err
 	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
 
 	defaultContent := `# Configuration for CriptoMenu
 #
-# Pairs: List of Binance trading pairs to display in the menu.
+# version: Schema version of this file, updated automatically when the app migrates it.
+#
+# pairs: List of Binance trading pairs to display in the menu.
 #        Example: ["BTCUSDC", "ETHUSDC"]
 #
-# Alerts: Define price alerts.
+# alerts: Define price alerts.
 #   - pair: The trading pair to monitor.
 #   - target: The price level to trigger the alert.
 #   - condition: "above" (trigger when price goes above target) or "below" (trigger when price drops below target).
 #   - active: Set to true to enable the alert. The app will set this to false after it triggers.
+#
+# stale_after: Age after which the displayed price is marked as stale (default "2m").
+#
+# title_template: Go text/template for the menubar title (default '{{.Pair}}: {{price .Price}}').
+#   Fields: .Pair .Base .Quote .Price .Change (24h %) .Stale .Age .Holdings .Value
+#   Functions: price, change, arrow, compact, number, alias, value
+#   Examples: '{{alias .Pair}} {{compact .Price}} {{arrow .Change}}{{change .Change}}' -> "BTC 92.4k ▲+1.20%"
+#             '{{alias .Pair}} {{number .Price 0}}' -> "₿ 92,430"
+#
+# [aliases]: Display names used by the alias function, e.g. BTCUSDC = "₿".
+# [holdings]: Amount of the base asset you hold per pair, e.g. BTCUSDC = 0.5 (used by .Value and value).
+#
+# [format]: Price formatting. Decimals follow each symbol's tick size on Binance.
+#   - currency_symbols: Show "$", "€", ... for fiat and stablecoin quotes (default true).
+#   - thousands_separator: Separator between digit groups (default ","; "" to disable).
+#   - significant_digits: Limit prices to this many significant digits.
+#   - [format.precision]: Decimals per pair, e.g. SHIBUSDC = 8.
+#
+# [market_chart]: Website opened by "Market Chart".
+#   - provider: "binance" (default), "tradingview", "coingecko" or "custom".
+#   - locale: Site language, e.g. "en" (default), "it".
+#   - url_template: For "custom", with {symbol}, {base}, {quote}, {base_lower}, {quote_lower} and {locale},
+#     e.g. "https://www.kraken.com/prices/{base_lower}".
+#
+# [backfill]: Historical prices fetched from Binance klines for every pair (enabled by default).
+#   - interval: Kline interval, e.g. "15m", "1h", "1d" (default "1h").
+#   - days: How many days back to fetch (default 30), or set start/end dates as "YYYY-MM-DD".
+#   - enabled: Set to false to disable the backfill.
+#
+# [icon]: Tray icon showing a sparkline of the displayed pair's recent history.
+#   - style: "sparkline" (default) or "static".
+#   - theme: "auto" (default), "light" or "dark".
+#   - template: macOS only, draw a monochrome icon that follows the menubar color.
+#   - points: Number of history points to draw (default 24).
+#
+# [chart]: Local charts ("Local Chart" menu) drawn from the price history, with your alert levels.
+#   - type: "line" (default) or "candles".
+#   - interval: Kline interval (defaults to the backfill interval).
+#   - range: Time span to draw, e.g. "24h", "7d", "4w" (default "7d").
+#   - moving_averages: Simple moving average periods, e.g. [20, 50].
+#   - hide_alerts: Set to true to not draw alert levels.
+#
+# [watchlist]: Shared pairs and alerts added read-only after your own.
+#   - url: http(s) URL of a TOML or JSON file with pairs and alerts, or
+#   - path: Local file or directory (e.g. a git checkout) with watchlist.toml or watchlist.json.
+#   - git_pull: With path, run "git pull --ff-only" before reading.
+#   - interval: How often to fetch it (default "15m").
+#
+# [api]: Local HTTP API with prices, alerts and control of the app (see the README).
+#   - enabled: Set to true to start it.
+#   - listen: Address to listen on (default "127.0.0.1:8765"). The API has no authentication,
+#     keep it on localhost.
+#
+# [mqtt]: Publish prices, 24h changes and alert states to an MQTT broker (see the README).
+#   - broker: Broker URL, e.g. "tcp://localhost:1883" or "ssl://broker:8883". Leave unset to disable.
+#   - client_id: Client id (default "criptomenu-<hostname>"), also names the Home Assistant device.
+#   - username, password: Broker credentials (or CRIPTOMENU_MQTT_PASSWORD).
+#   - topic_prefix: Topics are <prefix>/<PAIR>/price, change and alert (default "criptomenu").
+#   - qos: 0 (default), 1 or 2. retain: Set to false to not retain the states.
+#   - events: Set to false to not publish triggered alerts to <prefix>/events/alert.
+#   - discovery: Set to false to not announce Home Assistant sensors (discovery_prefix, default "homeassistant").
+#
+# extends: Files merged below this one, e.g. ["~/team/criptomenu.toml"].
+# [profiles.<name>]: Settings overriding the rest of the file when profile = "<name>" is set
+#   (or chosen from the "Profile" menu). CRIPTOMENU_* environment variables override single values,
+#   e.g. CRIPTOMENU_STALE_AFTER=5m.
 
-Pairs = [
+version = 2
+
+pairs = [
     "BTCUSDC",
     "ETHUSDC",
     "ADAUSDC",
@@ -182,46 +260,28 @@ Pairs = [
 ]
 
 # Example Alert (Uncomment and modify to use)
-# [[Alerts]]
+# [[alerts]]
 #   pair = "BTCUSDC"
 #   target = 100000.0
 #   condition = "above" # "above" or "below"
 #   active = true
 
-# [[Alerts]]
+# [[alerts]]
 #   pair = "ETHUSDC"
 #   target = 10000.0
 #   condition = "below" # "above" or "below"
 #   active = true
 
-# [[Alerts]]
+# [[alerts]]
 #   pair = "LTCUSDC"
 #   target = 50.0
 #   condition = "below" # "above" or "below"
 #   active = false
-`
-	return os.WriteFile(path, []byte(defaultContent), 0644)
-}
-
-func watchConfig() {
-	ticker := time.NewTicker(2 * time.Second)
-	var lastModTime time.Time
-	configPath, err := getConfigFilePath()
-	if err != nil {
-		log.Printf("Error getting config path for watcher: %v", err)
-		return
-	}
 
-	for range ticker.C {
-		info, err := os.Stat(configPath)
-		if err != nil {
-			continue
-		}
-		if !lastModTime.IsZero() && info.ModTime() != lastModTime {
-			log.Println("Config file changed. Reloading...")
-			loadAndSetConfig()
-			updatePairsMenu()
-		}
-		lastModTime = info.ModTime()
-	}
+# Example history backfill (Uncomment and modify to use)
+# [backfill]
+#   interval = "1h"
+#   days = 30
+`
+	return writeFileAtomic(path, []byte(defaultContent))
 }
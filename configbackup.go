@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getlantern/systray"
+)
+
+const (
+	// Number of previous config versions kept in the backup directory
+	maxConfigBackups = 10
+
+	// Backup file names are "config-<timestamp>.toml"
+	configBackupPrefix     = "config-"
+	configBackupSuffix     = ".toml"
+	configBackupTimeFormat = "20060102-150405.000"
+)
+
+// configBackup is a saved previous version of the config file
+type configBackup struct {
+	Path    string
+	SavedAt time.Time
+}
+
+var (
+	// "Restore previous config" menu and its items, one per backup
+	mRestoreConfig     *systray.MenuItem
+	restoreConfigItems []*systray.MenuItem
+	configBackups      []configBackup
+	configBackupsMutex sync.Mutex
+)
+
+// --- Atomic Writes ---
+
+// writeConfigFile replaces the config file at path with data. The current
+// content is backed up first, then data is written to a temporary file in the
+// same directory, synced and renamed over the old file, so a crash never
+// leaves a partially written config.
+func writeConfigFile(path string, data []byte) error {
+	if err := backupConfigFile(path); err != nil {
+		log.Printf("Error backing up config file: %v", err)
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return err
+	}
+	rememberConfigContent(path, data) // Not a change for the watcher
+	updateRestoreConfigMenu()
+	return nil
+}
+
+// writeFileAtomic writes data to path via a synced temporary file and rename,
+// keeping the permissions of an existing file. When path is a symlink, the
+// file it points to is replaced and the link is kept.
+func writeFileAtomic(path string, data []byte) error {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("could not create temporary file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // No-op after a successful rename
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write temporary file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not sync temporary file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("could not replace %s: %w", path, err)
+	}
+
+	// Persist the rename itself; not supported on every platform
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// --- Backups ---
+
+// getConfigBackupDir returns the backup directory of the config file at
+// configPath. Each config file has its own directory, named after a hash of
+// its resolved path, so files selected with --config keep separate backups.
+func getConfigBackupDir(configPath string) (string, error) {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(configPath); err == nil {
+		configPath = resolved
+	} else if abs, err := filepath.Abs(configPath); err == nil {
+		configPath = abs
+	}
+	sum := sha256.Sum256([]byte(configPath))
+	dir := filepath.Join(base, "criptomenu", "backups", hex.EncodeToString(sum[:6]))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// backupConfigFile copies the current config file into the backup directory,
+// unless it matches the newest backup, and prunes old backups.
+func backupConfigFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	backups, err := listConfigBackups(path)
+	if err != nil {
+		return err
+	}
+	if len(backups) > 0 {
+		if last, err := os.ReadFile(backups[0].Path); err == nil && bytes.Equal(last, data) {
+			return nil
+		}
+	}
+
+	dir, err := getConfigBackupDir(path)
+	if err != nil {
+		return err
+	}
+	name := configBackupPrefix + time.Now().Format(configBackupTimeFormat) + configBackupSuffix
+	if err := writeFileAtomic(filepath.Join(dir, name), data); err != nil {
+		return err
+	}
+
+	backups, err = listConfigBackups(path)
+	if err != nil {
+		return err
+	}
+	for i := maxConfigBackups; i < len(backups); i++ {
+		if err := os.Remove(backups[i].Path); err != nil {
+			log.Printf("Error removing old config backup: %v", err)
+		}
+	}
+	return nil
+}
+
+// listConfigBackups returns the backups of the config file at configPath,
+// newest first.
+func listConfigBackups(configPath string) ([]configBackup, error) {
+	dir, err := getConfigBackupDir(configPath)
+	if err != nil {
+		return nil, err
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []configBackup
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !strings.HasPrefix(name, configBackupPrefix) || !strings.HasSuffix(name, configBackupSuffix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, configBackupPrefix), configBackupSuffix)
+		t, err := time.ParseInLocation(configBackupTimeFormat, stamp, time.Local)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, configBackup{Path: filepath.Join(dir, name), SavedAt: t})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].SavedAt.After(backups[j].SavedAt) })
+	return backups, nil
+}
+
+// restoreConfigBackup replaces the config with backup (backing up the current
+// version, so a restore can be undone) and reloads it.
+func restoreConfigBackup(backup configBackup) error {
+	data, err := os.ReadFile(backup.Path)
+	if err != nil {
+		return err
+	}
+	path, err := getConfigFilePath()
+	if err != nil {
+		return err
+	}
+	if err := writeConfigFile(path, data); err != nil {
+		return err
+	}
+	log.Printf("Restored config from backup of %s", backup.SavedAt.Format(time.DateTime))
+
+	loadAndSetConfig()
+	updatePairsMenu()
+	triggerBackfill()
+	return nil
+}
+
+// --- Diff Summaries ---
+
+// diffSummary describes how other differs from current, e.g. "+2 −1 lines".
+func diffSummary(current, other []byte) string {
+	added, removed := lineDiff(splitLines(current), splitLines(other))
+	if added == 0 && removed == 0 {
+		return "same as current"
+	}
+	return fmt.Sprintf("+%d −%d lines", added, removed)
+}
+
+// lineDiff counts the lines only in b (added) and only in a (removed), using
+// the longest common subsequence of the two.
+func lineDiff(a, b []string) (added, removed int) {
+	common := lcsTable(a, b)[0][0]
+	return len(b) - common, len(a) - common
+}
+
+// lineMapping maps each line of b to the index of the corresponding line in
+// a. Lines only in b map to the line after the previous common line.
+func lineMapping(a, b []string) []int {
+	lcs := lcsTable(a, b)
+	res := make([]int, len(b))
+	i, j := 0, 0
+	for j < len(b) {
+		switch {
+		case i < len(a) && a[i] == b[j]:
+			res[j] = i
+			i++
+			j++
+		case i < len(a) && lcs[i+1][j] > lcs[i][j+1]:
+			i++
+		default:
+			res[j] = min(i, max(len(a)-1, 0))
+			j++
+		}
+	}
+	return res
+}
+
+// lcsTable returns the lengths of the longest common subsequences of the
+// suffixes of a and b.
+func lcsTable(a, b []string) [][]int {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	return lcs
+}
+
+// changedLines returns up to n lines of other that are not in current, for
+// tooltips.
+func changedLines(current, other []byte, n int) []string {
+	seen := make(map[string]bool)
+	for _, l := range splitLines(current) {
+		seen[l] = true
+	}
+	var res []string
+	for _, l := range splitLines(other) {
+		l = strings.TrimSpace(l)
+		if l == "" || seen[l] || strings.HasPrefix(l, "#") {
+			continue
+		}
+		res = append(res, l)
+		if len(res) == n {
+			break
+		}
+	}
+	return res
+}
+
+func splitLines(data []byte) []string {
+	return strings.Split(strings.TrimRight(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n"), "\n")
+}
+
+// --- Restore Menu ---
+
+// addRestoreConfigMenu adds the "Restore previous config" menu.
+func addRestoreConfigMenu() {
+	mRestoreConfig = systray.AddMenuItem("Restore previous config", "Go back to an earlier version of the config file")
+	updateRestoreConfigMenu()
+}
+
+// updateRestoreConfigMenu lists the backups with their time and a summary of
+// their differences from the current config.
+func updateRestoreConfigMenu() {
+	if mRestoreConfig == nil {
+		return
+	}
+	path, err := getConfigFilePath()
+	if err != nil {
+		return
+	}
+	backups, err := listConfigBackups(path)
+	if err != nil {
+		log.Printf("Error listing config backups: %v", err)
+		return
+	}
+	current, _ := os.ReadFile(path)
+
+	configBackupsMutex.Lock()
+	defer configBackupsMutex.Unlock()
+	configBackups = backups
+
+	if len(backups) == 0 {
+		mRestoreConfig.Disable()
+	} else {
+		mRestoreConfig.Enable()
+	}
+
+	for i := len(restoreConfigItems); i < len(backups); i++ {
+		item := mRestoreConfig.AddSubMenuItem("", "")
+		restoreConfigItems = append(restoreConfigItems, item)
+
+		go func(index int, it *systray.MenuItem) {
+			for range it.ClickedCh {
+				handleRestoreClick(index)
+			}
+		}(i, item)
+	}
+
+	for i, item := range restoreConfigItems {
+		if i >= len(backups) {
+			item.Hide()
+			continue
+		}
+		data, err := os.ReadFile(backups[i].Path)
+		if err != nil {
+			item.Hide()
+			continue
+		}
+		item.SetTitle(fmt.Sprintf("%s (%s)", backups[i].SavedAt.Format("Jan 2 15:04:05"), diffSummary(current, data)))
+		tooltip := "Restore this version"
+		if lines := changedLines(current, data, 3); len(lines) > 0 {
+			tooltip += "; differs in: " + strings.Join(lines, " | ")
+		}
+		item.SetTooltip(tooltip)
+		item.Show()
+	}
+}
+
+func handleRestoreClick(index int) {
+	configBackupsMutex.Lock()
+	if index < 0 || index >= len(configBackups) {
+		configBackupsMutex.Unlock()
+		return
+	}
+	backup := configBackups[index]
+	configBackupsMutex.Unlock()
+
+	if err := restoreConfigBackup(backup); err != nil {
+		log.Printf("Error restoring config backup: %v", err)
+		showErrorAlert("Restore Config", fmt.Sprintf("Could not restore the config.\nError: %v", err))
+	}
+}
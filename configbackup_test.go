@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+)
+
+// setTestConfigDir points the user config directory (and so the backups) at
+// a temporary directory, which is returned.
+func setTestConfigDir(t *testing.T) string {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "config"))
+	t.Setenv("AppData", filepath.Join(dir, "config"))
+	return dir
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+
+	if err := writeFileAtomic(path, []byte("a = 1\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFileAtomic(path, []byte("a = 2\n")); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "a = 2\n" {
+		t.Errorf("content = %q", data)
+	}
+	if info, err := os.Stat(path); err != nil || (runtime.GOOS != "windows" && info.Mode().Perm() != 0600) {
+		t.Errorf("mode = %v, %v; want the old 0600", info.Mode(), err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("%d files in the directory, want no temporary files left", len(entries))
+	}
+}
+
+// A symlinked config (e.g. into a dotfiles repository) stays a symlink.
+func TestWriteFileAtomicSymlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "dotfiles", "criptomenu.toml")
+	link := filepath.Join(dir, "config.toml")
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(target, []byte("a = 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	if err := writeFileAtomic(link, []byte("a = 2\n")); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("config is no longer a symlink: %v, %v", info.Mode(), err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "a = 2\n" {
+		t.Errorf("link target = %q, want the new content", data)
+	}
+}
+
+func TestBackupConfigFile(t *testing.T) {
+	dir := setTestConfigDir(t)
+	path := filepath.Join(dir, "config.toml")
+	other := filepath.Join(dir, "other.toml")
+
+	for i := range maxConfigBackups + 3 {
+		if err := os.WriteFile(path, fmt.Appendf(nil, "version = %d\n", i), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := backupConfigFile(path); err != nil {
+			t.Fatal(err)
+		}
+		// Unchanged content is not backed up twice
+		if err := backupConfigFile(path); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(2 * time.Millisecond) // Backup names have millisecond precision
+	}
+
+	backups, err := listConfigBackups(path)
+	if err != nil || len(backups) != maxConfigBackups {
+		t.Fatalf("%d backups, %v; want the newest %d", len(backups), err, maxConfigBackups)
+	}
+	if data, _ := os.ReadFile(backups[0].Path); string(data) != fmt.Sprintf("version = %d\n", maxConfigBackups+2) {
+		t.Errorf("newest backup = %q", data)
+	}
+	if data, _ := os.ReadFile(backups[len(backups)-1].Path); string(data) != "version = 3\n" {
+		t.Errorf("oldest backup = %q", data)
+	}
+
+	// Another config file has its own backups
+	if err := os.WriteFile(other, []byte("version = 2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := backupConfigFile(other); err != nil {
+		t.Fatal(err)
+	}
+	if backups, _ := listConfigBackups(other); len(backups) != 1 {
+		t.Errorf("%d backups of the other config, want 1", len(backups))
+	}
+	if backups, _ := listConfigBackups(path); len(backups) != maxConfigBackups {
+		t.Errorf("%d backups after backing up another config, want %d", len(backups), maxConfigBackups)
+	}
+}
+
+func TestLineDiff(t *testing.T) {
+	tests := []struct {
+		a, b           []string
+		added, removed int
+	}{
+		{nil, nil, 0, 0},
+		{[]string{"a", "b"}, []string{"a", "b"}, 0, 0},
+		{[]string{"a", "b"}, []string{"a", "x", "b"}, 1, 0},
+		{[]string{"a", "b", "c"}, []string{"a", "c"}, 0, 1},
+		{[]string{"a", "b", "c"}, []string{"c", "b", "a"}, 2, 2},
+		{[]string{"a"}, []string{"b"}, 1, 1},
+	}
+	for _, tt := range tests {
+		if added, removed := lineDiff(tt.a, tt.b); added != tt.added || removed != tt.removed {
+			t.Errorf("lineDiff(%q, %q) = +%d -%d, want +%d -%d", tt.a, tt.b, added, removed, tt.added, tt.removed)
+		}
+	}
+
+	if got := diffSummary([]byte("a\r\nb\r\n"), []byte("a\nb")); got != "same as current" {
+		t.Errorf("line endings only: %q", got)
+	}
+	if got := diffSummary([]byte("a\nb\n"), []byte("a\nc\nd\n")); got != "+2 −1 lines" {
+		t.Errorf("diffSummary = %q", got)
+	}
+}
+
+func TestLineMapping(t *testing.T) {
+	got := lineMapping([]string{"a", "b", "c"}, []string{"a", "x", "c", "y"})
+	if want := []int{0, 1, 2, 2}; !slices.Equal(got, want) {
+		t.Errorf("lineMapping = %v, want %v", got, want)
+	}
+}
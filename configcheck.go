@@ -0,0 +1,434 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/getlantern/systray"
+	"github.com/pelletier/go-toml/v2"
+)
+
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+)
+
+// ConfigProblem is an error or warning found in the config file
+type ConfigProblem struct {
+	Severity string // "error" or "warning"
+	Line     int    // 1-based; 0 when the position is unknown
+	Column   int
+	Message  string
+	File     string // Included file the problem is in; "" for the config file itself
+}
+
+// String renders the problem as "line 12, column 15: error: ...", prefixed
+// with the file name for problems in included files.
+func (p ConfigProblem) String() string {
+	s := fmt.Sprintf("%s: %s", p.Severity, p.Message)
+	if p.Line > 0 {
+		s = fmt.Sprintf("line %d, column %d: %s", p.Line, p.Column, s)
+	}
+	if p.File != "" {
+		s = filepath.Base(p.File) + ": " + s
+	}
+	return s
+}
+
+// Binance symbols are upper case letters and digits, e.g. "BTCUSDC"
+var symbolPattern = regexp.MustCompile(`^[A-Z0-9]+$`)
+
+var (
+	// Problems of the last loaded config and their menu items
+	configProblems      []ConfigProblem
+	configProblemsMutex sync.Mutex
+	mConfigProblems     *systray.MenuItem
+	configProblemItems  []*systray.MenuItem
+)
+
+// --- Config Validation ---
+
+// checkConfigData decodes and validates a config document. cfg is nil when
+// the document cannot be decoded; unknown keys and questionable values are
+// reported as warnings, invalid values as errors.
+func checkConfigData(data []byte) (*Config, []ConfigProblem) {
+	cfg, problems := decodeConfigData(data)
+	if cfg == nil {
+		return nil, problems
+	}
+	return cfg, append(problems, validateConfig(cfg, newConfigLocator(data))...)
+}
+
+// decodeConfigData decodes a config document, reporting syntax errors,
+// unknown keys and unsupported versions without validating the values.
+func decodeConfigData(data []byte) (*Config, []ConfigProblem) {
+	var problems []ConfigProblem
+	var cfg Config
+
+	err := toml.NewDecoder(bytes.NewReader(data)).DisallowUnknownFields().Decode(&cfg)
+	var strictErr *toml.StrictMissingError
+	if errors.As(err, &strictErr) {
+		for _, e := range strictErr.Errors {
+			line, col := e.Position()
+			problems = append(problems, ConfigProblem{Severity: severityWarning, Line: line, Column: col, Message: fmt.Sprintf("unknown key %q", strings.Join(e.Key(), "."))})
+		}
+		cfg = Config{}
+		err = toml.Unmarshal(data, &cfg)
+	}
+	if err != nil {
+		problem := ConfigProblem{Severity: severityError, Message: err.Error()}
+		var decodeErr *toml.DecodeError
+		if errors.As(err, &decodeErr) {
+			problem.Line, problem.Column = decodeErr.Position()
+		}
+		return nil, append(problems, problem)
+	}
+
+	if cfg.Version > currentConfigVersion {
+		pos := newConfigLocator(data).key("", -1, "version")
+		msg := fmt.Sprintf("config version %d was written by a newer release (this one supports %d)", cfg.Version, currentConfigVersion)
+		return nil, append(problems, ConfigProblem{Severity: severityError, Line: pos[0], Column: pos[1], Message: msg})
+	}
+	return &cfg, problems
+}
+
+// validateConfig runs the semantic checks on a decoded config.
+func validateConfig(cfg *Config, loc configLocator) []ConfigProblem {
+	var problems []ConfigProblem
+	add := func(severity string, pos [2]int, format string, args ...any) {
+		problems = append(problems, ConfigProblem{Severity: severity, Line: pos[0], Column: pos[1], Message: fmt.Sprintf(format, args...)})
+	}
+
+	// Pairs
+	if len(cfg.Pairs) == 0 {
+		add(severityWarning, loc.key("", -1, "pairs"), "no pairs configured")
+	}
+	seenPairs := make(map[string]int)
+	for _, p := range cfg.Pairs {
+		pos := loc.element("pairs", p, seenPairs[p])
+		switch {
+		case !symbolPattern.MatchString(p):
+			add(severityError, pos, "invalid pair %q: use the Binance symbol in upper case, e.g. \"BTCUSDC\"", p)
+		case seenPairs[p] > 0:
+			add(severityWarning, pos, "pair %s is listed more than once", p)
+		}
+		seenPairs[p]++
+	}
+
+	// Alerts
+	seenIDs := make(map[string]bool)
+	for i, a := range cfg.Alerts {
+		if a.Pair == "" {
+			add(severityError, loc.key("alerts", i, ""), "alert %d has no pair", i+1)
+		} else if !symbolPattern.MatchString(a.Pair) {
+			add(severityError, loc.key("alerts", i, "pair"), "alert %d: invalid pair %q", i+1, a.Pair)
+		}
+		if a.Condition != "above" && a.Condition != "below" {
+			add(severityError, loc.key("alerts", i, "condition"), "alert %d: condition %q must be \"above\" or \"below\"", i+1, a.Condition)
+		}
+		if !validTarget(a.Target) {
+			add(severityError, loc.key("alerts", i, "target"), "alert %d: target must be a number greater than zero", i+1)
+		}
+		if a.ID != "" {
+			if seenIDs[a.ID] {
+				add(severityError, loc.key("alerts", i, "id"), "duplicate alert id %q", a.ID)
+			}
+			seenIDs[a.ID] = true
+		}
+	}
+
+	if cfg.PinnedPair != "" && seenPairs[cfg.PinnedPair] == 0 {
+		add(severityWarning, loc.key("", -1, "pinned_pair"), "pinned pair %s is not in pairs", cfg.PinnedPair)
+	}
+	if cfg.StaleAfter != "" {
+		if d, err := time.ParseDuration(cfg.StaleAfter); err != nil || d <= 0 {
+			add(severityError, loc.key("", -1, "stale_after"), "stale_after %q is not a positive duration such as \"5m\"", cfg.StaleAfter)
+		}
+	}
+	if cfg.TitleTemplate != "" {
+		if _, err := template.New("title").Funcs(titleFuncs).Parse(cfg.TitleTemplate); err != nil {
+			add(severityError, loc.key("", -1, "title_template"), "invalid title_template: %v", err)
+		}
+	}
+	for _, pair := range sortedKeys(cfg.Holdings) {
+		if cfg.Holdings[pair] < 0 {
+			add(severityWarning, loc.key("holdings", -1, pair), "negative holdings for %s", pair)
+		}
+	}
+
+	if bf := cfg.Backfill; bf != nil {
+		if _, ok := klineIntervals[bf.Interval]; bf.Interval != "" && !ok {
+			add(severityError, loc.key("backfill", -1, "interval"), "unsupported backfill interval %q", bf.Interval)
+		}
+		if bf.Days < 0 {
+			add(severityError, loc.key("backfill", -1, "days"), "backfill days must not be negative")
+		}
+		for _, date := range [][2]string{{"start", bf.Start}, {"end", bf.End}} {
+			if _, err := time.Parse("2006-01-02", date[1]); date[1] != "" && err != nil {
+				add(severityError, loc.key("backfill", -1, date[0]), "backfill %s %q is not a YYYY-MM-DD date", date[0], date[1])
+			}
+		}
+	}
+	if ic := cfg.Icon; ic != nil {
+		if ic.Style != "" && ic.Style != "sparkline" && ic.Style != "static" {
+			add(severityError, loc.key("icon", -1, "style"), "icon style %q must be \"sparkline\" or \"static\"", ic.Style)
+		}
+		if ic.Theme != "" && ic.Theme != "auto" && ic.Theme != "light" && ic.Theme != "dark" {
+			add(severityError, loc.key("icon", -1, "theme"), "icon theme %q must be \"auto\", \"light\" or \"dark\"", ic.Theme)
+		}
+		if ic.Points < 0 {
+			add(severityError, loc.key("icon", -1, "points"), "icon points must not be negative")
+		}
+	}
+	if ch := cfg.Chart; ch != nil {
+		if ch.Type != "" && ch.Type != "line" && ch.Type != "candles" {
+			add(severityError, loc.key("chart", -1, "type"), "chart type %q must be \"line\" or \"candles\"", ch.Type)
+		}
+		if _, ok := klineIntervals[ch.Interval]; ch.Interval != "" && !ok {
+			add(severityError, loc.key("chart", -1, "interval"), "unsupported chart interval %q", ch.Interval)
+		}
+		if _, err := parseChartRange(ch.Range); ch.Range != "" && err != nil {
+			add(severityError, loc.key("chart", -1, "range"), "invalid chart range %q", ch.Range)
+		}
+		for _, n := range ch.MovingAverages {
+			if n < 2 {
+				add(severityError, loc.key("chart", -1, "moving_averages"), "moving average period %d must be at least 2", n)
+			}
+		}
+	}
+	if f := cfg.Format; f != nil {
+		if f.SignificantDigits < 0 {
+			add(severityError, loc.key("format", -1, "significant_digits"), "significant_digits must not be negative")
+		}
+		for _, pair := range sortedKeys(f.Precision) {
+			if f.Precision[pair] < 0 {
+				add(severityError, loc.key("format.precision", -1, pair), "precision for %s must not be negative", pair)
+			}
+		}
+	}
+	if w := cfg.Watchlist; w != nil {
+		switch {
+		case w.URL == "" && w.Path == "":
+			add(severityError, loc.key("watchlist", -1, ""), "watchlist needs a url or a path")
+		case w.URL != "" && w.Path != "":
+			add(severityError, loc.key("watchlist", -1, "path"), "watchlist url and path cannot both be set")
+		case w.URL != "" && !strings.HasPrefix(w.URL, "http://") && !strings.HasPrefix(w.URL, "https://"):
+			add(severityError, loc.key("watchlist", -1, "url"), "watchlist url %q must start with http:// or https://", w.URL)
+		}
+		if w.Interval != "" {
+			if d, err := time.ParseDuration(w.Interval); err != nil || d < time.Minute {
+				add(severityError, loc.key("watchlist", -1, "interval"), "watchlist interval %q is not a duration of at least 1m", w.Interval)
+			}
+		}
+		if w.GitPull && w.Path == "" {
+			add(severityWarning, loc.key("watchlist", -1, "git_pull"), "git_pull only applies to a watchlist path")
+		}
+	}
+	if a := cfg.API; a != nil && a.Listen != "" {
+		if host, _, err := net.SplitHostPort(a.Listen); err != nil {
+			add(severityError, loc.key("api", -1, "listen"), "api listen %q must be host:port, e.g. %q", a.Listen, defaultAPIListen)
+		} else if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			add(severityWarning, loc.key("api", -1, "listen"), "the api has no authentication; listening on %q makes it reachable from other machines", a.Listen)
+		}
+	}
+	if m := cfg.MQTT; m != nil {
+		if u, err := url.Parse(m.Broker); m.Broker == "" {
+			add(severityError, loc.key("mqtt", -1, "broker"), "mqtt needs a broker, e.g. %q", "tcp://localhost:1883")
+		} else if err != nil || !slices.Contains(mqttBrokerSchemes, u.Scheme) || u.Host == "" {
+			add(severityError, loc.key("mqtt", -1, "broker"), "mqtt broker %q must be a URL like tcp://host:1883 (schemes: %s)", m.Broker, strings.Join(mqttBrokerSchemes, ", "))
+		}
+		if m.QoS < 0 || m.QoS > 2 {
+			add(severityError, loc.key("mqtt", -1, "qos"), "mqtt qos must be 0, 1 or 2, got %d", m.QoS)
+		}
+		if strings.ContainsAny(m.TopicPrefix+m.DiscoveryPrefix, "#+") {
+			add(severityError, loc.key("mqtt", -1, "topic_prefix"), "mqtt topic prefixes cannot contain wildcards (# or +)")
+		}
+		if m.Password != "" && m.Username == "" {
+			add(severityWarning, loc.key("mqtt", -1, "password"), "mqtt password is set without a username")
+		}
+	}
+	if mc := cfg.MarketChart; mc != nil {
+		_, known := chartProviderTemplates[mc.Provider]
+		switch {
+		case mc.Provider == "custom" && mc.URLTemplate == "":
+			add(severityError, loc.key("market_chart", -1, "provider"), "chart provider \"custom\" requires url_template")
+		case mc.Provider != "" && mc.Provider != "custom" && !known:
+			add(severityError, loc.key("market_chart", -1, "provider"), "unknown chart provider %q", mc.Provider)
+		}
+	}
+
+	return problems
+}
+
+// validTarget reports whether target can be used as an alert price: a finite
+// number greater than zero ("inf" and "nan" parse as floats).
+func validTarget(target float64) bool {
+	return target > 0 && !math.IsInf(target, 1)
+}
+
+// sortedKeys returns the keys of m in order, for a stable problem order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// hasConfigErrors reports whether problems contains at least one error.
+func hasConfigErrors(problems []ConfigProblem) bool {
+	for _, p := range problems {
+		if p.Severity == severityError {
+			return true
+		}
+	}
+	return false
+}
+
+// --- Positions ---
+
+// configLocator finds the line and column of keys in a config document
+type configLocator struct {
+	doc    []byte
+	tables []tomlTable
+}
+
+func newConfigLocator(doc []byte) configLocator {
+	tables, err := scanDocument(doc)
+	if err != nil {
+		tables = nil
+	}
+	return configLocator{doc: doc, tables: tables}
+}
+
+// key returns the position of key's value in table (the index-th one for
+// [[table]] arrays, index -1 otherwise); the table header when key is empty
+// or missing; {0, 0} when the table is missing.
+func (l configLocator) key(table string, index int, key string) [2]int {
+	var t tomlTable
+	var ok bool
+	if index >= 0 {
+		t, ok = arrayTable(l.tables, table, index)
+	} else {
+		for _, tt := range l.tables {
+			if tt.Name == table && !tt.Array {
+				t, ok = tt, true
+				break
+			}
+		}
+	}
+	if !ok {
+		return [2]int{}
+	}
+	if e, found := t.entry(key); found && key != "" {
+		return l.position(e.ValueStart)
+	}
+	if table == "" {
+		return [2]int{}
+	}
+	return l.position(skipSpace(l.doc, t.Start))
+}
+
+// element returns the position of the n-th (0-based) occurrence of a string
+// element of a top-level array.
+func (l configLocator) element(key, value string, n int) [2]int {
+	if len(l.tables) == 0 {
+		return [2]int{}
+	}
+	e, ok := l.tables[0].entry(key)
+	if !ok {
+		return [2]int{}
+	}
+	for i := e.ValueStart + 1; i < e.ValueEnd; i++ {
+		switch l.doc[i] {
+		case '#':
+			i = lineEnd(l.doc, i) - 1
+		case '"', '\'':
+			end, err := skipValue(l.doc, i)
+			if err != nil {
+				return l.position(e.ValueStart)
+			}
+			if normalizeKey(string(l.doc[i:end])) == value {
+				if n == 0 {
+					return l.position(i)
+				}
+				n--
+			}
+			i = end - 1
+		}
+	}
+	return l.position(e.ValueStart)
+}
+
+func (l configLocator) position(offset int) [2]int {
+	lineStart := bytes.LastIndexByte(l.doc[:offset], '\n') + 1
+	return [2]int{lineNumber(l.doc, offset), len([]rune(string(l.doc[lineStart:offset]))) + 1}
+}
+
+// --- Problems Menu ---
+
+// setConfigProblems stores the problems of the loaded config, logs them and
+// updates the "Config problems" menu.
+func setConfigProblems(problems []ConfigProblem) {
+	for _, p := range problems {
+		log.Printf("Config %s", p)
+	}
+	configProblemsMutex.Lock()
+	configProblems = problems
+	configProblemsMutex.Unlock()
+
+	updateConfigProblemsMenu()
+}
+
+// updateConfigProblemsMenu shows one entry per config problem below a
+// "Config problems" parent, hiding the section when the config is clean.
+func updateConfigProblemsMenu() {
+	if mConfigProblems == nil {
+		return
+	}
+
+	configProblemsMutex.Lock()
+	problems := configProblems
+	configProblemsMutex.Unlock()
+
+	if len(problems) == 0 {
+		mConfigProblems.Hide()
+		return
+	}
+	mConfigProblems.SetTitle(fmt.Sprintf("⚠ Config problems (%d)", len(problems)))
+	mConfigProblems.Show()
+
+	for i := len(configProblemItems); i < len(problems); i++ {
+		item := mConfigProblems.AddSubMenuItem("", "")
+		configProblemItems = append(configProblemItems, item)
+		go func(it *systray.MenuItem) {
+			for range it.ClickedCh {
+				openConfigInEditor()
+			}
+		}(item)
+	}
+
+	for i, item := range configProblemItems {
+		if i < len(problems) {
+			item.SetTitle(problems[i].String())
+			item.SetTooltip("Edit the config to fix this problem")
+			item.Show()
+		} else {
+			item.Hide()
+		}
+	}
+}
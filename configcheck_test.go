@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCheckConfigDataPositions(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want []string // "line:column severity: message"
+	}{
+		{"valid", `version = 2
+pairs = ["BTCUSDC", "ETHUSDC"]
+pinned_pair = "ETHUSDC"
+
+[[alerts]]
+id = "btc"
+pair = "BTCUSDC"
+target = 100000.0
+condition = "above"
+active = true
+`, nil},
+		{"misspelled condition", `pairs = ["BTCUSDC"]
+
+[[alerts]]
+pair = "BTCUSDC"
+target = 100000.0
+condition = "abve"
+`, []string{`6:13 error: alert 1: condition "abve" must be "above" or "below"`}},
+		{"negative target", `pairs = ["BTCUSDC"]
+
+[[alerts]]
+pair = "BTCUSDC"
+condition = "below"
+
+[[alerts]]
+pair = "BTCUSDC"
+  target   = -5.0
+condition = "below"
+`, []string{
+			"3:1 error: alert 1: target must be a number greater than zero",
+			"9:14 error: alert 2: target must be a number greater than zero",
+		}},
+		{"infinite target", `pairs = ["BTCUSDC"]
+alerts = [
+  { pair = "BTCUSDC", target = inf, condition = "above" },
+]
+`, []string{"0:0 error: alert 1: target must be a number greater than zero"}},
+		{"duplicate ids", `pairs = ["BTCUSDC"]
+
+[[alerts]]
+id = "btc"
+pair = "BTCUSDC"
+target = 1.0
+condition = "above"
+
+[[alerts]]
+id = 'btc'
+pair = "BTCUSDC"
+target = 2.0
+condition = "above"
+`, []string{`10:6 error: duplicate alert id "btc"`}},
+		{"pinned pair not in pairs", `pairs = [
+  "BTCUSDC",
+  "ETHUSDC",
+]
+pinned_pair =   "SOLUSDC" # typo?
+`, []string{"5:17 warning: pinned pair SOLUSDC is not in pairs"}},
+		{"pairs", `pairs = ["BTCUSDC", "eth-usdc", "BTCUSDC"]
+`, []string{
+			`1:21 error: invalid pair "eth-usdc": use the Binance symbol in upper case, e.g. "BTCUSDC"`,
+			"1:33 warning: pair BTCUSDC is listed more than once",
+		}},
+		{"unknown key", `pairs = ["BTCUSDC"]
+pined_pair = "BTCUSDC"
+`, []string{`2:1 warning: unknown key "pined_pair"`}},
+		{"syntax error", `pairs = ["BTCUSDC"
+`, []string{"2:1 error: "}},
+		{"non-ASCII column", `pairs = ["BTCUSDC"]
+title_template = "₿ {{price .Price"
+`, []string{"2:18 error: invalid title_template: "}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, problems := checkConfigData([]byte(tt.doc))
+			var got []string
+			for _, p := range problems {
+				got = append(got, fmt.Sprintf("%d:%d %s: %s", p.Line, p.Column, p.Severity, p.Message))
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("problems:\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+			for i := range got {
+				if !strings.HasPrefix(got[i], tt.want[i]) {
+					t.Errorf("problem %d = %q, want %q", i+1, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCheckConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name, doc string
+		code      int
+		output    []string // Lines of the output, path replaced by "FILE"
+	}{
+		{"ok.toml", "version = 2\npairs = [\"BTCUSDC\"]\n", 0, []string{"FILE: OK (0 warning(s))"}},
+		{"warning.toml", "version = 2\npairs = [\"BTCUSDC\"]\npinned_pair = \"ETHUSDC\"\n", 0, []string{
+			"FILE:3:15: warning: pinned pair ETHUSDC is not in pairs",
+			"FILE: OK (1 warning(s))",
+		}},
+		{"error.toml", "version = 2\npairs = [\"BTCUSDC\"]\n\n[[alerts]]\npair = \"BTCUSDC\"\ntarget = 1.0\ncondition = \"abve\"\n", 1, []string{
+			`FILE:7:13: error: alert 1: condition "abve" must be "above" or "below"`,
+			"1 error(s), 0 warning(s)",
+		}},
+		// Positions refer to the file as written, before the migration
+		{"v1.toml", "# Old config\nPairs = [\"BTCUSDC\"]\n\n[[Alerts]]\npair = \"BTCUSDC\"\ntarget = -1.0\ncondition = \"above\"\n", 1, []string{
+			"FILE: note: config version 1 will be migrated to 2 when the app loads it",
+			"FILE:6:10: error: alert 1: target must be a number greater than zero",
+			"1 error(s), 0 warning(s)",
+		}},
+		{"syntax.toml", "version = 2\npairs = [\"BTCUSDC\"\n", 1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name)
+			if err := os.WriteFile(path, []byte(tt.doc), 0644); err != nil {
+				t.Fatal(err)
+			}
+			var out strings.Builder
+			code := checkConfigFile(&out, path)
+			if code != tt.code {
+				t.Errorf("exit code %d, want %d\n%s", code, tt.code, out.String())
+			}
+			got := strings.Split(strings.TrimSpace(strings.ReplaceAll(out.String(), path, "FILE")), "\n")
+			if tt.output != nil && !slices.Equal(got, tt.output) {
+				t.Errorf("output:\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.output, "\n"))
+			}
+		})
+	}
+
+	var out strings.Builder
+	if code := checkConfigFile(&out, filepath.Join(dir, "missing.toml")); code != 1 {
+		t.Errorf("missing file: exit code %d, want 1", code)
+	}
+}
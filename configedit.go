@@ -0,0 +1,737 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// tomlEntry is the position of a top-level "key = value" in a config document
+type tomlEntry struct {
+	Key        string
+	LineStart  int // Offset of the first character of the line
+	KeyStart   int // Offset of the key as written, including quotes
+	KeyEnd     int // Offset just after the key
+	ValueStart int // Offset of the first character of the value
+	ValueEnd   int // Offset just after the value
+}
+
+// --- Config Document Edits ---
+
+// updateConfigFile applies edit to the raw config file and writes it back.
+// Edits work on the text so comments and formatting of the file are kept; the
+// result is checked to still be a valid config before it is written.
+func updateConfigFile(edit func(doc []byte) ([]byte, error)) error {
+	path, err := getConfigFilePath()
+	if err != nil {
+		return err
+	}
+	doc, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read config file: %w", err)
+	}
+
+	updated, err := editDocument(doc, func(doc []byte) ([]byte, error) {
+		// Edits use the current key names, so bring older files up to date first
+		doc, _, err := migrateConfigData(doc)
+		if err != nil {
+			return nil, err
+		}
+		return edit(doc)
+	})
+	if err != nil {
+		return err
+	}
+	var cfg Config
+	if err := toml.Unmarshal(updated, &cfg); err != nil {
+		return fmt.Errorf("edit would produce an invalid config: %w", err)
+	}
+
+	if err := writeConfigFile(path, updated); err != nil {
+		return fmt.Errorf("could not write config file: %w", err)
+	}
+	return nil
+}
+
+// editDocument applies edit to doc with "\n" line endings, as the edits
+// insert, and restores "\r\n" line endings afterwards when doc uses them.
+func editDocument(doc []byte, edit func(doc []byte) ([]byte, error)) ([]byte, error) {
+	crlf := []byte("\r\n")
+	if !bytes.Contains(doc, crlf) {
+		return edit(doc)
+	}
+	updated, err := edit(bytes.ReplaceAll(doc, crlf, []byte("\n")))
+	if err != nil {
+		return nil, err
+	}
+	return bytes.ReplaceAll(updated, []byte("\n"), crlf), nil
+}
+
+// savePinnedPair writes pinned_pair, removing the key when pair is empty.
+// When the active profile sets pinned_pair, its table is changed instead.
+func savePinnedPair(pair string) error {
+	table := configWriteTable("pinned_pair")
+	return updateConfigFile(func(doc []byte) ([]byte, error) {
+		if pair == "" && table == "" {
+			return deleteTopLevelKey(doc, "pinned_pair")
+		}
+		return setTableValue(doc, table, "pinned_pair", tomlValue(pair))
+	})
+}
+
+// addAlert appends an [[alerts]] table for alert to the config file.
+func addAlert(alert Alert) error {
+	if err := checkAlertsWritable(); err != nil {
+		return err
+	}
+	if table := configWriteTable("alerts"); table != "" {
+		return fmt.Errorf("the active profile sets alerts; add the alert to [%s] by hand", table)
+	}
+	keys := []string{"pair", "target", "condition", "active"}
+	literals := []string{tomlValue(alert.Pair), tomlValue(alert.Target), tomlValue(alert.Condition), tomlValue(alert.Active)}
+	if alert.ID != "" {
+		keys = append([]string{"id"}, keys...)
+		literals = append([]string{tomlValue(alert.ID)}, literals...)
+	}
+	return updateConfigFile(func(doc []byte) ([]byte, error) {
+		return appendArrayTable(doc, "alerts", keys, literals)
+	})
+}
+
+// removeAlert removes the first [[alerts]] table of the config file for which
+// match returns true. Alerts from included files or the shared watchlist
+// cannot be removed.
+func removeAlert(match func(Alert) bool) error {
+	if err := checkAlertsWritable(); err != nil {
+		return err
+	}
+	if table := configWriteTable("alerts"); table != "" {
+		return fmt.Errorf("the active profile sets alerts; remove the alert from [%s] by hand", table)
+	}
+	return updateConfigFile(func(doc []byte) ([]byte, error) {
+		var cfg Config
+		if err := toml.Unmarshal(doc, &cfg); err != nil {
+			return nil, err
+		}
+		for i, a := range cfg.Alerts {
+			if match(a) {
+				return removeArrayTable(doc, "alerts", i)
+			}
+		}
+		return nil, fmt.Errorf("the alert is not defined in the config file")
+	})
+}
+
+// saveAlertStates writes the active flag of each alert to its [[alerts]] table,
+// or its [[profiles.<name>.alerts]] table when the active profile sets the
+// alerts. Alerts from the shared watchlist are read-only and skipped.
+func saveAlertStates(alerts []Alert) error {
+	if err := checkAlertsWritable(); err != nil {
+		return err
+	}
+	name := "alerts"
+	if table := configWriteTable("alerts"); table != "" {
+		name = table + ".alerts"
+	}
+	return updateConfigFile(func(doc []byte) ([]byte, error) {
+		var err error
+		i := 0
+		for _, a := range alerts {
+			if a.Shared {
+				continue
+			}
+			if doc, err = setArrayTableValue(doc, name, i, "active", tomlValue(a.Active)); err != nil {
+				return nil, err
+			}
+			i++
+		}
+		return doc, nil
+	})
+}
+
+// setStringArray sets key in table ("" for the top level) to values. An
+// existing array keeps its single- or multi-line layout and indentation; a
+// missing key is added as described for setTableValue.
+func setStringArray(doc []byte, table, key string, values []string) ([]byte, error) {
+	tables, err := scanDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+	old := ""
+	if t, ok := findTable(tables, table); ok {
+		if e, ok := t.entry(key); ok {
+			old = string(doc[e.ValueStart:e.ValueEnd])
+		}
+	}
+	return setTableValue(doc, table, key, formatStringArray(values, old))
+}
+
+// formatStringArray renders values as a TOML array in the layout of old: one
+// element per line when old spans several lines, inline otherwise. Comments
+// following an element of old stay with that element.
+func formatStringArray(values []string, old string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = tomlQuote(v)
+	}
+
+	if !strings.Contains(old, "\n") || len(values) == 0 {
+		return "[" + strings.Join(quoted, ", ") + "]"
+	}
+
+	comments := elementComments(old)
+	for i, v := range values {
+		if i < len(values)-1 {
+			quoted[i] += ","
+		}
+		if c, ok := comments[v]; ok {
+			quoted[i] += " " + c
+		}
+	}
+
+	indent := "    "
+	lines := strings.Split(old, "\n")
+	if len(lines) > 1 {
+		second := lines[1]
+		if trimmed := strings.TrimLeft(second, " \t"); trimmed != "" && trimmed != "]" {
+			indent = second[:len(second)-len(trimmed)]
+		}
+	}
+	return "[\n" + indent + strings.Join(quoted, "\n"+indent) + "\n]"
+}
+
+// elementComments maps the string elements of a multi-line array to the
+// comment on the rest of their line, e.g. `"BTCUSDC", # main` -> "# main".
+func elementComments(array string) map[string]string {
+	comments := make(map[string]string)
+	doc := []byte(array)
+	for i := 0; i < len(doc); i++ {
+		switch doc[i] {
+		case '#':
+			i = lineEnd(doc, i) - 1
+		case '"', '\'':
+			end, err := skipValue(doc, i)
+			if err != nil {
+				return comments
+			}
+			value := normalizeKey(string(doc[i:end]))
+			rest := doc[end:lineEnd(doc, end)]
+			if k := bytes.IndexByte(rest, '#'); k >= 0 && strings.Trim(string(rest[:k]), " \t,") == "" {
+				comments[value] = strings.TrimRight(string(rest[k:]), "\r\n")
+			}
+			i = end - 1
+		}
+	}
+	return comments
+}
+
+// insertTopLevel adds line after the last top-level entry, or before the
+// first table when the document has no top-level entries.
+func insertTopLevel(doc []byte, root tomlTable, line string) []byte {
+	pos := root.End
+	if len(root.Entries) > 0 {
+		pos = lineEnd(doc, root.Entries[len(root.Entries)-1].ValueEnd)
+	} else if pos < len(doc) {
+		line += "\n"
+	}
+	if pos > 0 && doc[pos-1] != '\n' {
+		line = "\n" + line
+	}
+	return splice(doc, pos, pos, line)
+}
+
+// setTopLevelValue sets the top-level key to a TOML value literal (see
+// tomlValue), replacing only the old value so a trailing comment is kept.
+func setTopLevelValue(doc []byte, key, literal string) ([]byte, error) {
+	tables, err := scanDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+	root := tables[0]
+	if e, ok := root.entry(key); ok {
+		return splice(doc, e.ValueStart, e.ValueEnd, literal), nil
+	}
+	return insertTopLevel(doc, root, key+" = "+literal+"\n"), nil
+}
+
+// setTableValue sets key in [table] to a TOML value literal, adding the key
+// after the table's last entry, or the table at the end of the document,
+// when missing. An empty table name is the top level.
+func setTableValue(doc []byte, table, key, literal string) ([]byte, error) {
+	if table == "" {
+		return setTopLevelValue(doc, key, literal)
+	}
+	tables, err := scanDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+	if t, ok := findTable(tables, table); ok {
+		return setEntry(doc, t, key, literal), nil
+	}
+	var b strings.Builder
+	if len(doc) > 0 && doc[len(doc)-1] != '\n' {
+		b.WriteString("\n")
+	}
+	if len(doc) > 0 {
+		b.WriteString("\n")
+	}
+	b.WriteString("[" + table + "]\n" + key + " = " + literal + "\n")
+	return append(doc, b.String()...), nil
+}
+
+// deleteTopLevelKey removes the line of the top-level key, if present.
+func deleteTopLevelKey(doc []byte, key string) ([]byte, error) {
+	tables, err := scanDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+	if e, ok := tables[0].entry(key); ok {
+		return splice(doc, e.LineStart, lineEnd(doc, e.ValueEnd), ""), nil
+	}
+	return doc, nil
+}
+
+// renameTopLevelKey renames the top-level key from to to, keeping its value.
+func renameTopLevelKey(doc []byte, from, to string) ([]byte, error) {
+	tables, err := scanDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+	if e, ok := tables[0].entry(from); ok {
+		return splice(doc, e.KeyStart, e.KeyEnd, to), nil
+	}
+	return doc, nil
+}
+
+// renameTables renames every [from] or [[from]] table header to to.
+func renameTables(doc []byte, from, to string) ([]byte, error) {
+	tables, err := scanDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+	// Edit from the end so earlier offsets stay valid
+	for i := len(tables) - 1; i > 0; i-- {
+		t := tables[i]
+		if t.Name != from {
+			continue
+		}
+		header := "[" + to + "]"
+		if t.Array {
+			header = "[" + header + "]"
+		}
+		start := skipSpace(doc, t.Start)
+		end := start + bytes.IndexByte(doc[start:], ']') + 1
+		if t.Array {
+			end++
+		}
+		doc = splice(doc, start, end, header)
+	}
+	return doc, nil
+}
+
+// setArrayTableValue sets key in the index-th [[name]] table, adding it after
+// the table's last entry when missing.
+func setArrayTableValue(doc []byte, name string, index int, key, literal string) ([]byte, error) {
+	tables, err := scanDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+	t, ok := arrayTable(tables, name, index)
+	if !ok {
+		return nil, fmt.Errorf("no [[%s]] table number %d", name, index+1)
+	}
+	return setEntry(doc, t, key, literal), nil
+}
+
+// setEntry sets key in table t, adding it after the table's last entry when
+// missing.
+func setEntry(doc []byte, t tomlTable, key, literal string) []byte {
+	if e, ok := t.entry(key); ok {
+		return splice(doc, e.ValueStart, e.ValueEnd, literal)
+	}
+	pos := t.contentEnd(doc)
+	line := t.indent(doc) + key + " = " + literal + "\n"
+	if pos > 0 && doc[pos-1] != '\n' {
+		line = "\n" + line
+	}
+	return splice(doc, pos, pos, line)
+}
+
+// appendArrayTable adds a [[name]] table with the given keys and value
+// literals after the last table of that name, or at the end of the document.
+func appendArrayTable(doc []byte, name string, keys, literals []string) ([]byte, error) {
+	tables, err := scanDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := tables[0].entry(name); ok {
+		return nil, fmt.Errorf("%s is written as an inline array; add the entry by hand", name)
+	}
+
+	pos := len(doc)
+	indent := "  "
+	count := 0
+	for _, t := range tables {
+		if t.Array && t.Name == name {
+			pos = t.contentEnd(doc)
+			indent = t.indent(doc)
+			count++
+		}
+	}
+
+	var b strings.Builder
+	if pos > 0 && doc[pos-1] != '\n' {
+		b.WriteString("\n")
+	}
+	if pos > 0 {
+		b.WriteString("\n")
+	}
+	b.WriteString("[[" + name + "]]\n")
+	for i, k := range keys {
+		b.WriteString(indent + k + " = " + literals[i] + "\n")
+	}
+	if count > 0 && pos < len(doc) && doc[pos] != '\n' {
+		b.WriteString("\n")
+	}
+	return splice(doc, pos, pos, b.String()), nil
+}
+
+// removeArrayTable removes the index-th [[name]] table with its entries.
+// Comments above the header are kept, as they may describe the next table.
+func removeArrayTable(doc []byte, name string, index int) ([]byte, error) {
+	tables, err := scanDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+	t, ok := arrayTable(tables, name, index)
+	if !ok {
+		return nil, fmt.Errorf("no [[%s]] table number %d", name, index+1)
+	}
+	end := t.contentEnd(doc)
+	// Drop one blank line following the table
+	if next := skipSpace(doc, end); next < len(doc) && doc[next] == '\n' {
+		end = next + 1
+	}
+	return splice(doc, t.Start, end, ""), nil
+}
+
+// tomlValue formats v as a TOML value literal.
+func tomlValue(v any) string {
+	switch x := v.(type) {
+	case string:
+		return tomlQuote(x)
+	case bool:
+		return strconv.FormatBool(x)
+	case int:
+		return strconv.Itoa(x)
+	case float64:
+		switch {
+		case math.IsNaN(x):
+			return "nan"
+		case math.IsInf(x, 0):
+			return strings.TrimPrefix(strings.ToLower(strconv.FormatFloat(x, 'f', -1, 64)), "+")
+		}
+		s := strconv.FormatFloat(x, 'f', -1, 64)
+		if !strings.ContainsAny(s, ".eEn") {
+			s += ".0" // Keep floats distinguishable from integers
+		}
+		return s
+	default:
+		return tomlQuote(fmt.Sprint(x))
+	}
+}
+
+// --- TOML Scanning ---
+
+// tomlTable is a table of a config document with the positions of its entries
+type tomlTable struct {
+	Name    string // "" for the root table
+	Array   bool   // Declared as [[Name]]
+	Start   int    // Offset of the header line (0 for the root table)
+	End     int    // Offset of the next header line, or the end of the document
+	Entries []tomlEntry
+}
+
+func (t tomlTable) entry(key string) (tomlEntry, bool) {
+	for _, e := range t.Entries {
+		if e.Key == key {
+			return e, true
+		}
+	}
+	return tomlEntry{}, false
+}
+
+// contentEnd returns the offset after the table's last entry (or header).
+func (t tomlTable) contentEnd(doc []byte) int {
+	if len(t.Entries) > 0 {
+		return lineEnd(doc, t.Entries[len(t.Entries)-1].ValueEnd)
+	}
+	if t.Name == "" {
+		return t.Start
+	}
+	return lineEnd(doc, t.Start)
+}
+
+// indent returns the indentation of the table's first entry.
+func (t tomlTable) indent(doc []byte) string {
+	if len(t.Entries) == 0 {
+		return "  "
+	}
+	e := t.Entries[0]
+	return string(doc[e.LineStart:skipSpace(doc, e.LineStart)])
+}
+
+// findTable returns the [name] table; the root table for "".
+func findTable(tables []tomlTable, name string) (tomlTable, bool) {
+	name = normalizeKey(name)
+	for _, t := range tables {
+		if t.Name == name && !t.Array {
+			return t, true
+		}
+	}
+	return tomlTable{}, false
+}
+
+// arrayTable returns the index-th [[name]] table.
+func arrayTable(tables []tomlTable, name string, index int) (tomlTable, bool) {
+	name = normalizeKey(name)
+	n := 0
+	for _, t := range tables {
+		if t.Array && t.Name == name {
+			if n == index {
+				return t, true
+			}
+			n++
+		}
+	}
+	return tomlTable{}, false
+}
+
+// scanDocument splits doc into its tables; the first one is the root table
+// holding the top-level entries.
+func scanDocument(doc []byte) ([]tomlTable, error) {
+	tables := []tomlTable{{}}
+	i := 0
+	for i < len(doc) {
+		start := i
+		i = skipSpace(doc, i)
+		if i >= len(doc) {
+			break
+		}
+		switch doc[i] {
+		case '\n', '\r':
+			i++
+			continue
+		case '#':
+			i = lineEnd(doc, i)
+			continue
+		case '[':
+			tables[len(tables)-1].End = start
+			t, err := parseTableHeader(doc, i)
+			if err != nil {
+				return nil, err
+			}
+			t.Start = start
+			tables = append(tables, t)
+			i = lineEnd(doc, i)
+			continue
+		}
+
+		eq := bytes.IndexByte(doc[i:], '=')
+		nl := bytes.IndexByte(doc[i:], '\n')
+		if eq < 0 || (nl >= 0 && nl < eq) {
+			return nil, fmt.Errorf("line %d: expected key = value", lineNumber(doc, i))
+		}
+		key := normalizeKey(string(doc[i : i+eq]))
+		valueStart := skipSpace(doc, i+eq+1)
+		valueEnd, err := skipValue(doc, valueStart)
+		if err != nil {
+			return nil, err
+		}
+		cur := &tables[len(tables)-1]
+		keyEnd := i + eq
+		for keyEnd > i && (doc[keyEnd-1] == ' ' || doc[keyEnd-1] == '\t') {
+			keyEnd--
+		}
+		cur.Entries = append(cur.Entries, tomlEntry{Key: key, LineStart: start, KeyStart: i, KeyEnd: keyEnd, ValueStart: valueStart, ValueEnd: valueEnd})
+		i = lineEnd(doc, valueEnd)
+	}
+	tables[len(tables)-1].End = len(doc)
+	return tables, nil
+}
+
+// parseTableHeader parses "[name]" or "[[name]]" at i.
+func parseTableHeader(doc []byte, i int) (tomlTable, error) {
+	line := string(doc[i:lineEnd(doc, i)])
+	t := tomlTable{Array: strings.HasPrefix(line, "[[")}
+	open, closing := "[", "]"
+	if t.Array {
+		open, closing = "[[", "]]"
+	}
+	end := strings.Index(line, closing)
+	if end < 0 {
+		return t, fmt.Errorf("line %d: unterminated table header", lineNumber(doc, i))
+	}
+	t.Name = normalizeKey(line[len(open):end])
+	return t, nil
+}
+
+// skipValue returns the offset just after the TOML value starting at i.
+func skipValue(doc []byte, i int) (int, error) {
+	if i >= len(doc) {
+		return i, fmt.Errorf("line %d: missing value", lineNumber(doc, i))
+	}
+	switch {
+	case bytes.HasPrefix(doc[i:], []byte(`"""`)), bytes.HasPrefix(doc[i:], []byte(`'''`)):
+		delim := doc[i : i+3]
+		j := i + 3
+		for {
+			k := bytes.Index(doc[j:], delim)
+			if k < 0 {
+				return 0, fmt.Errorf("line %d: unterminated multi-line string", lineNumber(doc, i))
+			}
+			j += k
+			if delim[0] == '"' && isEscaped(doc, j) {
+				j++
+				continue
+			}
+			// Up to two quotes may directly precede the closing delimiter
+			j += 3
+			for n := 0; n < 2 && j < len(doc) && doc[j] == delim[0]; n++ {
+				j++
+			}
+			return j, nil
+		}
+	case doc[i] == '"' || doc[i] == '\'':
+		for j := i + 1; j < len(doc); j++ {
+			switch {
+			case doc[j] == '\n':
+				return 0, fmt.Errorf("line %d: unterminated string", lineNumber(doc, i))
+			case doc[j] == doc[i] && (doc[i] == '\'' || !isEscaped(doc, j)):
+				return j + 1, nil
+			}
+		}
+		return 0, fmt.Errorf("line %d: unterminated string", lineNumber(doc, i))
+	case doc[i] == '[' || doc[i] == '{':
+		depth := 0
+		for j := i; j < len(doc); {
+			switch doc[j] {
+			case '[', '{':
+				depth++
+				j++
+			case ']', '}':
+				depth--
+				j++
+				if depth == 0 {
+					return j, nil
+				}
+			case '#':
+				j = lineEnd(doc, j)
+			case '"', '\'':
+				end, err := skipValue(doc, j)
+				if err != nil {
+					return 0, err
+				}
+				j = end
+			default:
+				j++
+			}
+		}
+		return 0, fmt.Errorf("line %d: unterminated array or inline table", lineNumber(doc, i))
+	default:
+		// Numbers, booleans and dates end at a comment or the end of the line
+		j := i
+		for j < len(doc) && doc[j] != '\n' && doc[j] != '#' {
+			j++
+		}
+		for j > i && (doc[j-1] == ' ' || doc[j-1] == '\t' || doc[j-1] == '\r') {
+			j--
+		}
+		return j, nil
+	}
+}
+
+// isEscaped reports whether the character at j is preceded by an odd number
+// of backslashes.
+func isEscaped(doc []byte, j int) bool {
+	n := 0
+	for k := j - 1; k >= 0 && doc[k] == '\\'; k-- {
+		n++
+	}
+	return n%2 == 1
+}
+
+// normalizeKey strips whitespace and quotes from a (possibly dotted) key.
+func normalizeKey(raw string) string {
+	parts := strings.Split(strings.TrimSpace(raw), ".")
+	for i, p := range parts {
+		p = strings.TrimSpace(p)
+		if len(p) >= 2 && (p[0] == '"' || p[0] == '\'') && p[len(p)-1] == p[0] {
+			p = p[1 : len(p)-1]
+		}
+		parts[i] = p
+	}
+	return strings.Join(parts, ".")
+}
+
+// tomlKey returns k as a TOML key, quoted unless it is a bare key.
+func tomlKey(k string) string {
+	if k != "" && strings.Trim(k, "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789_-") == "" {
+		return k
+	}
+	return tomlQuote(k)
+}
+
+// tomlQuote returns s as a TOML basic string.
+func tomlQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u%04X`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func skipSpace(doc []byte, i int) int {
+	for i < len(doc) && (doc[i] == ' ' || doc[i] == '\t') {
+		i++
+	}
+	return i
+}
+
+// lineEnd returns the offset just after the newline ending the line at i.
+func lineEnd(doc []byte, i int) int {
+	if k := bytes.IndexByte(doc[i:], '\n'); k >= 0 {
+		return i + k + 1
+	}
+	return len(doc)
+}
+
+func lineNumber(doc []byte, i int) int {
+	return bytes.Count(doc[:i], []byte("\n")) + 1
+}
+
+func splice(doc []byte, start, end int, s string) []byte {
+	res := make([]byte, 0, len(doc)-(end-start)+len(s))
+	res = append(res, doc[:start]...)
+	res = append(res, s...)
+	return append(res, doc[end:]...)
+}
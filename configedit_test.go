@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// configEdits are applied to testdata/configedit/config.toml and compared
+// with testdata/configedit/<name>.golden.toml ("go test -update" rewrites them).
+var configEdits = []struct {
+	name string
+	edit func(doc []byte) ([]byte, error)
+}{
+	{"set_pairs", func(doc []byte) ([]byte, error) {
+		return setStringArray(doc, "", "pairs", []string{"ETHUSDC", "BTCUSDC", "ADAUSDC"})
+	}},
+	{"set_profile_pairs", func(doc []byte) ([]byte, error) {
+		return setStringArray(doc, "profiles.work", "pairs", []string{"ETHUSDC", "SOLUSDC"})
+	}},
+	{"set_pinned_pair", func(doc []byte) ([]byte, error) {
+		return setTableValue(doc, "", "pinned_pair", tomlValue("ETHUSDC"))
+	}},
+	{"add_top_level_key", func(doc []byte) ([]byte, error) {
+		return setTableValue(doc, "", "stale_after", tomlValue("5m"))
+	}},
+	{"set_profile_pinned_pair", func(doc []byte) ([]byte, error) {
+		return setTableValue(doc, "profiles.work", "pinned_pair", tomlValue("ETHUSDC"))
+	}},
+	{"add_table", func(doc []byte) ([]byte, error) {
+		return setTableValue(doc, "profiles.home", "pinned_pair", tomlValue("SOLUSDC"))
+	}},
+	{"delete_pinned_pair", func(doc []byte) ([]byte, error) {
+		return deleteTopLevelKey(doc, "pinned_pair")
+	}},
+	{"rename_quoted_key", func(doc []byte) ([]byte, error) {
+		return renameTopLevelKey(doc, "title_template", "title")
+	}},
+	{"rename_tables", func(doc []byte) ([]byte, error) {
+		return renameTables(doc, "alerts", "alarms")
+	}},
+	{"append_alert", func(doc []byte) ([]byte, error) {
+		return appendArrayTable(doc, "alerts", []string{"pair", "target", "condition", "active"},
+			[]string{tomlValue("SOLUSDC"), tomlValue(150.0), tomlValue("above"), tomlValue(true)})
+	}},
+	{"remove_first_alert", func(doc []byte) ([]byte, error) {
+		return removeArrayTable(doc, "alerts", 0)
+	}},
+	{"remove_last_alert", func(doc []byte) ([]byte, error) {
+		return removeArrayTable(doc, "alerts", 1)
+	}},
+	{"set_alert_value", func(doc []byte) ([]byte, error) {
+		return setArrayTableValue(doc, "alerts", 1, "active", tomlValue(true))
+	}},
+	{"add_alert_key", func(doc []byte) ([]byte, error) {
+		return setArrayTableValue(doc, "alerts", 1, "id", tomlValue("eth-2500"))
+	}},
+}
+
+func TestConfigEditsGolden(t *testing.T) {
+	input, err := os.ReadFile(filepath.Join("testdata", "configedit", "config.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range configEdits {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.edit(bytes.Clone(input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden := filepath.Join("testdata", "configedit", tt.name+".golden.toml")
+			if *updateGolden {
+				if err := os.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("edited config differs from %s:\n%s", golden, got)
+			}
+			var v map[string]any
+			if err := toml.Unmarshal(got, &v); err != nil {
+				t.Errorf("edited config is not valid TOML: %v", err)
+			}
+
+			// A CRLF document gets the same edit with its line endings kept
+			crlf, err := editDocument(bytes.ReplaceAll(input, []byte("\n"), []byte("\r\n")), tt.edit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := bytes.ReplaceAll(want, []byte("\n"), []byte("\r\n")); !bytes.Equal(crlf, want) {
+				t.Errorf("edited CRLF config:\n%q\nwant\n%q", crlf, want)
+			}
+		})
+	}
+}
+
+// Edits of documents without a trailing newline, and of empty documents.
+func TestConfigEditsWithoutTrailingNewline(t *testing.T) {
+	tests := []struct {
+		name, doc string
+		edit      func(doc []byte) ([]byte, error)
+		want      string
+	}{
+		{"set top-level", `pairs = ["BTCUSDC"]`, func(doc []byte) ([]byte, error) {
+			return setStringArray(doc, "", "pairs", []string{"ETHUSDC"})
+		}, `pairs = ["ETHUSDC"]`},
+		{"add top-level", `pairs = ["BTCUSDC"]`, func(doc []byte) ([]byte, error) {
+			return setTableValue(doc, "", "pinned_pair", tomlValue("BTCUSDC"))
+		}, "pairs = [\"BTCUSDC\"]\npinned_pair = \"BTCUSDC\"\n"},
+		{"add top-level before a table", "[chart]\ntype = \"line\"", func(doc []byte) ([]byte, error) {
+			return setTableValue(doc, "", "pinned_pair", tomlValue("BTCUSDC"))
+		}, "pinned_pair = \"BTCUSDC\"\n\n[chart]\ntype = \"line\""},
+		{"add to table", "[chart]\ntype = \"line\"", func(doc []byte) ([]byte, error) {
+			return setTableValue(doc, "chart", "range", tomlValue("7d"))
+		}, "[chart]\ntype = \"line\"\nrange = \"7d\"\n"},
+		{"add table", `pairs = ["BTCUSDC"]`, func(doc []byte) ([]byte, error) {
+			return setTableValue(doc, "chart", "range", tomlValue("7d"))
+		}, "pairs = [\"BTCUSDC\"]\n\n[chart]\nrange = \"7d\"\n"},
+		{"append array table", "[[alerts]]\npair = \"BTCUSDC\"", func(doc []byte) ([]byte, error) {
+			return appendArrayTable(doc, "alerts", []string{"pair"}, []string{tomlValue("ETHUSDC")})
+		}, "[[alerts]]\npair = \"BTCUSDC\"\n\n[[alerts]]\npair = \"ETHUSDC\"\n"},
+		{"remove last array table", "[[alerts]]\npair = \"BTCUSDC\"\n\n[[alerts]]\npair = \"ETHUSDC\"", func(doc []byte) ([]byte, error) {
+			return removeArrayTable(doc, "alerts", 1)
+		}, "[[alerts]]\npair = \"BTCUSDC\"\n\n"},
+		{"delete last key", "version = 2\npinned_pair = \"BTCUSDC\"", func(doc []byte) ([]byte, error) {
+			return deleteTopLevelKey(doc, "pinned_pair")
+		}, "version = 2\n"},
+		{"empty document", "", func(doc []byte) ([]byte, error) {
+			return setTableValue(doc, "", "pinned_pair", tomlValue("BTCUSDC"))
+		}, "pinned_pair = \"BTCUSDC\"\n"},
+		{"empty document, array table", "", func(doc []byte) ([]byte, error) {
+			return appendArrayTable(doc, "alerts", []string{"pair"}, []string{tomlValue("ETHUSDC")})
+		}, "[[alerts]]\n  pair = \"ETHUSDC\"\n"},
+	}
+	for _, tt := range tests {
+		got, err := tt.edit([]byte(tt.doc))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s:\n%q\nwant\n%q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestConfigEditErrors(t *testing.T) {
+	doc := []byte("alerts = [{ pair = \"BTCUSDC\", target = 1.0, condition = \"above\" }]\n")
+	if _, err := appendArrayTable(doc, "alerts", []string{"pair"}, []string{`"ETHUSDC"`}); err == nil {
+		t.Error("appended [[alerts]] to an inline alerts array")
+	}
+	if _, err := removeArrayTable(doc, "alerts", 0); err == nil {
+		t.Error("removed a table from an inline alerts array")
+	}
+	if _, err := setArrayTableValue([]byte("[[alerts]]\npair = \"BTCUSDC\"\n"), "alerts", 1, "active", "true"); err == nil {
+		t.Error("set a value in a missing [[alerts]] table")
+	}
+}
+
+func TestScanDocument(t *testing.T) {
+	input, err := os.ReadFile(filepath.Join("testdata", "configedit", "config.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		".version = 2",
+		".pairs = [\n    \"BTCUSDC\", # main\n    \"ETHUSDC\",\n    'SOLUSDC',\n]",
+		".pinned_pair = \"BTCUSDC\"",
+		".title_template = '{{.Pair}} # {{price .Price}}'",
+		".format.currency_symbols = false",
+		".holdings = { BTCUSDC = 0.5, \"ETH.USDC\" = 2, nested = { a = [1, 2] } }",
+		".notes = \"\"\"\nmulti-line [not a table]\n= not a key \"quoted\" \\\"\"\"\n\"\"\"",
+		"chart.type = \"candles\"",
+		"chart.moving_averages = [7, 25]",
+		"[alerts].id = \"btc-100k\"",
+		"[alerts].pair = \"BTCUSDC\"",
+		"[alerts].target = 100000.0",
+		"[alerts].condition = \"above\"",
+		"[alerts].active = true",
+		"[alerts].pair = \"ETHUSDC\"",
+		"[alerts].target = 2500.0",
+		"[alerts].condition = \"below\"",
+		"[alerts].active = false",
+		"profiles.work.pairs = [\"ETHUSDC\"]",
+	}
+
+	for _, doc := range [][]byte{input, bytes.ReplaceAll(input, []byte("\n"), []byte("\r\n"))} {
+		tables, err := scanDocument(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, tbl := range tables {
+			name := tbl.Name
+			if tbl.Array {
+				name = "[" + name + "]"
+			}
+			for _, e := range tbl.Entries {
+				value := strings.ReplaceAll(string(doc[e.ValueStart:e.ValueEnd]), "\r\n", "\n")
+				got = append(got, name+"."+e.Key+" = "+value)
+			}
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("entries:\n%q\nwant\n%q", got, want)
+		}
+	}
+}
+
+func TestSkipValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string // The value as skipped, "" for an error
+	}{
+		{`"BTCUSDC" # comment`, `"BTCUSDC"`},
+		{`"a \" # b" # c`, `"a \" # b"`},
+		{`"a\\" # c`, `"a\\"`},
+		{`'C:\path\' # c`, `'C:\path\'`},
+		{"\"\"\"\nline \\\"\"\" x\"\"\"\"\" # c", "\"\"\"\nline \\\"\"\" x\"\"\"\"\""},
+		{"'''a\n'b''''' # c", "'''a\n'b'''''"},
+		{"[\n  \"a]\", # ]\n  'b',\n] # c", "[\n  \"a]\", # ]\n  'b',\n]"},
+		{`{ a = { b = [1, "}"] } } # c`, `{ a = { b = [1, "}"] } }`},
+		{"2500.0   # c", "2500.0"},
+		{"true\r\n", "true"},
+		{"1979-05-27T07:32:00Z\nnext = 1", "1979-05-27T07:32:00Z"},
+		{`"unterminated`, ""},
+		{"\"broken\nstring\"", ""},
+		{`"""open`, ""},
+		{"[1, 2", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		end, err := skipValue([]byte(tt.value), 0)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("skipValue(%q) = %d, want an error", tt.value, end)
+			}
+			continue
+		}
+		if err != nil || tt.value[:end] != tt.want {
+			t.Errorf("skipValue(%q) = %q, %v; want %q", tt.value, tt.value[:max(end, 0)], err, tt.want)
+		}
+	}
+}
+
+func TestTOMLValue(t *testing.T) {
+	tests := []struct {
+		v    any
+		want string
+	}{
+		{"BTC\"USDC\"\n", `"BTC\"USDC\"\n"`},
+		{true, "true"},
+		{3, "3"},
+		{100000.0, "100000.0"},
+		{0.00001234, "0.00001234"},
+		{1e21, "1000000000000000000000.0"},
+		{math.NaN(), "nan"},
+		{math.Inf(1), "inf"},
+		{math.Inf(-1), "-inf"},
+	}
+	for _, tt := range tests {
+		got := tomlValue(tt.v)
+		if got != tt.want {
+			t.Errorf("tomlValue(%v) = %s, want %s", tt.v, got, tt.want)
+		}
+		var v map[string]any
+		if err := toml.Unmarshal([]byte("v = "+got), &v); err != nil {
+			t.Errorf("tomlValue(%v) = %s is not valid TOML: %v", tt.v, got, err)
+		}
+	}
+}
+
+func TestFormatStringArray(t *testing.T) {
+	tests := []struct {
+		values []string
+		old    string
+		want   string
+	}{
+		{[]string{"A", "B"}, "", `["A", "B"]`},
+		{[]string{"A", "B"}, `["X"]`, `["A", "B"]`},
+		{nil, "[\n  \"X\",\n]", `[]`},
+		{[]string{"B", "A"}, "[\n\t\"A\", # first\n\t\"B\"\n]", "[\n\t\"B\",\n\t\"A\" # first\n]"},
+		{[]string{"A"}, "[\n]", "[\n    \"A\"\n]"},
+	}
+	for _, tt := range tests {
+		if got := formatStringArray(tt.values, tt.old); got != tt.want {
+			t.Errorf("formatStringArray(%q, %q) = %q, want %q", tt.values, tt.old, got, tt.want)
+		}
+	}
+}
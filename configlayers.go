@@ -0,0 +1,548 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/getlantern/systray"
+	"github.com/pelletier/go-toml/v2"
+)
+
+const (
+	// Maximum nesting of extends, e.g. personal -> team -> company
+	maxConfigIncludeDepth = 8
+
+	// Prefix of the environment variables overriding scalar settings, e.g.
+	// CRIPTOMENU_STALE_AFTER or CRIPTOMENU_MARKET_CHART_PROVIDER
+	configEnvPrefix = "CRIPTOMENU_"
+)
+
+// configLayers describes how the effective config was put together
+type configLayers struct {
+	Files       []string // Merged files, base files first and the config file last
+	Profile     string   // Active profile, "" for none
+	ProfileKeys []string // Top-level keys set by the active profile
+	Profiles    []string // Names of the defined profiles, sorted
+	Env         []string // Applied CRIPTOMENU_* variables
+	Doc         []byte   // Effective config as TOML
+	AlertsFile  string   // File the alerts come from, "" when none sets them
+
+	// Per profile, the last file setting the profile's alerts
+	profileAlertsFiles map[string]string
+}
+
+// configEnvOverride is a CRIPTOMENU_* variable and the setting it replaces
+type configEnvOverride struct {
+	Var   string
+	Path  []string // Key path, e.g. ["market_chart", "provider"]
+	Value any
+}
+
+var (
+	// Layers of the active config; separate from configMutex because config
+	// writes look up their target table while the config is locked
+	activeLayers      *configLayers
+	activeLayersMutex sync.Mutex
+
+	// "Profile" menu, one item per profile after the "No profile" item
+	mProfiles     *systray.MenuItem
+	profileItems  []*systray.MenuItem
+	profileNames  []string
+	profilesMutex sync.Mutex
+)
+
+// --- Layering ---
+
+// loadConfigLayers builds the effective config of the file at path from its
+// content data: files listed in extends are merged first (recursively),
+// then the file itself, the active profile and the CRIPTOMENU_* variables.
+// Tables are merged key by key; arrays such as pairs and alerts, and all
+// other values, are replaced by the later layer.
+func loadConfigLayers(path string, data []byte) (*Config, *configLayers, []ConfigProblem) {
+	layers := &configLayers{profileAlertsFiles: make(map[string]string)}
+	merged := make(map[string]any)
+	problems, ok := mergeConfigLayer(path, data, "", merged, layers, nil)
+	if !ok {
+		return nil, layers, problems
+	}
+
+	env, envProblems := configEnvOverrides()
+	problems = append(problems, envProblems...)
+
+	// The profile can be chosen from the environment as well
+	for _, o := range env {
+		if o.Path[0] == "profile" {
+			setConfigValue(merged, o.Path, o.Value)
+		}
+	}
+
+	profiles, _ := merged["profiles"].(map[string]any)
+	layers.Profiles = sortedKeys(profiles)
+	for _, name := range layers.Profiles {
+		problems = append(problems, checkProfile(name, profiles[name])...)
+	}
+	if name, _ := merged["profile"].(string); name != "" {
+		if profile, ok := profiles[name].(map[string]any); ok {
+			mergeConfigMaps(merged, profile)
+			layers.Profile = name
+			layers.ProfileKeys = sortedKeys(profile)
+			if file, ok := layers.profileAlertsFiles[name]; ok {
+				layers.AlertsFile = file
+			}
+		} else {
+			problems = append(problems, ConfigProblem{Severity: severityWarning, Message: fmt.Sprintf("profile %q is not defined", name)})
+		}
+	}
+
+	for _, o := range env {
+		setConfigValue(merged, o.Path, o.Value)
+		layers.Env = append(layers.Env, o.Var)
+	}
+
+	delete(merged, "profiles")
+	merged["version"] = currentConfigVersion // Every layer has been migrated
+	doc, err := toml.Marshal(merged)
+	if err != nil {
+		return nil, layers, append(problems, ConfigProblem{Severity: severityError, Message: fmt.Sprintf("could not merge config layers: %v", err)})
+	}
+
+	var cfg Config
+	if err := toml.Unmarshal(doc, &cfg); err != nil {
+		return nil, layers, append(problems, ConfigProblem{Severity: severityError, Message: fmt.Sprintf("invalid effective config: %v", err)})
+	}
+
+	if len(layers.Files) == 1 && layers.Profile == "" && len(layers.Env) == 0 {
+		// Just the file itself: report positions in it
+		layers.Doc = data
+		return &cfg, layers, append(problems, validateConfig(&cfg, newConfigLocator(data))...)
+	}
+
+	// Values may come from any layer, so positions in the merged text would
+	// only be misleading
+	layers.Doc = doc
+	for _, p := range validateConfig(&cfg, newConfigLocator(doc)) {
+		p.Line, p.Column = 0, 0
+		problems = append(problems, p)
+	}
+	return &cfg, layers, problems
+}
+
+// mergeConfigLayer merges the files data extends into merged, then data
+// itself. file is the name reported with problems ("" for the config file);
+// stack holds the files including this one, to detect cycles.
+func mergeConfigLayer(path string, data []byte, file string, merged map[string]any, layers *configLayers, stack []string) ([]ConfigProblem, bool) {
+	cfg, problems := decodeConfigData(data)
+	for i := range problems {
+		problems[i].File = file
+	}
+	if cfg == nil {
+		return problems, false
+	}
+
+	loc := newConfigLocator(data)
+	stack = append(stack, absPath(path))
+	for _, ext := range cfg.Extends {
+		pos := loc.element("extends", ext, 0)
+		fail := func(format string, args ...any) ([]ConfigProblem, bool) {
+			msg := fmt.Sprintf("extends %s: ", ext) + fmt.Sprintf(format, args...)
+			return append(problems, ConfigProblem{Severity: severityError, Line: pos[0], Column: pos[1], Message: msg, File: file}), false
+		}
+
+		base := resolveIncludePath(path, ext)
+		if slices.Contains(stack, base) {
+			return fail("includes itself (%s)", strings.Join(append(stack, base), " -> "))
+		}
+		if len(stack) > maxConfigIncludeDepth {
+			return fail("more than %d levels of extends", maxConfigIncludeDepth)
+		}
+		raw, err := os.ReadFile(base)
+		if err != nil {
+			return fail("%v", err)
+		}
+		rememberConfigContent(base, raw)
+
+		// Shared files are migrated in memory only; they may belong to others
+		baseData, _, err := migrateConfigData(raw)
+		if err != nil {
+			return fail("%v", err)
+		}
+		baseProblems, ok := mergeConfigLayer(base, baseData, base, merged, layers, stack)
+		problems = append(problems, baseProblems...)
+		if !ok {
+			return problems, false
+		}
+	}
+
+	var layer map[string]any
+	if err := toml.Unmarshal(data, &layer); err != nil {
+		return append(problems, ConfigProblem{Severity: severityError, Message: err.Error(), File: file}), false
+	}
+	delete(layer, "extends")
+	mergeConfigMaps(merged, layer)
+	layers.Files = append(layers.Files, path)
+
+	// Arrays are replaced whole, so the alerts come from a single layer
+	if _, ok := layer["alerts"]; ok {
+		layers.AlertsFile = path
+	}
+	profiles, _ := layer["profiles"].(map[string]any)
+	for name, profile := range profiles {
+		if table, ok := profile.(map[string]any); ok && table["alerts"] != nil {
+			layers.profileAlertsFiles[name] = path
+		}
+	}
+	return problems, true
+}
+
+// resolveIncludePath resolves an extends entry: "~/" is the home directory
+// and relative paths are relative to the including file.
+func resolveIncludePath(from, path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, rest)
+		}
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(from), path)
+	}
+	return absPath(path)
+}
+
+// mergeConfigMaps merges src into dst: tables present in both are merged key
+// by key, any other value in src replaces the one in dst.
+func mergeConfigMaps(dst, src map[string]any) {
+	for k, v := range src {
+		if srcTable, ok := v.(map[string]any); ok {
+			if dstTable, ok := dst[k].(map[string]any); ok {
+				mergeConfigMaps(dstTable, srcTable)
+				continue
+			}
+			copied := make(map[string]any, len(srcTable))
+			mergeConfigMaps(copied, srcTable)
+			v = copied
+		}
+		dst[k] = v
+	}
+}
+
+// setConfigValue sets the value at path, creating missing tables.
+func setConfigValue(m map[string]any, path []string, value any) {
+	for _, key := range path[:len(path)-1] {
+		next, ok := m[key].(map[string]any)
+		if !ok {
+			next = make(map[string]any)
+			m[key] = next
+		}
+		m = next
+	}
+	m[path[len(path)-1]] = value
+}
+
+// checkProfile reports unknown keys and invalid values in a profile table.
+func checkProfile(name string, profile any) []ConfigProblem {
+	table, ok := profile.(map[string]any)
+	if !ok {
+		return []ConfigProblem{{Severity: severityError, Message: fmt.Sprintf("profile %q must be a table", name)}}
+	}
+	data, err := toml.Marshal(table)
+	if err != nil {
+		return []ConfigProblem{{Severity: severityError, Message: fmt.Sprintf("profile %q: %v", name, err)}}
+	}
+	_, problems := decodeConfigData(data)
+	for i := range problems {
+		problems[i].Line, problems[i].Column = 0, 0
+		problems[i].Message = fmt.Sprintf("profile %q: %s", name, problems[i].Message)
+	}
+	return problems
+}
+
+// --- Environment Overrides ---
+
+// configEnvOverrides returns the CRIPTOMENU_* variables that are set, one
+// per scalar setting: the key path in upper case joined by underscores.
+func configEnvOverrides() ([]configEnvOverride, []ConfigProblem) {
+	var overrides []configEnvOverride
+	var problems []ConfigProblem
+	for _, key := range configScalarKeys(reflect.TypeOf(Config{}), nil) {
+		name := configEnvPrefix + strings.ToUpper(strings.Join(key.Path, "_"))
+		raw, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		value, err := parseEnvValue(raw, key.Kind)
+		if err != nil {
+			problems = append(problems, ConfigProblem{Severity: severityError, Message: fmt.Sprintf("%s: %v", name, err)})
+			continue
+		}
+		overrides = append(overrides, configEnvOverride{Var: name, Path: key.Path, Value: value})
+	}
+	return overrides, problems
+}
+
+// configScalarKey is a setting holding a single string, number or bool
+type configScalarKey struct {
+	Path []string
+	Kind reflect.Kind
+}
+
+// configScalarKeys lists the scalar settings of the config struct t, in
+// declaration order, descending into tables such as [icon].
+func configScalarKeys(t reflect.Type, prefix []string) []configScalarKey {
+	var keys []configScalarKey
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("toml"), ",")
+		if name == "" || name == "-" || name == "version" {
+			continue
+		}
+		path := append(slices.Clone(prefix), name)
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		switch ft.Kind() {
+		case reflect.Struct:
+			keys = append(keys, configScalarKeys(ft, path)...)
+		case reflect.String, reflect.Bool, reflect.Int, reflect.Float64:
+			keys = append(keys, configScalarKey{Path: path, Kind: ft.Kind()})
+		}
+	}
+	return keys
+}
+
+func parseEnvValue(raw string, kind reflect.Kind) (any, error) {
+	switch kind {
+	case reflect.Bool:
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not true or false", raw)
+		}
+		return v, nil
+	case reflect.Int:
+		v, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer", raw)
+		}
+		return v, nil
+	case reflect.Float64:
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", raw)
+		}
+		return v, nil
+	default:
+		return raw, nil
+	}
+}
+
+// --- Effective Config ---
+
+// setActiveLayers records the layers of the config that was just applied.
+func setActiveLayers(layers *configLayers) {
+	activeLayersMutex.Lock()
+	activeLayers = layers
+	activeLayersMutex.Unlock()
+	updateProfilesMenu()
+}
+
+// configLayerFiles returns the files the active config was merged from.
+func configLayerFiles() []string {
+	activeLayersMutex.Lock()
+	defer activeLayersMutex.Unlock()
+	if activeLayers == nil {
+		return nil
+	}
+	return activeLayers.Files
+}
+
+// configWriteTable returns the table the app writes key to: the active
+// profile's table when the profile sets key, so the change is not hidden by
+// it, and "" (the top level) otherwise.
+func configWriteTable(key string) string {
+	activeLayersMutex.Lock()
+	defer activeLayersMutex.Unlock()
+	if activeLayers == nil || activeLayers.Profile == "" || !slices.Contains(activeLayers.ProfileKeys, key) {
+		return ""
+	}
+	return "profiles." + tomlKey(activeLayers.Profile)
+}
+
+// checkAlertsWritable fails when the alerts of the active config come from
+// an included file, which the app never changes; edits of the config file
+// would replace or miss them.
+func checkAlertsWritable() error {
+	activeLayersMutex.Lock()
+	defer activeLayersMutex.Unlock()
+	if activeLayers == nil || activeLayers.AlertsFile == "" || len(activeLayers.Files) == 0 {
+		return nil
+	}
+	if file := activeLayers.AlertsFile; file != activeLayers.Files[len(activeLayers.Files)-1] {
+		return fmt.Errorf("the alerts are defined in %s, which is only read; change them there", file)
+	}
+	return nil
+}
+
+// effectiveConfigText renders the effective config with a header naming its
+// layers.
+func effectiveConfigText(layers *configLayers) string {
+	var b strings.Builder
+	b.WriteString("# Effective CriptoMenu configuration (generated, changes are not read back)\n")
+	for _, f := range layers.Files {
+		b.WriteString("# File: " + f + "\n")
+	}
+	if layers.Profile != "" {
+		b.WriteString("# Profile: " + layers.Profile + "\n")
+	}
+	for _, v := range layers.Env {
+		b.WriteString("# Environment: " + v + "\n")
+	}
+	b.WriteString("\n")
+	b.Write(layers.Doc)
+	return b.String()
+}
+
+// openEffectiveConfig writes the effective config to the cache directory and
+// opens it in the editor.
+func openEffectiveConfig() {
+	activeLayersMutex.Lock()
+	layers := activeLayers
+	activeLayersMutex.Unlock()
+	if layers == nil {
+		return
+	}
+
+	dir, err := getCacheDir()
+	if err != nil {
+		log.Printf("Error getting cache dir: %v", err)
+		return
+	}
+	path := filepath.Join(dir, "effective-config.toml")
+	if err := os.WriteFile(path, []byte(effectiveConfigText(layers)), 0644); err != nil {
+		log.Printf("Error writing effective config: %v", err)
+		return
+	}
+	openFileInEditor(path)
+}
+
+// --- Profile Menu ---
+
+// addProfilesMenu adds the "Profile" menu.
+func addProfilesMenu() {
+	mProfiles = systray.AddMenuItem("Profile", "Switch between the profiles of the config file")
+	item := mProfiles.AddSubMenuItem("No profile", "Use the settings without a profile")
+	profileItems = append(profileItems, item)
+	go func() {
+		for range item.ClickedCh {
+			selectProfile("")
+		}
+	}()
+	updateProfilesMenu()
+}
+
+// updateProfilesMenu lists the defined profiles, checking the active one.
+// The menu is hidden when the config defines no profiles.
+func updateProfilesMenu() {
+	if mProfiles == nil {
+		return
+	}
+	activeLayersMutex.Lock()
+	var names []string
+	active := ""
+	if activeLayers != nil {
+		names, active = activeLayers.Profiles, activeLayers.Profile
+	}
+	activeLayersMutex.Unlock()
+
+	profilesMutex.Lock()
+	defer profilesMutex.Unlock()
+	profileNames = names
+
+	if len(names) == 0 {
+		mProfiles.Hide()
+		return
+	}
+	mProfiles.Show()
+	if v := os.Getenv(configEnvPrefix + "PROFILE"); v != "" {
+		mProfiles.SetTitle("Profile: " + v)
+		mProfiles.SetTooltip("Set by " + configEnvPrefix + "PROFILE")
+		mProfiles.Disable()
+		return
+	}
+	mProfiles.Enable()
+	if active != "" {
+		mProfiles.SetTitle("Profile: " + active)
+	} else {
+		mProfiles.SetTitle("Profile")
+	}
+
+	for i := len(profileItems); i <= len(names); i++ {
+		item := mProfiles.AddSubMenuItem("", "")
+		profileItems = append(profileItems, item)
+		go func(index int, it *systray.MenuItem) {
+			for range it.ClickedCh {
+				handleProfileClick(index)
+			}
+		}(i, item)
+	}
+
+	if active == "" {
+		profileItems[0].Check()
+	} else {
+		profileItems[0].Uncheck()
+	}
+	for i, item := range profileItems[1:] {
+		if i >= len(names) {
+			item.Hide()
+			continue
+		}
+		item.SetTitle(names[i])
+		item.SetTooltip("Switch to the " + names[i] + " profile")
+		if names[i] == active {
+			item.Check()
+		} else {
+			item.Uncheck()
+		}
+		item.Show()
+	}
+}
+
+// handleProfileClick selects the profile of the index-th menu item (1-based;
+// 0 is "No profile").
+func handleProfileClick(index int) {
+	profilesMutex.Lock()
+	if index < 1 || index > len(profileNames) {
+		profilesMutex.Unlock()
+		return
+	}
+	name := profileNames[index-1]
+	profilesMutex.Unlock()
+
+	selectProfile(name)
+}
+
+// selectProfile writes the profile key of the config file and reloads it.
+func selectProfile(name string) {
+	err := updateConfigFile(func(doc []byte) ([]byte, error) {
+		if name == "" {
+			return deleteTopLevelKey(doc, "profile")
+		}
+		return setTopLevelValue(doc, "profile", tomlValue(name))
+	})
+	if err != nil {
+		log.Printf("Error switching profile: %v", err)
+		showErrorAlert("Profile", fmt.Sprintf("Could not switch the profile.\nError: %v", err))
+		return
+	}
+	log.Printf("Switched to profile %q", name)
+
+	loadAndSetConfig()
+	updatePairsMenu()
+	triggerBackfill()
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMergeConfigMaps(t *testing.T) {
+	dst := map[string]any{
+		"pairs":       []any{"BTCUSDC", "ETHUSDC"},
+		"stale_after": "2m",
+		"icon":        map[string]any{"style": "sparkline", "points": int64(24)},
+	}
+	src := map[string]any{
+		"pairs":  []any{"SOLUSDC"},
+		"icon":   map[string]any{"points": int64(48)},
+		"chart":  map[string]any{"type": "line", "moving_averages": []any{int64(20)}},
+		"format": map[string]any{"precision": map[string]any{"BTCUSDC": int64(0)}},
+	}
+	mergeConfigMaps(dst, src)
+
+	want := map[string]any{
+		"pairs":       []any{"SOLUSDC"}, // Arrays are replaced, not appended
+		"stale_after": "2m",
+		"icon":        map[string]any{"style": "sparkline", "points": int64(48)},
+		"chart":       map[string]any{"type": "line", "moving_averages": []any{int64(20)}},
+		"format":      map[string]any{"precision": map[string]any{"BTCUSDC": int64(0)}},
+	}
+	if !reflect.DeepEqual(dst, want) {
+		t.Errorf("merged = %v\nwant %v", dst, want)
+	}
+
+	// Tables are copied, so later layers do not change the source
+	mergeConfigMaps(dst, map[string]any{"format": map[string]any{"precision": map[string]any{"ETHUSDC": int64(2)}}})
+	if p := src["format"].(map[string]any)["precision"].(map[string]any); len(p) != 1 {
+		t.Errorf("source table changed by a later merge: %v", p)
+	}
+}
+
+func TestConfigEnvOverrides(t *testing.T) {
+	t.Setenv("CRIPTOMENU_STALE_AFTER", "5m")
+	t.Setenv("CRIPTOMENU_ICON_POINTS", "12")
+	t.Setenv("CRIPTOMENU_API_ENABLED", "true")
+	t.Setenv("CRIPTOMENU_FORMAT_CURRENCY_SYMBOLS", "false")
+	t.Setenv("CRIPTOMENU_MARKET_CHART_PROVIDER", "tradingview")
+	t.Setenv("CRIPTOMENU_BACKFILL_DAYS", "a week")
+	t.Setenv("CRIPTOMENU_PAIRS", "BTCUSDC") // Arrays cannot be set
+
+	overrides, problems := configEnvOverrides()
+	got := make(map[string]any)
+	for _, o := range overrides {
+		if o.Var != configEnvPrefix+strings.ToUpper(strings.Join(o.Path, "_")) {
+			t.Errorf("%s sets %v", o.Var, o.Path)
+		}
+		got[strings.Join(o.Path, ".")] = o.Value
+	}
+	want := map[string]any{
+		"stale_after":             "5m",
+		"icon.points":             int64(12),
+		"api.enabled":             true,
+		"format.currency_symbols": false,
+		"market_chart.provider":   "tradingview",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("overrides = %v\nwant %v", got, want)
+	}
+	if len(problems) != 1 || !strings.Contains(problems[0].Message, "CRIPTOMENU_BACKFILL_DAYS") {
+		t.Errorf("problems = %v, want one for CRIPTOMENU_BACKFILL_DAYS", problems)
+	}
+}
+
+// writeTestConfigFiles writes files (name -> content) to a temporary
+// directory and returns its path.
+func writeTestConfigFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+const testLayeredConfig = `version = 2
+extends = ["base.toml"]
+pairs = ["BTCUSDC"]
+profile = "work"
+
+[profiles.work]
+pairs = ["ETHUSDC", "SOLUSDC"]
+stale_after = "5m"
+
+[[profiles.work.alerts]]
+pair = "ETHUSDC"
+target = 2500.0
+condition = "below"
+active = true
+
+[profiles.home]
+pinned_pair = "BTCUSDC"
+`
+
+const testBaseConfig = `version = 2
+pairs = ["ADAUSDC"]
+stale_after = "1m"
+
+[icon]
+points = 48
+
+[[alerts]]
+pair = "BTCUSDC"
+target = 100000.0
+condition = "above"
+active = true
+`
+
+func TestLoadConfigLayersProfiles(t *testing.T) {
+	dir := writeTestConfigFiles(t, map[string]string{"base.toml": testBaseConfig})
+	path := filepath.Join(dir, "config.toml")
+
+	cfg, layers, problems := loadConfigLayers(path, []byte(testLayeredConfig))
+	if cfg == nil || len(problems) != 0 {
+		t.Fatalf("problems: %v", problems)
+	}
+	if !slices.Equal(cfg.Pairs, []string{"ETHUSDC", "SOLUSDC"}) || cfg.StaleAfter != "5m" || cfg.Icon == nil || cfg.Icon.Points != 48 {
+		t.Errorf("work profile: pairs %v, stale_after %q, icon %+v", cfg.Pairs, cfg.StaleAfter, cfg.Icon)
+	}
+	if len(cfg.Alerts) != 1 || cfg.Alerts[0].Pair != "ETHUSDC" || layers.AlertsFile != path {
+		t.Errorf("alerts %+v from %q, want the work profile's of %s", cfg.Alerts, layers.AlertsFile, path)
+	}
+	if layers.Profile != "work" || !slices.Equal(layers.Profiles, []string{"home", "work"}) || !slices.Equal(layers.ProfileKeys, []string{"alerts", "pairs", "stale_after"}) {
+		t.Errorf("layers = %+v", layers)
+	}
+	if !slices.Equal(layers.Files, []string{filepath.Join(dir, "base.toml"), path}) {
+		t.Errorf("files = %v", layers.Files)
+	}
+
+	// The environment selects another profile; alerts then come from the base
+	t.Setenv("CRIPTOMENU_PROFILE", "home")
+	cfg, layers, _ = loadConfigLayers(path, []byte(testLayeredConfig))
+	if layers.Profile != "home" || cfg.PinnedPair != "BTCUSDC" || !slices.Equal(cfg.Pairs, []string{"BTCUSDC"}) || cfg.StaleAfter != "1m" {
+		t.Errorf("home profile: %+v, pairs %v, stale_after %q", layers, cfg.Pairs, cfg.StaleAfter)
+	}
+	if len(cfg.Alerts) != 1 || cfg.Alerts[0].Pair != "BTCUSDC" || layers.AlertsFile != filepath.Join(dir, "base.toml") {
+		t.Errorf("alerts %+v from %q, want the base file's", cfg.Alerts, layers.AlertsFile)
+	}
+
+	t.Setenv("CRIPTOMENU_PROFILE", "travel")
+	_, layers, problems = loadConfigLayers(path, []byte(testLayeredConfig))
+	if layers.Profile != "" || len(problems) != 1 || !strings.Contains(problems[0].Message, `profile "travel" is not defined`) {
+		t.Errorf("undefined profile: %q, %v", layers.Profile, problems)
+	}
+}
+
+// setTestConfigFile makes path the config file and installs its layers as
+// the active ones.
+func setTestConfigFile(t *testing.T, path string) {
+	setTestConfigDir(t) // Backups
+	t.Setenv(configPathEnv, path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	activeLayersMutex.Lock()
+	old := activeLayers
+	activeLayersMutex.Unlock()
+	_, layers, problems := loadConfigLayers(path, data)
+	if hasConfigErrors(problems) {
+		t.Fatalf("problems: %v", problems)
+	}
+	setActiveLayers(layers)
+	t.Cleanup(func() { setActiveLayers(old) })
+}
+
+func TestSaveAlertStatesProfile(t *testing.T) {
+	dir := writeTestConfigFiles(t, map[string]string{"base.toml": testBaseConfig, "config.toml": testLayeredConfig})
+	path := filepath.Join(dir, "config.toml")
+	setTestConfigFile(t, path)
+
+	err := saveAlertStates([]Alert{
+		{Pair: "ETHUSDC", Target: 2500, Condition: "below", Active: false},
+		{Pair: "BTCUSDC", Target: 100000, Condition: "above", Active: true, Shared: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if want := strings.Replace(testLayeredConfig, "active = true", "active = false", 1); string(data) != want {
+		t.Errorf("config after saving:\n%s", data)
+	}
+	if base, _ := os.ReadFile(filepath.Join(dir, "base.toml")); string(base) != testBaseConfig {
+		t.Error("the base file was changed")
+	}
+}
+
+// Alerts of an included file are never written, nor hidden by new ones.
+func TestSaveAlertStatesIncluded(t *testing.T) {
+	dir := writeTestConfigFiles(t, map[string]string{"base.toml": testBaseConfig, "config.toml": "version = 2\nextends = [\"base.toml\"]\n"})
+	path := filepath.Join(dir, "config.toml")
+	setTestConfigFile(t, path)
+
+	if err := saveAlertStates([]Alert{{Pair: "BTCUSDC", Target: 100000, Condition: "above"}}); err == nil || !strings.Contains(err.Error(), "base.toml") {
+		t.Errorf("saveAlertStates: %v, want an error naming base.toml", err)
+	}
+	if err := addAlert(Alert{Pair: "ETHUSDC", Target: 2500, Condition: "below", Active: true}); err == nil {
+		t.Error("added an alert hiding those of base.toml")
+	}
+	if data, _ := os.ReadFile(path); string(data) != "version = 2\nextends = [\"base.toml\"]\n" {
+		t.Errorf("config changed:\n%s", data)
+	}
+}
+
+func TestSaveAlertStatesTopLevel(t *testing.T) {
+	const doc = `version = 2
+pairs = ["BTCUSDC"]
+
+[[alerts]]
+pair = "BTCUSDC"
+target = 100000.0
+condition = "above"
+active = true # until acknowledged
+
+[[alerts]]
+pair = "BTCUSDC"
+target = 80000.0
+condition = "below"
+active = true
+`
+	dir := writeTestConfigFiles(t, map[string]string{"config.toml": doc})
+	path := filepath.Join(dir, "config.toml")
+	setTestConfigFile(t, path)
+
+	err := saveAlertStates([]Alert{
+		{Pair: "BTCUSDC", Target: 100000, Condition: "above", Active: true},
+		{Pair: "BTCUSDC", Target: 80000, Condition: "below", Active: false},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if want := doc[:strings.LastIndex(doc, "true")] + "false\n"; string(data) != want {
+		t.Errorf("config after saving:\n%s", data)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/getlantern/systray"
+)
+
+const (
+	// Name of the config file in the platform config directory
+	configFileName = "config.toml"
+
+	// Name of the config file in the home, working and executable directories
+	legacyConfigFileName = ".criptomenu.toml"
+
+	// Environment variable selecting the config file
+	configPathEnv = "CRIPTOMENU_CONFIG"
+)
+
+// configCandidate is a location checked for the config file
+type configCandidate struct {
+	Source   string // Where the location comes from, e.g. "--config flag"
+	Path     string // Empty when the source is not set
+	Explicit bool   // Chosen by the user: used even when the file does not exist yet
+}
+
+// Config file given with --config; set by main before anything loads the config
+var configPathFlag string
+
+// --- Config Location ---
+
+// configCandidates returns the config locations in resolution order:
+//  1. the --config flag
+//  2. the CRIPTOMENU_CONFIG environment variable
+//  3. config.toml in the platform config directory
+//     ($XDG_CONFIG_HOME/criptomenu or ~/.config/criptomenu on Linux,
+//     ~/Library/Application Support/criptomenu on macOS)
+//  4. .criptomenu.toml in the working directory (for 'go run' and terminal launch)
+//  5. .criptomenu.toml next to the executable or up to 4 parents above it
+//     (covers Contents/MacOS/Bundle/Build/ProjectRoot)
+//  6. ~/.criptomenu.toml, the location used by older releases
+func configCandidates() []configCandidate {
+	candidates := []configCandidate{
+		{Source: "--config flag", Path: absPath(configPathFlag), Explicit: true},
+		{Source: configPathEnv, Path: absPath(os.Getenv(configPathEnv)), Explicit: true},
+	}
+
+	if dir, err := os.UserConfigDir(); err == nil {
+		candidates = append(candidates, configCandidate{Source: "config directory", Path: filepath.Join(dir, "criptomenu", configFileName)})
+	}
+
+	candidates = append(candidates, configCandidate{Source: "working directory", Path: absPath(legacyConfigFileName)})
+
+	if exePath, err := os.Executable(); err == nil {
+		dir := filepath.Dir(exePath)
+		for i := 0; i < 5; i++ {
+			candidates = append(candidates, configCandidate{Source: "executable directory", Path: filepath.Join(dir, legacyConfigFileName)})
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				break // Hit root
+			}
+			dir = parent
+		}
+	}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		candidates = append(candidates, configCandidate{Source: "home directory", Path: filepath.Join(home, legacyConfigFileName)})
+	}
+	return candidates
+}
+
+// getConfigFilePath returns the config file to use: an explicitly chosen
+// path, else the first existing candidate, else the platform config
+// directory, where a new default config is created.
+func getConfigFilePath() (string, error) {
+	path, _, err := resolveConfigPath(configCandidates())
+	return path, err
+}
+
+// resolveConfigPath picks the config file from candidates and returns the
+// index of the chosen candidate (-1 for the fallback).
+func resolveConfigPath(candidates []configCandidate) (string, int, error) {
+	for i, c := range candidates {
+		if c.Path == "" {
+			continue
+		}
+		if c.Explicit || fileExists(c.Path) {
+			return c.Path, i, nil
+		}
+	}
+	for i, c := range candidates {
+		if c.Source == "config directory" {
+			return c.Path, i, nil
+		}
+	}
+	return "", -1, fmt.Errorf("no location found for the config file")
+}
+
+// describeConfigCandidates renders the resolution order, marking the chosen
+// file, e.g. "✓ 3. config directory: /home/me/.config/criptomenu/config.toml".
+func describeConfigCandidates() []string {
+	candidates := configCandidates()
+	_, chosen, _ := resolveConfigPath(candidates)
+
+	lines := make([]string, len(candidates))
+	for i, c := range candidates {
+		state := "missing"
+		switch {
+		case c.Path == "":
+			state = "not set"
+		case i == chosen && !fileExists(c.Path):
+			state = "will be created"
+		case fileExists(c.Path):
+			state = "found"
+		}
+		mark := "   "
+		if i == chosen {
+			mark = "✓ "
+		}
+		path := c.Path
+		if path == "" {
+			path = "-"
+		}
+		lines[i] = fmt.Sprintf("%s%d. %s: %s (%s)", mark, i+1, c.Source, path, state)
+	}
+	return lines
+}
+
+func absPath(path string) string {
+	if path == "" {
+		return ""
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	return abs
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// --- Diagnostics Menu ---
+
+// addConfigLocationMenu adds a "Config Location" submenu listing where the
+// config file is searched, in order, with the chosen file marked.
+func addConfigLocationMenu() {
+	mLocation := systray.AddMenuItem("Config Location", "Where the config file is searched and which one is used")
+	lines := describeConfigCandidates()
+	for _, line := range lines {
+		log.Printf("Config location %s", line)
+	}
+
+	for _, line := range lines {
+		item := mLocation.AddSubMenuItem(line, "")
+		if !strings.HasPrefix(line, "✓") {
+			item.Disable()
+			continue
+		}
+		item.SetTooltip("Open the config file")
+		go func(it *systray.MenuItem) {
+			for range it.ClickedCh {
+				openConfigInEditor()
+			}
+		}(item)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveConfigPath(t *testing.T) {
+	dir := writeTestConfigFiles(t, map[string]string{"found.toml": "", "other.toml": ""})
+	found, other := filepath.Join(dir, "found.toml"), filepath.Join(dir, "other.toml")
+	missing, fallback := filepath.Join(dir, "missing.toml"), filepath.Join(dir, "config", "config.toml")
+
+	tests := []struct {
+		name       string
+		candidates []configCandidate
+		want       int
+	}{
+		{"explicit path not created yet", []configCandidate{
+			{Source: "--config flag", Path: missing, Explicit: true},
+			{Source: "home directory", Path: found},
+		}, 0},
+		{"unset explicit path", []configCandidate{
+			{Source: "--config flag", Explicit: true},
+			{Source: "config directory", Path: fallback},
+			{Source: "working directory", Path: found},
+		}, 2},
+		{"first existing candidate", []configCandidate{
+			{Source: "config directory", Path: fallback},
+			{Source: "working directory", Path: missing},
+			{Source: "executable directory", Path: other},
+			{Source: "home directory", Path: found},
+		}, 2},
+		{"config directory fallback", []configCandidate{
+			{Source: configPathEnv, Explicit: true},
+			{Source: "working directory", Path: missing},
+			{Source: "config directory", Path: fallback},
+		}, 2},
+	}
+	for _, tt := range tests {
+		path, index, err := resolveConfigPath(tt.candidates)
+		if err != nil || index != tt.want || path != tt.candidates[tt.want].Path {
+			t.Errorf("%s: %q (%d), %v, want candidate %d", tt.name, path, index, err, tt.want)
+		}
+	}
+
+	if path, index, err := resolveConfigPath([]configCandidate{{Source: "working directory", Path: missing}}); err == nil || index != -1 {
+		t.Errorf("without a config directory: %q (%d), %v, want an error", path, index, err)
+	}
+}
+
+func TestDescribeConfigCandidates(t *testing.T) {
+	home := setTestConfigDir(t)
+	t.Chdir(t.TempDir())
+	t.Setenv(configPathEnv, "")
+	old := configPathFlag
+	configPathFlag = ""
+	t.Cleanup(func() { configPathFlag = old })
+
+	// line returns the description of the candidate with the given source
+	line := func(lines []string, source string) string {
+		for _, l := range lines {
+			if strings.Contains(l, ". "+source+": ") {
+				return l
+			}
+		}
+		t.Fatalf("no %s in %q", source, lines)
+		return ""
+	}
+
+	configDir, _ := os.UserConfigDir()
+	defaultPath := filepath.Join(configDir, "criptomenu", configFileName)
+	lines := describeConfigCandidates()
+	if got, want := line(lines, "--config flag"), "   1. --config flag: - (not set)"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := line(lines, "config directory"), "✓ 3. config directory: "+defaultPath+" (will be created)"; got != want {
+		t.Errorf("without a config file: %q, want %q", got, want)
+	}
+
+	// An existing file further down the list is chosen instead
+	legacy := filepath.Join(home, legacyConfigFileName)
+	if err := os.WriteFile(legacy, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	lines = describeConfigCandidates()
+	if got := line(lines, "home directory"); !strings.HasPrefix(got, "✓ ") || !strings.HasSuffix(got, legacy+" (found)") {
+		t.Errorf("with %s: %q", legacy, got)
+	}
+	if got := line(lines, "config directory"); !strings.HasPrefix(got, "   ") || !strings.HasSuffix(got, " (missing)") {
+		t.Errorf("with %s: %q", legacy, got)
+	}
+
+	// An explicit path is used even before it exists
+	explicit := filepath.Join(home, "new.toml")
+	t.Setenv(configPathEnv, explicit)
+	lines = describeConfigCandidates()
+	if got, want := line(lines, configPathEnv), "✓ 2. "+configPathEnv+": "+explicit+" (will be created)"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := line(lines, "home directory"); strings.HasPrefix(got, "✓ ") {
+		t.Errorf("two chosen files: %q", got)
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/getlantern/systray"
+)
+
+const (
+	// Quiet period after the last file event before the config is reloaded;
+	// editors often write, truncate and rename in quick succession
+	configReloadDebounce = 300 * time.Millisecond
+
+	// Interval of the fallback watcher used when file events are unavailable
+	configPollInterval = 2 * time.Second
+)
+
+var (
+	// Hash of each config file's content last loaded or written by the app,
+	// so our own writes and no-op saves do not trigger a reload
+	configContentHashes = make(map[string][sha256.Size]byte)
+	configContentMutex  sync.Mutex
+
+	// Menu item showing the result of the last reload
+	mConfigStatus *systray.MenuItem
+)
+
+// --- Config Watcher ---
+
+// watchConfig reloads the config when its file, or a file it extends,
+// changes. It watches the directories rather than the files, so saves that
+// replace a file (write to a temporary file, then rename) are seen as well.
+func watchConfig() {
+	configPath, err := getConfigFilePath()
+	if err != nil {
+		log.Printf("Error getting config path for watcher: %v", err)
+		return
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err == nil {
+		err = watcher.Add(filepath.Dir(configPath))
+	}
+	if err != nil {
+		log.Printf("Error watching config directory, polling instead: %v", err)
+		if watcher != nil {
+			watcher.Close()
+		}
+		pollConfig(configPath)
+		return
+	}
+	defer watcher.Close()
+
+	runConfigWatcher(watcher, configPath, reloadConfigIfChanged)
+}
+
+// runConfigWatcher calls reload with the path of a changed config file once
+// its events have been quiet for configReloadDebounce. It returns when the
+// watcher is closed.
+func runConfigWatcher(watcher *fsnotify.Watcher, configPath string, reload func(path string)) {
+	// Directories of the config and included files and of their symlink
+	// targets, added as they are discovered
+	watchedDirs := map[string]bool{filepath.Dir(configPath): true}
+	targets := configWatchTargets(configPath)
+	watchDirs := func() {
+		for name := range targets {
+			if dir := filepath.Dir(name); !watchedDirs[dir] {
+				if err := watcher.Add(dir); err != nil {
+					log.Printf("Error watching %s: %v", dir, err)
+				}
+				watchedDirs[dir] = true
+			}
+		}
+	}
+	watchDirs()
+
+	debounce := time.NewTimer(configReloadDebounce)
+	debounce.Stop()
+	changed := ""
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			path, isConfig := targets[filepath.Clean(event.Name)]
+			if event.Op == fsnotify.Chmod || !isConfig {
+				continue
+			}
+			changed = path
+			debounce.Reset(configReloadDebounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Config watcher error: %v", err)
+		case <-debounce.C:
+			reload(changed)
+			// Includes and symlink targets may have changed with the reload
+			targets = configWatchTargets(configPath)
+			watchDirs()
+		}
+	}
+}
+
+// configWatchTargets maps the config file, the files the active config was
+// merged from and the files they link to, to the path the file is read from.
+// Saves through a symlink change the target, not the link, so both are matched.
+func configWatchTargets(configPath string) map[string]string {
+	targets := make(map[string]string)
+	for _, path := range append([]string{configPath}, configLayerFiles()...) {
+		targets[filepath.Clean(path)] = path
+		if resolved, err := filepath.EvalSymlinks(path); err == nil {
+			targets[resolved] = path
+		}
+	}
+	return targets
+}
+
+// pollConfig is the fallback watcher, checking the files' modification times.
+func pollConfig(configPath string) {
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+
+	lastModTimes := make(map[string]time.Time)
+	for range ticker.C {
+		for _, path := range append([]string{configPath}, configLayerFiles()...) {
+			info, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			if last, ok := lastModTimes[path]; ok && info.ModTime() != last {
+				reloadConfigIfChanged(path)
+			}
+			lastModTimes[path] = info.ModTime()
+		}
+	}
+}
+
+// reloadConfigIfChanged reloads the config unless the content of path is the
+// one the app last loaded or wrote itself.
+func reloadConfigIfChanged(path string) {
+	if !configContentChanged(path) {
+		return
+	}
+	log.Println("Config file changed. Reloading...")
+	reloadConfig()
+}
+
+// configContentChanged reports whether the content of the config file at
+// path differs from the one last recorded by rememberConfigContent. A file
+// that cannot be read (deleted or mid-rename) is not a change; a following
+// event will bring it back.
+func configContentChanged(path string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	configContentMutex.Lock()
+	defer configContentMutex.Unlock()
+	return sha256.Sum256(data) != configContentHashes[path]
+}
+
+// reloadConfig loads the config file again and updates everything that
+// depends on it. It returns false when the file could not be used.
+func reloadConfig() bool {
+	applied := loadAndSetConfig()
+	updatePairsMenu()
+	updateRestoreConfigMenu()
+	triggerBackfill() // Fetch history for newly added pairs
+	setConfigReloadStatus(applied, time.Now())
+	recordConfigReload(applied)
+	return applied
+}
+
+// rememberConfigContent records data as the current content of the config
+// file at path.
+func rememberConfigContent(path string, data []byte) {
+	configContentMutex.Lock()
+	configContentHashes[path] = sha256.Sum256(data)
+	configContentMutex.Unlock()
+}
+
+// --- Reload Status ---
+
+// addConfigStatusMenu adds the menu item showing the last reload result.
+func addConfigStatusMenu() {
+	mConfigStatus = systray.AddMenuItem("", "Result of the last config reload")
+	mConfigStatus.Hide()
+	go func() {
+		for range mConfigStatus.ClickedCh {
+			openConfigInEditor()
+		}
+	}()
+}
+
+// setConfigReloadStatus shows whether the last reload succeeded, and how
+// many errors and warnings the config has.
+func setConfigReloadStatus(applied bool, at time.Time) {
+	if mConfigStatus == nil {
+		return
+	}
+
+	configProblemsMutex.Lock()
+	errs, warnings := 0, 0
+	for _, p := range configProblems {
+		if p.Severity == severityError {
+			errs++
+		} else {
+			warnings++
+		}
+	}
+	configProblemsMutex.Unlock()
+
+	stamp := at.Format("15:04:05")
+	var title string
+	switch {
+	case !applied:
+		title = fmt.Sprintf("✗ Config reload failed at %s, previous config kept", stamp)
+	case errs > 0:
+		title = fmt.Sprintf("⚠ Config reloaded at %s with %d error(s)", stamp, errs)
+	case warnings > 0:
+		title = fmt.Sprintf("✓ Config reloaded at %s (%d warning(s))", stamp, warnings)
+	default:
+		title = fmt.Sprintf("✓ Config reloaded at %s", stamp)
+	}
+	mConfigStatus.SetTitle(title)
+	mConfigStatus.Show()
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// startTestConfigWatcher runs the config watcher on configPath, waits until
+// it watches dirs directories and returns the channel receiving the reloaded
+// paths.
+func startTestConfigWatcher(t *testing.T, configPath string, dirs int) <-chan string {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.Skipf("file events not available: %v", err)
+	}
+	if err := watcher.Add(filepath.Dir(configPath)); err != nil {
+		t.Fatal(err)
+	}
+	reloads := make(chan string, 10)
+	done := make(chan struct{})
+	go func() {
+		runConfigWatcher(watcher, configPath, func(path string) { reloads <- path })
+		close(done)
+	}()
+	t.Cleanup(func() {
+		watcher.Close()
+		<-done
+	})
+	for deadline := time.Now().Add(time.Second); len(watcher.WatchList()) < dirs; {
+		if time.Now().After(deadline) {
+			t.Fatalf("watching %q, want %d directories", watcher.WatchList(), dirs)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return reloads
+}
+
+// expectReloads waits for the debounce to settle and returns the reloads.
+func expectReloads(reloads <-chan string) []string {
+	var got []string
+	timeout := time.After(4 * configReloadDebounce)
+	for {
+		select {
+		case path := <-reloads:
+			got = append(got, path)
+			timeout = time.After(2 * configReloadDebounce)
+		case <-timeout:
+			return got
+		}
+	}
+}
+
+// An editor saving in several steps triggers a single reload.
+func TestConfigWatcherDebounce(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	if err := os.WriteFile(path, []byte("pairs = []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	reloads := startTestConfigWatcher(t, path, 1)
+
+	for i := range 5 {
+		if err := os.WriteFile(path, fmt.Appendf(nil, "pairs = [] # save %d\n", i), 0644); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(configReloadDebounce / 10)
+	}
+	// Replaced via rename, as many editors do
+	tmp := filepath.Join(dir, ".config.toml.swp")
+	os.WriteFile(tmp, []byte("pairs = [\"BTCUSDC\"]\n"), 0644)
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+	if got := expectReloads(reloads); len(got) != 1 || got[0] != path {
+		t.Errorf("reloads = %q, want one of %s", got, path)
+	}
+
+	// Other files in the directory are ignored
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("x"), 0644)
+	if got := expectReloads(reloads); len(got) != 0 {
+		t.Errorf("reloads for another file: %q", got)
+	}
+}
+
+// Saving the file a symlinked config points to reloads the config, and the
+// app's own writes through the link are not taken as changes.
+func TestConfigWatcherSymlink(t *testing.T) {
+	setTestConfigDir(t)
+	dir := t.TempDir()
+	target := filepath.Join(dir, "dotfiles", "criptomenu.toml")
+	link := filepath.Join(dir, "config", "config.toml")
+	for _, d := range []string{filepath.Dir(target), filepath.Dir(link)} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(target, []byte("pairs = []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	t.Cleanup(func() {
+		configContentMutex.Lock()
+		delete(configContentHashes, link)
+		configContentMutex.Unlock()
+	})
+	reloads := startTestConfigWatcher(t, link, 2)
+
+	if err := os.WriteFile(target, []byte("pairs = [\"BTCUSDC\"]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := expectReloads(reloads); len(got) != 1 || got[0] != link {
+		t.Fatalf("reloads = %q, want one of %s", got, link)
+	}
+	if !configContentChanged(link) {
+		t.Error("an edit of the link target is not a change")
+	}
+
+	if err := writeConfigFile(link, []byte("pairs = [\"ETHUSDC\"]\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got := expectReloads(reloads); len(got) != 1 || configContentChanged(got[0]) {
+		t.Errorf("own write: reloads = %q, changed %v; want an event without a change", got, len(got) > 0 && configContentChanged(got[0]))
+	}
+}
+
+func TestConfigContentChanged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	t.Cleanup(func() {
+		configContentMutex.Lock()
+		delete(configContentHashes, path)
+		configContentMutex.Unlock()
+	})
+
+	data := []byte("pairs = [\"BTCUSDC\"]\n")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !configContentChanged(path) {
+		t.Error("a file never loaded is not a change")
+	}
+	rememberConfigContent(path, data)
+	if configContentChanged(path) {
+		t.Error("the remembered content is a change")
+	}
+	os.WriteFile(path, []byte("pairs = [\"ETHUSDC\"]\n"), 0644)
+	if !configContentChanged(path) {
+		t.Error("an edit is not a change")
+	}
+	os.Remove(path)
+	if configContentChanged(path) {
+		t.Error("a missing file is a change")
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Name of the control socket in its per-user directory
+	controlSocketName = "criptomenu.sock"
+
+	// Limit for a control request line
+	maxControlRequestSize = 4096
+)
+
+var (
+	// Listener of the control socket while this process serves it
+	controlListener net.Listener
+	controlMutex    sync.Mutex
+)
+
+// --- Control Socket ---
+
+// controlSocketPath returns the per-user path of the control socket, in a
+// directory other users cannot enter (see controlSocketDir).
+func controlSocketPath() (string, error) {
+	dir, err := controlSocketDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, controlSocketName), nil
+}
+
+// startControlServer listens on the control socket so "criptomenu ctl" and
+// scripts can drive this process. A socket left by a crashed process is
+// replaced; one served by a running process is left alone.
+func startControlServer() {
+	path, err := controlSocketPath()
+	if err != nil {
+		log.Printf("Error locating control socket: %v", err)
+		return
+	}
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		log.Printf("Control socket %s is served by another process; not starting it.", path)
+		return
+	}
+	os.Remove(path) // Stale socket
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		log.Printf("Error starting control socket: %v", err)
+		return
+	}
+	// Any process that can connect controls the app, so other users must not
+	if err := restrictControlSocket(path); err != nil {
+		log.Printf("Error restricting control socket permissions, not serving it: %v", err)
+		ln.Close()
+		return
+	}
+
+	controlMutex.Lock()
+	controlListener = ln
+	controlMutex.Unlock()
+	log.Printf("Control socket listening on %s", path)
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return // Closed by stopControlServer
+			}
+			go handleControlConn(conn)
+		}
+	}()
+}
+
+// stopControlServer closes the control socket and removes its file.
+func stopControlServer() {
+	controlMutex.Lock()
+	defer controlMutex.Unlock()
+	if controlListener != nil {
+		controlListener.Close() // Also removes the socket file
+		controlListener = nil
+	}
+}
+
+// handleControlConn runs one request: a line with a command and its
+// arguments. The reply is the command's output, one line each, followed by
+// "OK" or "ERR <message>".
+func handleControlConn(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	line, err := bufio.NewReader(io.LimitReader(conn, maxControlRequestSize)).ReadString('\n')
+	if err != nil && line == "" {
+		return
+	}
+	args := strings.Fields(line)
+	if len(args) == 0 {
+		fmt.Fprintln(conn, "ERR empty command")
+		return
+	}
+
+	log.Printf("Control command: %s", strings.Join(args, " "))
+	output, err := runControlCommand(args)
+	for _, l := range output {
+		fmt.Fprintln(conn, l)
+	}
+	if err != nil {
+		fmt.Fprintln(conn, "ERR "+err.Error())
+		return
+	}
+	fmt.Fprintln(conn, "OK")
+}
+
+// runControlCommand runs a control command in this process.
+func runControlCommand(args []string) ([]string, error) {
+	cmd, args := args[0], args[1:]
+	switch {
+	case cmd == "select" && len(args) == 1:
+		pair, err := configuredPair(args[0])
+		if err != nil {
+			return nil, err
+		}
+		selectPair(pair)
+		return nil, nil
+
+	case cmd == "pin" && len(args) <= 1:
+		pair := getPair()
+		if len(args) == 1 {
+			var err error
+			if pair, err = configuredPair(args[0]); err != nil {
+				return nil, err
+			}
+		}
+		if pair == "" {
+			return nil, errors.New("no pair to pin")
+		}
+		return []string{"Pinned " + pair}, setPinnedPair(pair)
+
+	case cmd == "unpin" && len(args) == 0:
+		return nil, setPinnedPair("")
+
+	case cmd == "refresh" && len(args) == 0:
+		select {
+		case updateChan <- struct{}{}:
+		default:
+			// Channel full, update already pending
+		}
+		return nil, nil
+
+	case cmd == "reload-config" && len(args) == 0:
+		if !reloadConfig() {
+			return nil, errors.New("the config file has errors; the previous configuration stays active")
+		}
+		return nil, nil
+
+	case cmd == "status" && len(args) == 0:
+		return controlStatus(), nil
+
+	case slices.Contains(controlCommands, cmd):
+		return nil, fmt.Errorf("wrong arguments for %s", cmd)
+	default:
+		return nil, fmt.Errorf("unknown command %q", cmd)
+	}
+}
+
+// Commands understood by the control socket
+var controlCommands = []string{"select", "pin", "unpin", "refresh", "reload-config", "status"}
+
+// configuredPair returns pair in upper case, or an error when it is not one
+// of the configured pairs.
+func configuredPair(pair string) (string, error) {
+	pair = strings.ToUpper(pair)
+	if !slices.Contains(commandPairs(nil), pair) {
+		return "", fmt.Errorf("%s is not a configured pair", pair)
+	}
+	return pair, nil
+}
+
+// controlStatus describes the state of this process for "ctl status".
+func controlStatus() []string {
+	pair := getPair()
+	configMutex.RLock()
+	pairs := strings.Join(activeConfig.Pairs, " ")
+	alerts, active := len(activeConfig.Alerts), 0
+	for _, a := range activeConfig.Alerts {
+		if a.Active {
+			active++
+		}
+	}
+	configMutex.RUnlock()
+
+	shown := pair
+	if isPinned(pair) {
+		shown += " (pinned)"
+	}
+	price := "waiting for first price"
+	if entry, ok := getCachedPrice(pair); ok {
+		price = fmt.Sprintf("%s (%s 24h), updated %s ago", formatPairPrice(pair, entry.Price), formatChange(entry.ChangePercent), formatAge(time.Since(entry.UpdatedAt)))
+		if isStale(entry) {
+			price += staleMarker
+		}
+	}
+	configPath, _ := getConfigFilePath()
+	apiMutex.Lock()
+	api := "disabled"
+	if apiServer != nil {
+		api = "http://" + apiAddr
+	}
+	apiMutex.Unlock()
+	mqttMutex.Lock()
+	broker := "disabled"
+	if mqttClient != nil {
+		broker = mqttCurrent.Broker
+		if !mqttClient.IsConnectionOpen() {
+			broker += " (not connected)"
+		}
+	}
+	mqttMutex.Unlock()
+
+	return []string{
+		"pair: " + shown,
+		"price: " + price,
+		"pairs: " + pairs,
+		fmt.Sprintf("alerts: %d (%d active)", alerts, active),
+		"config: " + configPath,
+		"api: " + api,
+		"mqtt: " + broker,
+		"version: " + CurrentVersion,
+		fmt.Sprintf("pid: %d", os.Getpid()),
+	}
+}
+
+// --- Client ---
+
+// sendControlCommand sends a command to the running instance and returns its
+// output lines. The error tells apart a missing instance (errNotRunning) from
+// a failed command.
+func sendControlCommand(args []string) ([]string, error) {
+	path, err := controlSocketPath()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialTimeout("unix", path, 2*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("%w (no control socket at %s)", errNotRunning, path)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(15 * time.Second))
+
+	if _, err := fmt.Fprintln(conn, strings.Join(args, " ")); err != nil {
+		return nil, err
+	}
+	var output []string
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "OK":
+			return output, nil
+		case strings.HasPrefix(line, "ERR "):
+			return output, errors.New(strings.TrimPrefix(line, "ERR "))
+		}
+		output = append(output, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return output, err
+	}
+	return output, errors.New("connection closed without a reply")
+}
+
+var errNotRunning = errors.New("CriptoMenu is not running")
+
+// runCtlCommand handles "criptomenu ctl COMMAND [ARGS]".
+func runCtlCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "Usage: criptomenu ctl %s\n", strings.Join(controlCommands, "|"))
+		return 2
+	}
+	output, err := sendControlCommand(args)
+	for _, line := range output {
+		fmt.Println(line)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+// setTestRuntimeDir points XDG_RUNTIME_DIR, where the control socket is
+// created, to a new private directory.
+func setTestRuntimeDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Chmod(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("XDG_RUNTIME_DIR", dir)
+}
+
+func TestControlSocket(t *testing.T) {
+	setAPITestState(t)
+	setTestRuntimeDir(t)
+
+	if _, err := sendControlCommand([]string{"status"}); !errors.Is(err, errNotRunning) {
+		t.Fatalf("without a server: err = %v, want errNotRunning", err)
+	}
+
+	startControlServer()
+	defer stopControlServer()
+	path, _ := controlSocketPath()
+	if info, err := os.Stat(path); err != nil {
+		t.Error(err)
+	} else if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("socket mode = %v, want 0600", info.Mode())
+	}
+
+	if _, err := sendControlCommand([]string{"select", "ethusdc"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := getPair(); got != "ETHUSDC" {
+		t.Errorf("displayed pair = %q, want ETHUSDC", got)
+	}
+
+	output, err := sendControlCommand([]string{"status"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(output, "pair: ETHUSDC") {
+		t.Errorf("status = %q", output)
+	}
+
+	for _, args := range [][]string{{"select", "SOLUSDC"}, {"select"}, {"launch"}} {
+		if _, err := sendControlCommand(args); err == nil || errors.Is(err, errNotRunning) {
+			t.Errorf("%q: err = %v, want a command error", args, err)
+		}
+	}
+}
+
+func TestControlSocketDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the socket is in the user's cache directory")
+	}
+	setTestCacheDir(t)
+	t.Setenv("XDG_RUNTIME_DIR", "")
+
+	dir, err := controlSocketDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(dir); err != nil || info.Mode().Perm() != 0700 || filepath.Base(dir) != "run" {
+		t.Errorf("fallback directory %s: %v, %v", dir, info.Mode(), err)
+	}
+	os.Chmod(dir, 0755)
+	if _, err := controlSocketDir(); err == nil {
+		t.Error("no error for a fallback directory open to other users")
+	}
+
+	// Other users could connect before the socket mode is set: not served
+	open := t.TempDir()
+	os.Chmod(open, 0755)
+	t.Setenv("XDG_RUNTIME_DIR", open)
+	startControlServer()
+	defer stopControlServer()
+	if _, err := os.Stat(filepath.Join(open, controlSocketName)); !os.IsNotExist(err) {
+		t.Errorf("socket created in a directory open to other users: %v", err)
+	}
+}
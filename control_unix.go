@@ -0,0 +1,57 @@
+//go:build unix
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// controlSocketDir returns the directory of the control socket:
+// $XDG_RUNTIME_DIR when set (Linux), else a "run" directory in the cache
+// directory. The socket is created with the umask and restricted only after
+// it listens, so the directory must keep other users out in the meantime.
+func controlSocketDir() (string, error) {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		cacheDir, err := getCacheDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(cacheDir, "run")
+		if err := os.Mkdir(dir, 0700); err != nil && !os.IsExist(err) {
+			return "", err
+		}
+	}
+	if err := checkPrivateDir(dir); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// checkPrivateDir fails unless dir is a directory of the current user that
+// other users cannot access.
+func checkPrivateDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok && int(st.Uid) != os.Getuid() {
+		return fmt.Errorf("%s is owned by another user", dir)
+	}
+	if perm := info.Mode().Perm(); perm&0077 != 0 {
+		return fmt.Errorf("%s is accessible to other users (mode %04o)", dir, perm)
+	}
+	return nil
+}
+
+// restrictControlSocket lets only the current user connect to the control
+// socket.
+func restrictControlSocket(path string) error {
+	return os.Chmod(path, 0600)
+}
@@ -0,0 +1,38 @@
+//go:build windows
+
+package main
+
+import "golang.org/x/sys/windows"
+
+// controlSocketDir returns the directory of the control socket: the cache
+// directory, under the user's local AppData, which other users cannot open.
+func controlSocketDir() (string, error) {
+	return getCacheDir()
+}
+
+// restrictControlSocket lets only the current user connect to the control
+// socket. File modes do not apply on Windows, so the socket file gets a
+// protected ACL granting access to the user alone; connecting needs write
+// access to it.
+func restrictControlSocket(path string) error {
+	user, err := windows.GetCurrentProcessToken().GetTokenUser()
+	if err != nil {
+		return err
+	}
+	acl, err := windows.ACLFromEntries([]windows.EXPLICIT_ACCESS{{
+		AccessPermissions: windows.GENERIC_ALL,
+		AccessMode:        windows.GRANT_ACCESS,
+		Inheritance:       windows.NO_INHERITANCE,
+		Trustee: windows.TRUSTEE{
+			TrusteeForm:  windows.TRUSTEE_IS_SID,
+			TrusteeType:  windows.TRUSTEE_IS_USER,
+			TrusteeValue: windows.TrusteeValueFromSID(user.User.Sid),
+		},
+	}}, nil)
+	if err != nil {
+		return err
+	}
+	// Protected: the user's entry replaces those inherited from the directory
+	return windows.SetNamedSecurityInfo(path, windows.SE_FILE_OBJECT,
+		windows.DACL_SECURITY_INFORMATION|windows.PROTECTED_DACL_SECURITY_INFORMATION, nil, nil, acl, nil)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// errDialogUnsupported is returned when no dialog tool is available
+var errDialogUnsupported = errors.New("dialogs are not supported on this platform")
+
+// --- Dialogs ---
+
+// promptText asks the user for a line of text. ok is false when the dialog
+// was cancelled.
+func promptText(title, prompt string) (text string, ok bool, err error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf(`text returned of (display dialog "%s" default answer "" with title "%s")`,
+			appleScriptEscape(prompt), appleScriptEscape(title))
+		cmd = exec.Command("osascript", "-e", script)
+	case "linux":
+		cmd = exec.Command("zenity", "--entry", "--title", title, "--text", prompt)
+	default:
+		return "", false, errDialogUnsupported
+	}
+	return runDialog(cmd)
+}
+
+// chooseFromList lets the user pick one of items. ok is false when the dialog
+// was cancelled.
+func chooseFromList(title, prompt string, items []string) (choice string, ok bool, err error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		quoted := make([]string, len(items))
+		for i, it := range items {
+			quoted[i] = `"` + appleScriptEscape(it) + `"`
+		}
+		script := fmt.Sprintf(`choose from list {%s} with title "%s" with prompt "%s"`,
+			strings.Join(quoted, ", "), appleScriptEscape(title), appleScriptEscape(prompt))
+		cmd = exec.Command("osascript", "-e", script)
+	case "linux":
+		args := []string{"--list", "--title", title, "--text", prompt, "--column", "Pair", "--hide-header"}
+		cmd = exec.Command("zenity", append(args, items...)...)
+	default:
+		return "", false, errDialogUnsupported
+	}
+
+	choice, ok, err = runDialog(cmd)
+	// osascript prints "false" when the list is cancelled
+	if choice == "false" {
+		return "", false, err
+	}
+	return choice, ok, err
+}
+
+// runDialog runs a dialog command and returns its trimmed output. A non-zero
+// exit status means the user cancelled.
+func runDialog(cmd *exec.Cmd) (string, bool, error) {
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	text := strings.TrimSpace(string(out))
+	return text, text != "", nil
+}
+
+func appleScriptEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return strings.ReplaceAll(s, `"`, `\"`)
+}
@@ -0,0 +1,51 @@
+package main
+
+import "sync"
+
+// priceListener is called after a price has been fetched and cached
+type priceListener func(pair string, entry PriceEntry)
+
+// alertListener is called when an active alert's condition is met
+type alertListener func(alert Alert, price float64)
+
+var (
+	// The tray, terminal commands and other outputs subscribe here, so the
+	// fetch and alert code runs the same with or without a menu bar
+	priceListeners []priceListener
+	alertListeners []alertListener
+	listenersMutex sync.RWMutex
+)
+
+// --- Listeners ---
+
+func addPriceListener(l priceListener) {
+	listenersMutex.Lock()
+	priceListeners = append(priceListeners, l)
+	listenersMutex.Unlock()
+}
+
+func addAlertListener(l alertListener) {
+	listenersMutex.Lock()
+	alertListeners = append(alertListeners, l)
+	listenersMutex.Unlock()
+}
+
+// notifyPrice passes a fetched price to the price listeners.
+func notifyPrice(pair string, entry PriceEntry) {
+	listenersMutex.RLock()
+	listeners := priceListeners
+	listenersMutex.RUnlock()
+	for _, l := range listeners {
+		l(pair, entry)
+	}
+}
+
+// notifyAlert passes a triggered alert to the alert listeners.
+func notifyAlert(alert Alert, price float64) {
+	listenersMutex.RLock()
+	listeners := alertListeners
+	listenersMutex.RUnlock()
+	for _, l := range listeners {
+		l(alert, price)
+	}
+}
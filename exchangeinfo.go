@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	binance_connector "github.com/binance/binance-connector-go"
+)
+
+const (
+	// How long the cached exchange info is considered fresh
+	exchangeInfoMaxAge = 24 * time.Hour
+)
+
+// SymbolMeta is the subset of /api/v3/exchangeInfo the app uses for a symbol
+type SymbolMeta struct {
+	Symbol     string `json:"symbol"`
+	Status     string `json:"status"` // "TRADING", "BREAK", ...
+	BaseAsset  string `json:"base_asset"`
+	QuoteAsset string `json:"quote_asset"`
+	TickSize   string `json:"tick_size"` // e.g. "0.01000000"
+}
+
+// exchangeInfoCache is the on-disk format of the exchange info cache
+type exchangeInfoCache struct {
+	FetchedAt time.Time             `json:"fetched_at"`
+	Symbols   map[string]SymbolMeta `json:"symbols"`
+}
+
+var (
+	// Symbol metadata, loaded from disk and refreshed from Binance
+	symbolInfo          map[string]SymbolMeta
+	symbolInfoFetchedAt time.Time
+	symbolInfoMutex     sync.RWMutex
+)
+
+// --- Exchange Info ---
+
+// watchExchangeInfo loads the cached exchange info and refreshes it from
+// Binance whenever it is older than exchangeInfoMaxAge.
+func watchExchangeInfo() {
+	if err := loadExchangeInfoCache(); err != nil {
+		log.Printf("Error loading exchange info cache: %v", err)
+	}
+	validateConfiguredPairs()
+
+	client := binance_connector.NewClient("", "", binanceBaseURL)
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		symbolInfoMutex.RLock()
+		age := time.Since(symbolInfoFetchedAt)
+		symbolInfoMutex.RUnlock()
+
+		if age > exchangeInfoMaxAge {
+			if err := refreshExchangeInfo(client); err != nil {
+				log.Printf("Error refreshing exchange info: %v", err)
+			}
+			validateConfiguredPairs()
+		}
+		<-ticker.C
+	}
+}
+
+func getExchangeInfoCachePath() (string, error) {
+	dir, err := getCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "exchangeinfo.json"), nil
+}
+
+func loadExchangeInfoCache() error {
+	path, err := getExchangeInfoCachePath()
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var cache exchangeInfoCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return fmt.Errorf("could not parse exchange info cache: %w", err)
+	}
+	setSymbolInfo(cache.Symbols, cache.FetchedAt)
+	log.Printf("Loaded metadata for %d symbols (fetched %s)", len(cache.Symbols), formatAge(time.Since(cache.FetchedAt)))
+	return nil
+}
+
+// refreshExchangeInfo downloads the symbol list from Binance and updates both
+// the in-memory metadata and the disk cache.
+func refreshExchangeInfo(client *binance_connector.Client) error {
+	res, err := client.NewExchangeInfoService().Do(context.Background())
+	if err != nil {
+		return err
+	}
+
+	symbols := make(map[string]SymbolMeta, len(res.Symbols))
+	for _, s := range res.Symbols {
+		meta := SymbolMeta{
+			Symbol:     s.Symbol,
+			Status:     s.Status,
+			BaseAsset:  s.BaseAsset,
+			QuoteAsset: s.QuoteAsset,
+		}
+		for _, f := range s.Filters {
+			if f.FilterType == "PRICE_FILTER" {
+				meta.TickSize = f.TickSize
+			}
+		}
+		symbols[s.Symbol] = meta
+	}
+
+	now := time.Now()
+	setSymbolInfo(symbols, now)
+	log.Printf("Fetched metadata for %d symbols from Binance", len(symbols))
+
+	path, err := getExchangeInfoCachePath()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(exchangeInfoCache{FetchedAt: now, Symbols: symbols})
+	if err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("could not write exchange info cache: %w", err)
+	}
+	return os.Rename(tmpPath, path)
+}
+
+func setSymbolInfo(symbols map[string]SymbolMeta, fetchedAt time.Time) {
+	symbolInfoMutex.Lock()
+	symbolInfo = symbols
+	symbolInfoFetchedAt = fetchedAt
+	symbolInfoMutex.Unlock()
+}
+
+// getSymbolMeta returns the exchange metadata for symbol, if known.
+func getSymbolMeta(symbol string) (SymbolMeta, bool) {
+	symbolInfoMutex.RLock()
+	defer symbolInfoMutex.RUnlock()
+	meta, ok := symbolInfo[symbol]
+	return meta, ok
+}
+
+// tickDecimals returns the number of decimals of a tick size such as
+// "0.00100000" (3). ok is false when the tick size is missing or invalid.
+func tickDecimals(tickSize string) (int, bool) {
+	tickSize = strings.TrimSpace(tickSize)
+	if tickSize == "" {
+		return 0, false
+	}
+	i := strings.IndexByte(tickSize, '.')
+	if i < 0 {
+		return 0, true
+	}
+	frac := strings.TrimRight(tickSize[i+1:], "0")
+	if frac == "" && strings.Trim(tickSize[:i], "0") == "" {
+		// "0.00000000" is not a usable tick size
+		return 0, false
+	}
+	return len(frac), true
+}
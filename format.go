@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// FormatConfig controls how prices are displayed
+type FormatConfig struct {
+	CurrencySymbols    *bool          `toml:"currency_symbols,omitempty"`    // Default true: "$92,430.00" for USD quoted pairs
+	ThousandsSeparator *string        `toml:"thousands_separator,omitempty"` // Default ","; "" disables grouping
+	SignificantDigits  int            `toml:"significant_digits,omitempty"`  // Limit prices to N significant digits (never beyond the tick size)
+	Precision          map[string]int `toml:"precision,omitempty"`           // Pair -> decimals, overrides the tick size
+}
+
+// resolvedFormat is FormatConfig with defaults applied
+type resolvedFormat struct {
+	CurrencySymbols    bool
+	ThousandsSeparator string
+	SignificantDigits  int
+	Precision          map[string]int
+}
+
+// Currency symbols for fiat and stablecoin quote assets
+var quoteCurrencySymbols = map[string]string{
+	"USD":   "$",
+	"USDT":  "$",
+	"USDC":  "$",
+	"FDUSD": "$",
+	"BUSD":  "$",
+	"TUSD":  "$",
+	"USDP":  "$",
+	"DAI":   "$",
+	"EUR":   "€",
+	"EURI":  "€",
+	"GBP":   "£",
+	"TRY":   "₺",
+	"JPY":   "¥",
+	"BRL":   "R$",
+	"PLN":   "zł",
+	"RUB":   "₽",
+	"UAH":   "₴",
+	"ZAR":   "R",
+	"AUD":   "A$",
+	"MXN":   "MX$",
+	"ARS":   "AR$",
+	"COP":   "COL$",
+	"IDR":   "Rp",
+}
+
+// --- Price Formatting ---
+
+func getFormatConfig() resolvedFormat {
+	res := resolvedFormat{CurrencySymbols: true, ThousandsSeparator: ","}
+
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+	if activeConfig == nil || activeConfig.Format == nil {
+		return res
+	}
+	f := activeConfig.Format
+	if f.CurrencySymbols != nil {
+		res.CurrencySymbols = *f.CurrencySymbols
+	}
+	if f.ThousandsSeparator != nil {
+		res.ThousandsSeparator = *f.ThousandsSeparator
+	}
+	res.SignificantDigits = f.SignificantDigits
+	res.Precision = f.Precision
+	return res
+}
+
+// formatPairPrice formats a price of pair with the precision derived from the
+// symbol's tick size, thousands separators and, for fiat/stablecoin quotes,
+// the currency symbol: BTCUSDC 92430.1 -> "$92,430.10", ETHBTC -> "0.03456".
+func formatPairPrice(pair string, price float64) string {
+	cfg := getFormatConfig()
+	decimals := pricePrecision(pair, price, cfg)
+	return currencyPrefix(pair, cfg) + groupThousands(strconv.FormatFloat(price, 'f', decimals, 64), cfg.ThousandsSeparator)
+}
+
+// formatQuoteValue formats an amount of the quote asset of pair (e.g. the
+// value of holdings): two decimals for fiat quotes, the price precision otherwise.
+func formatQuoteValue(pair string, value float64) string {
+	cfg := getFormatConfig()
+	decimals := 2
+	if _, fiat := quoteCurrencySymbols[quoteAsset(pair)]; !fiat {
+		decimals = pricePrecision(pair, value, cfg)
+	}
+	return currencyPrefix(pair, cfg) + groupThousands(strconv.FormatFloat(value, 'f', decimals, 64), cfg.ThousandsSeparator)
+}
+
+// formatPrice formats a price without symbol metadata.
+func formatPrice(price float64) string {
+	return formatPairPrice("", price)
+}
+
+// pricePrecision returns the number of decimals used to display price.
+func pricePrecision(pair string, price float64, cfg resolvedFormat) int {
+	if d, ok := cfg.Precision[pair]; ok && d >= 0 {
+		return d
+	}
+
+	decimals := -1
+	if meta, ok := getSymbolMeta(pair); ok {
+		if d, ok := tickDecimals(meta.TickSize); ok {
+			decimals = d
+		}
+	}
+
+	if cfg.SignificantDigits > 0 {
+		sig := significantDecimals(price, cfg.SignificantDigits)
+		if decimals < 0 || sig < decimals {
+			decimals = sig
+		}
+	}
+	if decimals >= 0 {
+		return decimals
+	}
+
+	// Unknown symbol: two decimals, or four significant digits for small prices
+	if math.Abs(price) >= 1 || price == 0 {
+		return 2
+	}
+	return significantDecimals(price, 4)
+}
+
+// significantDecimals returns the decimals needed to show digits significant
+// digits of v.
+func significantDecimals(v float64, digits int) int {
+	v = math.Abs(v)
+	if v == 0 {
+		return 0
+	}
+	magnitude := int(math.Floor(math.Log10(v))) + 1
+	d := digits - magnitude
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+func currencyPrefix(pair string, cfg resolvedFormat) string {
+	if !cfg.CurrencySymbols || pair == "" {
+		return ""
+	}
+	return quoteCurrencySymbols[quoteAsset(pair)]
+}
+
+// quoteAsset returns the quote asset of pair from the exchange metadata.
+func quoteAsset(pair string) string {
+	if meta, ok := getSymbolMeta(pair); ok {
+		return meta.QuoteAsset
+	}
+	return ""
+}
+
+// groupThousands inserts sep between groups of three digits of the integer
+// part of a formatted number: "92430.10" -> "92,430.10".
+func groupThousands(s, sep string) string {
+	if sep == "" {
+		return s
+	}
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	intPart, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, frac = s[:i], s[i:]
+	}
+
+	var b strings.Builder
+	b.WriteString(sign)
+	for i, r := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteString(sep)
+		}
+		b.WriteRune(r)
+	}
+	b.WriteString(frac)
+	return b.String()
+}
+
+// --- Number Helpers ---
+
+func formatChange(percent float64) string {
+	return fmt.Sprintf("%+.2f%%", percent)
+}
+
+func formatArrow(percent float64) string {
+	switch {
+	case percent > 0:
+		return "▲"
+	case percent < 0:
+		return "▼"
+	default:
+		return "•"
+	}
+}
+
+// formatCompact abbreviates large numbers: 92430 -> "92.4k", 1250000 -> "1.25M".
+func formatCompact(v float64) string {
+	abs := math.Abs(v)
+	units := []struct {
+		threshold float64
+		suffix    string
+	}{{1e12, "T"}, {1e9, "B"}, {1e6, "M"}, {1e3, "k"}}
+
+	for _, u := range units {
+		if abs >= u.threshold {
+			return trimZeros(strconv.FormatFloat(v/u.threshold, 'f', compactDecimals(abs/u.threshold), 64)) + u.suffix
+		}
+	}
+	return trimZeros(strconv.FormatFloat(v, 'f', compactDecimals(abs), 64))
+}
+
+// compactDecimals keeps about three significant digits.
+func compactDecimals(abs float64) int {
+	return significantDecimals(abs, 3)
+}
+
+func trimZeros(s string) string {
+	if !strings.Contains(s, ".") {
+		return s
+	}
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// formatNumber formats v with the given decimals and the configured
+// thousands separator.
+func formatNumber(v float64, decimals int) string {
+	return groupThousands(strconv.FormatFloat(v, 'f', decimals, 64), getFormatConfig().ThousandsSeparator)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// setTestSymbols installs exchange metadata for the formatting tests.
+func setTestSymbols(t *testing.T) {
+	symbolInfoMutex.RLock()
+	oldInfo, oldFetchedAt := symbolInfo, symbolInfoFetchedAt
+	symbolInfoMutex.RUnlock()
+	setSymbolInfo(map[string]SymbolMeta{
+		"BTCUSDC":  {Symbol: "BTCUSDC", Status: "TRADING", BaseAsset: "BTC", QuoteAsset: "USDC", TickSize: "0.01000000"},
+		"SHIBUSDC": {Symbol: "SHIBUSDC", Status: "TRADING", BaseAsset: "SHIB", QuoteAsset: "USDC", TickSize: "0.00000001"},
+		"ETHBTC":   {Symbol: "ETHBTC", Status: "TRADING", BaseAsset: "ETH", QuoteAsset: "BTC", TickSize: "0.00001000"},
+		"BTCEUR":   {Symbol: "BTCEUR", Status: "TRADING", BaseAsset: "BTC", QuoteAsset: "EUR", TickSize: "0.01000000"},
+		"BTCTRY":   {Symbol: "BTCTRY", Status: "TRADING", BaseAsset: "BTC", QuoteAsset: "TRY", TickSize: "1.00000000"},
+	}, time.Now())
+	t.Cleanup(func() { setSymbolInfo(oldInfo, oldFetchedAt) })
+}
+
+// setTestFormat installs a config with the given [format] table.
+func setTestFormat(t *testing.T, format *FormatConfig) {
+	configMutex.Lock()
+	old := activeConfig
+	activeConfig = &Config{Format: format}
+	configMutex.Unlock()
+	t.Cleanup(func() {
+		configMutex.Lock()
+		activeConfig = old
+		configMutex.Unlock()
+	})
+}
+
+func TestTickDecimals(t *testing.T) {
+	tests := []struct {
+		tickSize string
+		want     int
+		ok       bool
+	}{
+		{"0.01000000", 2, true},
+		{"0.00000001", 8, true},
+		{"0.00001000", 5, true},
+		{"1.00000000", 0, true},
+		{"10", 0, true},
+		{"0.00000000", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		if got, ok := tickDecimals(tt.tickSize); got != tt.want || ok != tt.ok {
+			t.Errorf("tickDecimals(%q) = %d, %v; want %d, %v", tt.tickSize, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestPricePrecision(t *testing.T) {
+	setTestSymbols(t)
+	tests := []struct {
+		pair  string
+		price float64
+		cfg   resolvedFormat
+		want  int
+	}{
+		{"SHIBUSDC", 0.00001234, resolvedFormat{}, 8},
+		{"ETHBTC", 0.03456, resolvedFormat{}, 5},
+		{"BTCUSDC", 92430.1, resolvedFormat{}, 2},
+		// Significant digits never go beyond the tick size
+		{"BTCUSDC", 92430.1, resolvedFormat{SignificantDigits: 3}, 0},
+		{"ETHBTC", 0.03456, resolvedFormat{SignificantDigits: 8}, 5},
+		// Per-pair override wins
+		{"SHIBUSDC", 0.00001234, resolvedFormat{Precision: map[string]int{"SHIBUSDC": 10}}, 10},
+		// Unknown symbol: two decimals, or four significant digits
+		{"NEWUSDC", 12.5, resolvedFormat{}, 2},
+		{"NEWUSDC", 0.001234567, resolvedFormat{}, 6},
+	}
+	for _, tt := range tests {
+		if got := pricePrecision(tt.pair, tt.price, tt.cfg); got != tt.want {
+			t.Errorf("pricePrecision(%s, %v, %+v) = %d, want %d", tt.pair, tt.price, tt.cfg, got, tt.want)
+		}
+	}
+}
+
+func TestFormatPairPrice(t *testing.T) {
+	setTestSymbols(t)
+	off, noSep := false, ""
+	tests := []struct {
+		format *FormatConfig
+		pair   string
+		price  float64
+		want   string
+	}{
+		{nil, "BTCUSDC", 92430.1, "$92,430.10"},
+		{nil, "SHIBUSDC", 0.00001234, "$0.00001234"},
+		{nil, "ETHBTC", 0.03456, "0.03456"},
+		{nil, "BTCEUR", 85123.4, "€85,123.40"},
+		{nil, "BTCTRY", 3412345, "₺3,412,345"},
+		{&FormatConfig{CurrencySymbols: &off}, "BTCUSDC", 92430.1, "92,430.10"},
+		{&FormatConfig{ThousandsSeparator: &noSep}, "BTCUSDC", 92430.1, "$92430.10"},
+		{&FormatConfig{Precision: map[string]int{"BTCUSDC": 0}}, "BTCUSDC", 92430.1, "$92,430"},
+		// Without symbol metadata there is no currency symbol
+		{nil, "", 92430.1, "92,430.10"},
+	}
+	for _, tt := range tests {
+		setTestFormat(t, tt.format)
+		if got := formatPairPrice(tt.pair, tt.price); got != tt.want {
+			t.Errorf("formatPairPrice(%s, %v) with %+v = %q, want %q", tt.pair, tt.price, tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestFormatQuoteValue(t *testing.T) {
+	setTestSymbols(t)
+	setTestFormat(t, nil)
+	if got := formatQuoteValue("SHIBUSDC", 1234.5); got != "$1,234.50" {
+		t.Errorf("fiat quote: %q, want two decimals", got)
+	}
+	if got := formatQuoteValue("ETHBTC", 1.5); got != "1.50000" {
+		t.Errorf("crypto quote: %q, want the price precision", got)
+	}
+}
+
+func TestFormatNumber(t *testing.T) {
+	space, none := " ", ""
+	tests := []struct {
+		format *FormatConfig
+		want   string
+	}{
+		{nil, "1,234,567.9"},
+		{&FormatConfig{ThousandsSeparator: &space}, "1 234 567.9"},
+		{&FormatConfig{ThousandsSeparator: &none}, "1234567.9"},
+	}
+	for _, tt := range tests {
+		setTestFormat(t, tt.format)
+		if got := formatNumber(1234567.89, 1); got != tt.want {
+			t.Errorf("formatNumber with %+v = %q, want %q", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestFormatCompact(t *testing.T) {
+	tests := map[float64]string{
+		92430:    "92.4k",
+		1250000:  "1.25M",
+		999:      "999",
+		0.5:      "0.5",
+		-46215:   "-46.2k",
+		3.21e12:  "3.21T",
+		12345678: "12.3M",
+	}
+	for v, want := range tests {
+		if got := formatCompact(v); got != want {
+			t.Errorf("formatCompact(%v) = %q, want %q", v, got, want)
+		}
+	}
+}
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	binance_connector "github.com/binance/binance-connector-go"
+)
+
+// Default refresh interval of "criptomenu watch"
+const defaultWatchInterval = 10 * time.Second
+
+// --- Headless Setup ---
+
+// startHeadless loads the config and the cached prices and symbol metadata
+// for commands that run without the menu bar.
+func startHeadless() {
+	loadAndSetConfig()
+	if err := loadPriceCache(); err != nil {
+		log.Printf("Error restoring price cache: %v", err)
+	}
+	if err := loadExchangeInfoCache(); err != nil {
+		log.Printf("Error loading exchange info cache: %v", err)
+	}
+}
+
+// commandPairs returns the pairs named on the command line, or the
+// configured pairs when there are none.
+func commandPairs(args []string) []string {
+	if len(args) == 0 {
+		configMutex.RLock()
+		defer configMutex.RUnlock()
+		return append([]string(nil), activeConfig.Pairs...)
+	}
+	pairs := make([]string, len(args))
+	for i, a := range args {
+		pairs[i] = strings.ToUpper(a)
+	}
+	return pairs
+}
+
+// parseInterleaved parses fs from args, allowing flags after positional
+// arguments ("alerts add BTCUSDC above 100000 --id btc"), and returns the
+// positional arguments.
+func parseInterleaved(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// isTerminal reports whether f is an interactive terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// interruptChannel returns a channel receiving SIGINT and SIGTERM.
+func interruptChannel() chan os.Signal {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	return sig
+}
+
+// --- price ---
+
+// runPriceCommand prints the current price and 24h change of each pair.
+func runPriceCommand(args []string) int {
+	startHeadless()
+	client := binance_connector.NewClient("", "", binanceBaseURL)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PAIR\tPRICE\t24H")
+	status := 0
+	for _, pair := range commandPairs(args) {
+		entry, err := fetchTicker(client, pair)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", pair, err)
+			status = 1
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", pair, formatPairPrice(pair, entry.Price), formatChange(entry.ChangePercent))
+	}
+	w.Flush()
+	return status
+}
+
+// --- watch ---
+
+// runWatchCommand redraws a table of prices until interrupted.
+func runWatchCommand(args []string) int {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	interval := fs.Duration("interval", defaultWatchInterval, "refresh interval")
+	pairArgs, err := parseInterleaved(fs, args)
+	if err != nil {
+		return 2
+	}
+	if *interval < time.Second {
+		fmt.Fprintln(os.Stderr, "--interval must be at least 1s")
+		return 2
+	}
+
+	startHeadless()
+	pairs := commandPairs(pairArgs)
+	client := binance_connector.NewClient("", "", binanceBaseURL)
+	clear := isTerminal(os.Stdout)
+	sig := interruptChannel()
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	for {
+		errs := make(map[string]error)
+		for _, pair := range pairs {
+			if _, err := fetchTicker(client, pair); err != nil {
+				errs[pair] = err
+			}
+		}
+		if clear {
+			fmt.Print("\033[H\033[2J") // Cursor home, clear screen
+		}
+		writeWatchTable(os.Stdout, pairs, errs)
+
+		select {
+		case <-ticker.C:
+		case <-sig:
+			return 0
+		}
+	}
+}
+
+// writeWatchTable prints the cached price of each pair, marking stale ones.
+func writeWatchTable(out io.Writer, pairs []string, errs map[string]error) {
+	fmt.Fprintf(out, "CriptoMenu %s, updated %s (Ctrl-C to quit)\n\n", CurrentVersion, time.Now().Format("15:04:05"))
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PAIR\tPRICE\t24H\tUPDATED")
+	for _, pair := range pairs {
+		entry, ok := getCachedPrice(pair)
+		if !ok {
+			fmt.Fprintf(w, "%s\t-\t-\t%v\n", pair, errs[pair])
+			continue
+		}
+		updated := formatAge(time.Since(entry.UpdatedAt)) + " ago"
+		if isStale(entry) {
+			updated += staleMarker
+		}
+		if err := errs[pair]; err != nil {
+			updated += fmt.Sprintf(" (error: %v)", err)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", pair, formatPairPrice(pair, entry.Price), formatChange(entry.ChangePercent), updated)
+	}
+	w.Flush()
+}
+
+// --- alerts ---
+
+func runAlertsCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, cliUsage)
+		return 2
+	}
+	switch args[0] {
+	case "list":
+		return runAlertsList()
+	case "add":
+		return runAlertsAdd(args[1:])
+	case "remove":
+		return runAlertsRemove(args[1:])
+	case "test":
+		return runAlertsTest()
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown alerts command %q\n\n%s", args[0], cliUsage)
+		return 2
+	}
+}
+
+func activeAlerts() []Alert {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+	return append([]Alert(nil), activeConfig.Alerts...)
+}
+
+// alertSource names where an alert is defined, for listings.
+func alertSource(a Alert) string {
+	if a.Shared {
+		return "shared"
+	}
+	return "config"
+}
+
+func runAlertsList() int {
+	startHeadless()
+	alerts := activeAlerts()
+	if len(alerts) == 0 {
+		fmt.Println("No alerts configured.")
+		return 0
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "#\tID\tPAIR\tCONDITION\tTARGET\tACTIVE\tSOURCE")
+	for i, a := range alerts {
+		id := a.ID
+		if id == "" {
+			id = "-"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%t\t%s\n", i+1, id, a.Pair, a.Condition, formatPairPrice(a.Pair, a.Target), a.Active, alertSource(a))
+	}
+	w.Flush()
+	return 0
+}
+
+// runAlertsAdd handles "alerts add PAIR above|below TARGET [--id ID] [--inactive]".
+func runAlertsAdd(args []string) int {
+	fs := flag.NewFlagSet("alerts add", flag.ContinueOnError)
+	id := fs.String("id", "", "identifier of the alert")
+	inactive := fs.Bool("inactive", false, "add the alert disabled")
+	pos, err := parseInterleaved(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(pos) != 3 {
+		fmt.Fprintln(os.Stderr, "Usage: criptomenu alerts add PAIR above|below TARGET [--id ID] [--inactive]")
+		return 2
+	}
+
+	target, err := strconv.ParseFloat(pos[2], 64)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: target %q must be a number greater than zero\n", pos[2])
+		return 2
+	}
+	alert := Alert{ID: *id, Pair: strings.ToUpper(pos[0]), Condition: strings.ToLower(pos[1]), Target: target, Active: !*inactive}
+
+	startHeadless()
+	if err := checkNewAlert(alert); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if err := addAlert(alert); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Added alert: %s %s %s\n", alert.Pair, alert.Condition, formatPairPrice(alert.Pair, alert.Target))
+	return 0
+}
+
+// checkNewAlert validates an alert about to be added to the config.
+func checkNewAlert(alert Alert) error {
+	switch {
+	case !symbolPattern.MatchString(alert.Pair):
+		return fmt.Errorf("invalid pair %q", alert.Pair)
+	case alert.Condition != "above" && alert.Condition != "below":
+		return fmt.Errorf("condition %q must be \"above\" or \"below\"", alert.Condition)
+	case !validTarget(alert.Target):
+		return fmt.Errorf("target must be a number greater than zero")
+	case alert.ID != "" && slices.ContainsFunc(activeAlerts(), func(a Alert) bool { return a.ID == alert.ID }):
+		return fmt.Errorf("an alert with id %q already exists", alert.ID)
+	}
+	return nil
+}
+
+// runAlertsRemove handles "alerts remove ID|NUMBER", NUMBER as shown by
+// "alerts list".
+func runAlertsRemove(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: criptomenu alerts remove ID|NUMBER")
+		return 2
+	}
+	startHeadless()
+
+	alert, err := findAlert(activeAlerts(), args[0])
+	if err == nil && alert.Shared {
+		err = errors.New("the alert comes from the shared watchlist and is read-only")
+	}
+	if err == nil {
+		err = removeAlert(func(a Alert) bool { return a == alert })
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Removed alert: %s %s %s\n", alert.Pair, alert.Condition, formatPairPrice(alert.Pair, alert.Target))
+	return 0
+}
+
+// findAlert returns the alert with the given id, or with the given 1-based
+// number.
+func findAlert(alerts []Alert, ref string) (Alert, error) {
+	for _, a := range alerts {
+		if a.ID != "" && a.ID == ref {
+			return a, nil
+		}
+	}
+	if n, err := strconv.Atoi(ref); err == nil {
+		if n < 1 || n > len(alerts) {
+			return Alert{}, fmt.Errorf("there is no alert number %d", n)
+		}
+		return alerts[n-1], nil
+	}
+	return Alert{}, fmt.Errorf("no alert with id %q", ref)
+}
+
+// runAlertsTest fetches the current prices and shows which alerts would
+// trigger now and how far the others are from their target.
+func runAlertsTest() int {
+	startHeadless()
+	alerts := activeAlerts()
+	if len(alerts) == 0 {
+		fmt.Println("No alerts configured.")
+		return 0
+	}
+
+	client := binance_connector.NewClient("", "", binanceBaseURL)
+	prices := make(map[string]PriceEntry)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "#\tPAIR\tCONDITION\tTARGET\tPRICE\tDISTANCE\tSTATUS")
+	status := 0
+	for i, a := range alerts {
+		entry, ok := prices[a.Pair]
+		if !ok {
+			var err error
+			if entry, err = fetchTicker(client, a.Pair); err != nil {
+				fmt.Fprintf(w, "%d\t%s\t%s\t%s\t-\t-\terror: %v\n", i+1, a.Pair, a.Condition, formatPairPrice(a.Pair, a.Target), err)
+				status = 1
+				continue
+			}
+			prices[a.Pair] = entry
+		}
+
+		state := "waiting"
+		switch {
+		case !a.Active:
+			state = "inactive"
+		case alertTriggered(a, entry.Price):
+			state = "TRIGGERED"
+		}
+		distance := (a.Target - entry.Price) / entry.Price * 100
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", i+1, a.Pair, a.Condition, formatPairPrice(a.Pair, a.Target), formatPairPrice(a.Pair, entry.Price), formatChange(distance), state)
+	}
+	w.Flush()
+	return status
+}
+
+// --- daemon ---
+
+// runDaemon fetches prices and runs the alert engine without the menu bar,
+// printing triggered alerts, until interrupted.
+func runDaemon(args []string) int {
+	fs := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	notify := fs.Bool("notify", false, "also show desktop notifications for alerts")
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 {
+		return 2
+	}
+
+	// The daemon polls and notifies like the app, so only one of them runs
+	if locked, pid, err := acquireInstanceLock(); err != nil {
+		log.Printf("Error taking the instance lock, starting anyway: %v", err)
+	} else if !locked {
+		fmt.Fprintf(os.Stderr, "Error: %s.\n", alreadyRunning(pid))
+		return 1
+	}
+
+	startHeadless()
+	setPair(initialPair())
+	addAlertListener(func(alert Alert, price float64) {
+		fmt.Printf("%s ALERT %s\n", time.Now().Format(time.DateTime), alertMessage(alert, price))
+	})
+	if *notify {
+		addAlertListener(showAlertNotification)
+	}
+
+	go watchConfig()
+	go watchExchangeInfo()
+	go watchWatchlist()
+	go runBackfill()
+	go persistPriceCache()
+	go fetchPrices()
+	startAPI()
+	startMQTT()
+	startControlServer()
+	log.Println("Daemon started.")
+
+	<-interruptChannel()
+	stopControlServer()
+	stopMQTT()
+	if err := savePriceCache(); err != nil {
+		log.Printf("Error saving price cache: %v", err)
+	}
+	log.Println("Daemon exiting.")
+	return 0
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCheckNewAlert(t *testing.T) {
+	setAPITestState(t)
+	tests := []struct {
+		alert Alert
+		ok    bool
+	}{
+		{Alert{Pair: "ETHUSDC", Target: 2500, Condition: "below"}, true},
+		{Alert{ID: "eth", Pair: "ETHUSDC", Target: 0.5, Condition: "above"}, true},
+		{Alert{Pair: "eth-usdc", Target: 2500, Condition: "below"}, false},
+		{Alert{Pair: "ETHUSDC", Target: 2500, Condition: "abve"}, false},
+		{Alert{Pair: "ETHUSDC", Target: 0, Condition: "below"}, false},
+		{Alert{Pair: "ETHUSDC", Target: -1, Condition: "below"}, false},
+		{Alert{Pair: "ETHUSDC", Target: math.Inf(1), Condition: "above"}, false},
+		{Alert{Pair: "ETHUSDC", Target: math.NaN(), Condition: "above"}, false},
+		{Alert{ID: "btc-100k", Pair: "ETHUSDC", Target: 2500, Condition: "below"}, false},
+	}
+	for _, tt := range tests {
+		if err := checkNewAlert(tt.alert); (err == nil) != tt.ok {
+			t.Errorf("checkNewAlert(%+v) = %v, want ok %v", tt.alert, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Candle is one OHLCV sample of a pair's price history
+type Candle struct {
+	Time   time.Time // Open time of the candle
+	Open   float64
+	High   float64
+	Low    float64
+	Close  float64
+	Volume float64
+}
+
+// Bytes read per step when looking for the newest candle at the end of a file
+const historyTailChunk = 4096
+
+var (
+	// Serializes access to the history files
+	historyMutex sync.Mutex
+
+	// Newest candle of each history file by path, so appends do not read the
+	// file again while its size is unchanged
+	historyEnds = make(map[string]historyEnd)
+)
+
+// historyEnd is the open time of the newest candle in a file of size bytes
+type historyEnd struct {
+	Last time.Time
+	Size int64
+}
+
+// --- History Store ---
+//
+// History is stored as one CSV file per pair and interval under
+// <cache dir>/history, e.g. history/BTCUSDC_1h.csv, with one line per candle:
+// open time (unix ms), open, high, low, close, volume. Lines are kept sorted
+// by open time, so appends only need to look at the last stored candle and
+// reads of recent history can seek to the first candle they need.
+
+func getHistoryDir() (string, error) {
+	dir, err := getCacheDir()
+	if err != nil {
+		return "", err
+	}
+	historyDir := filepath.Join(dir, "history")
+	if err := os.MkdirAll(historyDir, 0755); err != nil {
+		return "", err
+	}
+	return historyDir, nil
+}
+
+func getHistoryPath(pair, interval string) (string, error) {
+	dir, err := getHistoryDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, fmt.Sprintf("%s_%s.csv", pair, interval)), nil
+}
+
+// loadHistory returns the stored candles for pair at interval whose open time
+// falls in [from, to]. Zero times leave that side of the range open.
+func loadHistory(pair, interval string, from, to time.Time) ([]Candle, error) {
+	path, err := getHistoryPath(pair, interval)
+	if err != nil {
+		return nil, err
+	}
+
+	historyMutex.Lock()
+	defer historyMutex.Unlock()
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not open history file: %w", err)
+	}
+	defer f.Close()
+
+	if !from.IsZero() {
+		if err := seekHistory(f, from); err != nil {
+			return nil, fmt.Errorf("could not read history file: %w", err)
+		}
+	}
+	candles, err := readHistory(f, filepath.Base(path))
+	if err != nil {
+		return nil, err
+	}
+
+	var res []Candle
+	for _, c := range candles {
+		if !from.IsZero() && c.Time.Before(from) {
+			continue
+		}
+		if !to.IsZero() && c.Time.After(to) {
+			break // Sorted, nothing later is in range
+		}
+		res = append(res, c)
+	}
+	return res, nil
+}
+
+// lastHistoryTime returns the open time of the newest stored candle, or the
+// zero time if nothing is stored yet.
+func lastHistoryTime(pair, interval string) (time.Time, error) {
+	path, err := getHistoryPath(pair, interval)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	historyMutex.Lock()
+	defer historyMutex.Unlock()
+	return lastStoredTime(path)
+}
+
+// lastStoredTime returns the open time of the newest candle in the file at
+// path, reading the end of the file only when it changed since the last
+// call. Called with historyMutex held.
+func lastStoredTime(path string) (time.Time, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		delete(historyEnds, path)
+		return time.Time{}, nil
+	} else if err != nil {
+		return time.Time{}, fmt.Errorf("could not open history file: %w", err)
+	}
+	if end, ok := historyEnds[path]; ok && end.Size == info.Size() {
+		return end.Last, nil
+	}
+	last, err := readLastHistoryTime(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	historyEnds[path] = historyEnd{Last: last, Size: info.Size()}
+	return last, nil
+}
+
+// appendHistory stores candles newer than the last stored one and returns how
+// many were written. Candles must be sorted by open time.
+func appendHistory(pair, interval string, candles []Candle) (int, error) {
+	path, err := getHistoryPath(pair, interval)
+	if err != nil {
+		return 0, err
+	}
+
+	historyMutex.Lock()
+	defer historyMutex.Unlock()
+
+	last, err := lastStoredTime(path)
+	if err != nil {
+		return 0, err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("could not open history file: %w", err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	if torn, err := endsWithoutNewline(f); err != nil {
+		return 0, fmt.Errorf("could not read history file: %w", err)
+	} else if torn {
+		// Finish the line torn by an interrupted write so it stays apart
+		w.WriteString("\n")
+	}
+	written := 0
+	for _, c := range candles {
+		if !c.Time.After(last) {
+			continue
+		}
+		fmt.Fprintf(w, "%d,%s,%s,%s,%s,%s\n", c.Time.UnixMilli(),
+			formatHistoryFloat(c.Open), formatHistoryFloat(c.High), formatHistoryFloat(c.Low),
+			formatHistoryFloat(c.Close), formatHistoryFloat(c.Volume))
+		last = c.Time
+		written++
+	}
+	if err := w.Flush(); err != nil {
+		return written, fmt.Errorf("could not write history file: %w", err)
+	}
+	if info, err := f.Stat(); err == nil {
+		historyEnds[path] = historyEnd{Last: last, Size: info.Size()}
+	}
+	return written, nil
+}
+
+// readHistory parses history lines from r, named name in log messages.
+func readHistory(r io.Reader, name string) ([]Candle, error) {
+	var candles []Candle
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		c, err := parseHistoryLine(line)
+		if err != nil {
+			// A torn line (e.g. crash mid-write) is skipped rather than failing the whole file
+			log.Printf("Skipping invalid history line %s:%d: %v", name, lineNum, err)
+			continue
+		}
+		candles = append(candles, c)
+	}
+	return candles, scanner.Err()
+}
+
+// readLastHistoryTime returns the open time of the last valid line of the
+// file at path, reading it backwards from the end.
+func readLastHistoryTime(path string) (time.Time, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, fmt.Errorf("could not open history file: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var tail []byte
+	for end := info.Size(); end > 0; {
+		start := max(end-historyTailChunk, 0)
+		chunk := make([]byte, end-start)
+		if _, err := f.ReadAt(chunk, start); err != nil {
+			return time.Time{}, fmt.Errorf("could not read history file: %w", err)
+		}
+		tail = append(chunk, tail...)
+		end = start
+
+		lines := strings.Split(string(tail), "\n")
+		if start > 0 {
+			lines = lines[1:] // May start mid-line
+		}
+		for i := len(lines) - 1; i >= 0; i-- {
+			if c, err := parseHistoryLine(strings.TrimSpace(lines[i])); err == nil {
+				return c.Time, nil
+			}
+		}
+	}
+	return time.Time{}, nil
+}
+
+// seekHistory moves f to the first valid line with an open time not before
+// from, by binary search over the sorted lines.
+func seekHistory(f *os.File, from time.Time) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+
+	// The answer is the smallest line start in [lo, hi) meeting from, else best
+	best, lo, hi := size, int64(0), size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		start, next, c, err := historyLineAt(f, size, mid)
+		if err != nil {
+			return err
+		}
+		switch {
+		case start >= hi:
+			hi = mid // No line starts in [mid, hi)
+		case !c.Time.Before(from):
+			best, hi = start, mid
+		default:
+			lo = next
+		}
+	}
+	_, err = f.Seek(best, io.SeekStart)
+	return err
+}
+
+// historyLineAt returns the first valid line starting at or after offset:
+// its start, the offset after it and the candle. start is size when there is
+// none.
+func historyLineAt(f *os.File, size, offset int64) (start, next int64, c Candle, err error) {
+	start = offset
+	if offset > 0 {
+		start = offset - 1 // Offset itself starts a line when the previous byte is a newline
+	}
+	r := bufio.NewReader(io.NewSectionReader(f, start, size-start))
+	if offset > 0 {
+		skipped, err := r.ReadString('\n')
+		if err == io.EOF {
+			return size, size, Candle{}, nil
+		} else if err != nil {
+			return 0, 0, Candle{}, err
+		}
+		start += int64(len(skipped))
+	}
+	for start < size {
+		line, err := r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return 0, 0, Candle{}, err
+		}
+		next = start + int64(len(line))
+		if c, perr := parseHistoryLine(strings.TrimSpace(line)); perr == nil {
+			return start, next, c, nil
+		}
+		start = next
+	}
+	return size, size, Candle{}, nil
+}
+
+// endsWithoutNewline reports whether f is not empty and its last byte is not
+// a newline.
+func endsWithoutNewline(f *os.File) (bool, error) {
+	info, err := f.Stat()
+	if err != nil || info.Size() == 0 {
+		return false, err
+	}
+	last := make([]byte, 1)
+	if _, err := f.ReadAt(last, info.Size()-1); err != nil {
+		return false, err
+	}
+	return last[0] != '\n', nil
+}
+
+func parseHistoryLine(line string) (Candle, error) {
+	fields := strings.Split(line, ",")
+	if len(fields) != 6 {
+		return Candle{}, fmt.Errorf("expected 6 fields, got %d", len(fields))
+	}
+
+	ms, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return Candle{}, fmt.Errorf("invalid time: %w", err)
+	}
+
+	var values [5]float64
+	for i := range values {
+		values[i], err = strconv.ParseFloat(fields[i+1], 64)
+		if err != nil {
+			return Candle{}, fmt.Errorf("invalid number: %w", err)
+		}
+	}
+
+	return Candle{
+		Time:   time.UnixMilli(ms),
+		Open:   values[0],
+		High:   values[1],
+		Low:    values[2],
+		Close:  values[3],
+		Volume: values[4],
+	}, nil
+}
+
+func formatHistoryFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+// setTestCacheDir points the cache directory (history, price cache, locks) at
+// a temporary directory.
+func setTestCacheDir(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", dir)
+	t.Setenv("HOME", dir) // macOS: ~/Library/Caches
+	historyMutex.Lock()
+	clear(historyEnds)
+	historyMutex.Unlock()
+}
+
+// testCandles returns n one-minute candles starting at start.
+func testCandles(start time.Time, n int) []Candle {
+	candles := make([]Candle, n)
+	for i := range candles {
+		p := 100 + float64(i)
+		candles[i] = Candle{Time: start.Add(time.Duration(i) * time.Minute), Open: p, High: p + 1, Low: p - 1, Close: p + 0.5, Volume: 10}
+	}
+	return candles
+}
+
+var testHistoryStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestAppendHistory(t *testing.T) {
+	setTestCacheDir(t)
+	candles := testCandles(testHistoryStart, 10)
+
+	if n, err := appendHistory("BTCUSDC", "1m", candles[:6]); err != nil || n != 6 {
+		t.Fatalf("first append: %d, %v", n, err)
+	}
+	// Overlapping pages only add the new candles
+	if n, err := appendHistory("BTCUSDC", "1m", candles[4:]); err != nil || n != 4 {
+		t.Fatalf("second append: %d, %v", n, err)
+	}
+	last, err := lastHistoryTime("BTCUSDC", "1m")
+	if err != nil || !last.Equal(candles[9].Time) {
+		t.Errorf("last = %v, %v", last, err)
+	}
+
+	got, err := loadHistory("BTCUSDC", "1m", time.Time{}, time.Time{})
+	if err != nil || len(got) != 10 {
+		t.Fatalf("loaded %d candles, %v", len(got), err)
+	}
+	for i, c := range got {
+		if !c.Time.Equal(candles[i].Time) || c.Close != candles[i].Close {
+			t.Errorf("candle %d = %+v, want %+v", i, c, candles[i])
+		}
+	}
+}
+
+// A write interrupted mid-line leaves a torn last line; the next append
+// starts on a new line and resumes after the last complete candle.
+func TestAppendHistoryAfterTornWrite(t *testing.T) {
+	setTestCacheDir(t)
+	candles := testCandles(testHistoryStart, 4)
+	if _, err := appendHistory("BTCUSDC", "1m", candles[:2]); err != nil {
+		t.Fatal(err)
+	}
+	path, _ := getHistoryPath("BTCUSDC", "1m")
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("1704067320000,102,10")
+	f.Close()
+
+	last, err := lastHistoryTime("BTCUSDC", "1m")
+	if err != nil || !last.Equal(candles[1].Time) {
+		t.Fatalf("last = %v, %v; want the last complete candle", last, err)
+	}
+	if n, err := appendHistory("BTCUSDC", "1m", candles); err != nil || n != 2 {
+		t.Fatalf("append: %d, %v", n, err)
+	}
+	got, err := loadHistory("BTCUSDC", "1m", time.Time{}, time.Time{})
+	if err != nil || len(got) != 4 {
+		t.Errorf("loaded %d candles, %v; want 4", len(got), err)
+	}
+}
+
+func TestLoadHistoryRange(t *testing.T) {
+	setTestCacheDir(t)
+	candles := testCandles(testHistoryStart, 500)
+	if _, err := appendHistory("BTCUSDC", "1m", candles); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		from, to   time.Time
+		first, end int // Expected candles[first:end]
+	}{
+		{time.Time{}, time.Time{}, 0, 500},
+		{candles[0].Time, time.Time{}, 0, 500},
+		{candles[1].Time.Add(-time.Second), time.Time{}, 1, 500},
+		{candles[250].Time, candles[260].Time, 250, 261},
+		{candles[499].Time, time.Time{}, 499, 500},
+		{candles[499].Time.Add(time.Second), time.Time{}, 500, 500},
+		{testHistoryStart.Add(-time.Hour), candles[3].Time, 0, 4},
+	}
+	for _, tt := range tests {
+		got, err := loadHistory("BTCUSDC", "1m", tt.from, tt.to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := candles[tt.first:tt.end]
+		if len(got) != len(want) || (len(got) > 0 && (!got[0].Time.Equal(want[0].Time) || !got[len(got)-1].Time.Equal(want[len(want)-1].Time))) {
+			t.Errorf("loadHistory(%v, %v): %d candles, want candles[%d:%d]", tt.from, tt.to, len(got), tt.first, tt.end)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Name of the instance lock file in the cache directory
+const instanceLockName = "instance.lock"
+
+// Delay between attempts to reach an instance that is still starting
+var startupRetryDelay = 500 * time.Millisecond
+
+var (
+	// Lock file held for the life of the process; the lock is released by the
+	// OS when the process exits, even after a crash
+	instanceLockFile *os.File
+
+	// Pair to display and pair to pin at startup (--select and --pin), applied
+	// here or forwarded to the running instance
+	startupSelect string
+	startupPin    string
+)
+
+// --- Instance Lock ---
+
+// acquireInstanceLock takes the per-user instance lock. It returns false with
+// the pid of the holder when another instance has it.
+func acquireInstanceLock() (bool, int, error) {
+	dir, err := getCacheDir()
+	if err != nil {
+		return false, 0, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, instanceLockName), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return false, 0, err
+	}
+	locked, err := lockFile(f)
+	if err != nil || !locked {
+		data := make([]byte, 32)
+		n, _ := f.Read(data)
+		pid, _ := strconv.Atoi(strings.TrimSpace(string(data[:n])))
+		f.Close()
+		return false, pid, err
+	}
+
+	// Record our pid for the error messages of later launches
+	if err := f.Truncate(0); err == nil {
+		f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	instanceLockFile = f
+	return true, 0, nil
+}
+
+// startupCommands returns the control commands for --select and --pin.
+func startupCommands() [][]string {
+	var commands [][]string
+	if startupPin != "" {
+		commands = append(commands, []string{"pin", startupPin})
+	}
+	if startupSelect != "" {
+		commands = append(commands, []string{"select", startupSelect})
+	}
+	return commands
+}
+
+// alreadyRunning describes the instance holding the lock.
+func alreadyRunning(pid int) string {
+	if pid == 0 {
+		return "CriptoMenu is already running" // Windows does not let us read the locked file
+	}
+	return fmt.Sprintf("CriptoMenu is already running (pid %d)", pid)
+}
+
+// applyStartupCommands runs --select and --pin in this process.
+func applyStartupCommands() {
+	for _, args := range startupCommands() {
+		if _, err := runControlCommand(args); err != nil {
+			showErrorAlert("CriptoMenu", fmt.Sprintf("Could not %s %s.\nError: %v", args[0], args[1], err))
+		}
+	}
+}
+
+// handOff forwards --select and --pin to the running instance and returns
+// the exit code. A config file other than the instance's cannot be handed
+// off and is reported.
+func handOff(pid int) int {
+	if err := checkInstanceConfig(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s: %v.\n", alreadyRunning(pid), err)
+		return 1
+	}
+	commands := startupCommands()
+	if len(commands) == 0 {
+		fmt.Fprintf(os.Stderr, "%s.\n", alreadyRunning(pid))
+		return 0
+	}
+
+	status := 0
+	for _, args := range commands {
+		if _, err := sendStartupCommand(args); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s %s: %v\n", args[0], args[1], err)
+			status = 1
+		}
+	}
+	return status
+}
+
+// sendStartupCommand sends a command to the running instance. The instance
+// may still be starting, so connecting is retried for a few seconds.
+func sendStartupCommand(args []string) ([]string, error) {
+	var output []string
+	var err error
+	for attempt := 0; attempt < 10; attempt++ {
+		if output, err = sendControlCommand(args); !errors.Is(err, errNotRunning) {
+			break
+		}
+		time.Sleep(startupRetryDelay)
+	}
+	return output, err
+}
+
+// checkInstanceConfig returns an error when this launch chose a config file
+// (--config or CRIPTOMENU_CONFIG) other than the running instance's.
+func checkInstanceConfig() error {
+	candidates := configCandidates()
+	path, i, err := resolveConfigPath(candidates)
+	if err != nil || i < 0 || !candidates[i].Explicit {
+		return nil
+	}
+	output, err := sendStartupCommand([]string{"status"})
+	if err != nil {
+		return fmt.Errorf("could not check its config file: %w", err)
+	}
+	for _, line := range output {
+		if running, ok := strings.CutPrefix(line, "config: "); ok && !sameFile(running, path) {
+			return fmt.Errorf("it uses %s, not %s; quit it first to use another config file", running, path)
+		}
+	}
+	return nil
+}
+
+// sameFile reports whether a and b name the same file, following symlinks.
+func sameFile(a, b string) bool {
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	if errA != nil || errB != nil {
+		return filepath.Clean(a) == filepath.Clean(b)
+	}
+	return os.SameFile(infoA, infoB)
+}
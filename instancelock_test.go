@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A second lock on the file fails while the first is held, even from the
+// same process, and reports the holder's pid.
+func TestAcquireInstanceLock(t *testing.T) {
+	setTestCacheDir(t)
+	t.Cleanup(func() {
+		if instanceLockFile != nil {
+			instanceLockFile.Close()
+			instanceLockFile = nil
+		}
+	})
+
+	locked, _, err := acquireInstanceLock()
+	if err != nil || !locked {
+		t.Fatalf("first lock: %v, %v", locked, err)
+	}
+	held := instanceLockFile
+
+	locked, pid, err := acquireInstanceLock()
+	if err != nil || locked {
+		t.Fatalf("second lock: %v, %v; want contention", locked, err)
+	}
+	if runtime.GOOS != "windows" && pid != os.Getpid() {
+		t.Errorf("holder pid = %d, want %d", pid, os.Getpid())
+	}
+	if instanceLockFile != held {
+		t.Error("the failed attempt replaced the held lock file")
+	}
+
+	// Released when the holder closes the file (or exits)
+	held.Close()
+	instanceLockFile = nil
+	if locked, _, err := acquireInstanceLock(); err != nil || !locked {
+		t.Errorf("after release: %v, %v", locked, err)
+	}
+}
+
+// setStartupFlags sets --select, --pin and --config for a test.
+func setStartupFlags(t *testing.T, selectPair, pin, config string) {
+	oldSelect, oldPin, oldConfig, oldDelay := startupSelect, startupPin, configPathFlag, startupRetryDelay
+	startupSelect, startupPin, configPathFlag = selectPair, pin, config
+	startupRetryDelay = 50 * time.Millisecond
+	t.Cleanup(func() {
+		startupSelect, startupPin, configPathFlag, startupRetryDelay = oldSelect, oldPin, oldConfig, oldDelay
+	})
+}
+
+// The commands reach an instance that only starts listening after a while.
+func TestHandOffRetries(t *testing.T) {
+	setAPITestState(t)
+	setTestRuntimeDir(t)
+	setStartupFlags(t, "ethusdc", "", "")
+	displayed := getPair()
+	t.Cleanup(func() { setPair(displayed) })
+
+	go func() {
+		time.Sleep(3 * startupRetryDelay)
+		startControlServer()
+	}()
+	defer stopControlServer()
+
+	if code := handOff(123); code != 0 {
+		t.Fatalf("exit code %d, want 0", code)
+	}
+	if got := getPair(); got != "ETHUSDC" {
+		t.Errorf("displayed pair = %q, want ETHUSDC", got)
+	}
+}
+
+// startFakeInstance serves the control socket with a status reporting
+// configPath.
+func startFakeInstance(t *testing.T, configPath string) {
+	setTestRuntimeDir(t)
+	path, _ := controlSocketPath()
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("unix sockets not available: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			line, _ := bufio.NewReader(conn).ReadString('\n')
+			if strings.TrimSpace(line) == "status" {
+				conn.Write([]byte("pair: BTCUSDC\nconfig: " + configPath + "\nOK\n"))
+			} else {
+				conn.Write([]byte("OK\n"))
+			}
+			conn.Close()
+		}
+	}()
+}
+
+func TestHandOffConfig(t *testing.T) {
+	dir := t.TempDir()
+	running := filepath.Join(dir, "config.toml")
+	other := filepath.Join(dir, "work.toml")
+	link := filepath.Join(dir, "link.toml")
+	os.WriteFile(running, []byte("pairs = []\n"), 0644)
+	os.Symlink(running, link)
+	startFakeInstance(t, running)
+	t.Setenv(configPathEnv, "")
+
+	tests := []struct {
+		name, config string
+		code         int
+	}{
+		{"no --config", "", 0},
+		{"same config", running, 0},
+		{"same config through a symlink", link, 0},
+		{"other config", other, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.config == link {
+				if _, err := os.Lstat(link); err != nil {
+					t.Skip("symlinks not supported")
+				}
+			}
+			setStartupFlags(t, "", "", tt.config)
+			if code := handOff(123); code != tt.code {
+				t.Errorf("exit code %d, want %d", code, tt.code)
+			}
+		})
+	}
+
+	// The environment variable chooses a config as well
+	setStartupFlags(t, "", "", "")
+	t.Setenv(configPathEnv, other)
+	if code := handOff(123); code != 1 {
+		t.Errorf("%s: exit code %d, want 1", configPathEnv, code)
+	}
+}
+
+func TestCheckInstanceConfigNotReachable(t *testing.T) {
+	setTestRuntimeDir(t)
+	setStartupFlags(t, "", "", filepath.Join(t.TempDir(), "config.toml"))
+	if err := checkInstanceConfig(); err == nil || !strings.Contains(err.Error(), "could not check") {
+		t.Errorf("err = %v, want a failed check", err)
+	}
+}
@@ -1,9 +1,52 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
 	"github.com/getlantern/systray"
 )
 
 func main() {
+	// Older macOS versions pass a -psn_... argument to app bundles
+	var args []string
+	for _, arg := range os.Args[1:] {
+		if !strings.HasPrefix(arg, "-psn") {
+			args = append(args, arg)
+		}
+	}
+
+	flags := flag.NewFlagSet("criptomenu", flag.ContinueOnError)
+	flags.StringVar(&configPathFlag, "config", "", "config file to use")
+	verbose := flags.Bool("verbose", false, "print log messages of commands")
+	flags.StringVar(&startupSelect, "select", "", "pair to display")
+	flags.StringVar(&startupPin, "pin", "", "pair to pin")
+	flags.Usage = func() { fmt.Fprint(os.Stderr, cliUsage) }
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	}
+
+	if flags.NArg() > 0 {
+		// Keep command output readable; the daemon logs like the menu bar app
+		if !*verbose && flags.Arg(0) != "daemon" {
+			log.SetOutput(io.Discard)
+		}
+		os.Exit(runCommand(flags.Args()))
+	}
+
+	// One app per user: a second launch hands its arguments to the first
+	locked, pid, err := acquireInstanceLock()
+	if err != nil {
+		log.Printf("Error taking the instance lock, starting anyway: %v", err)
+	} else if !locked {
+		os.Exit(handOff(pid))
+	}
 	systray.Run(onReady, onExit)
 }
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/getlantern/systray"
+)
+
+// Maximum number of symbols offered by "Add pair…"
+const maxPairSearchResults = 40
+
+// pairSubmenu is a submenu with one item per configured pair
+type pairSubmenu struct {
+	parent  *systray.MenuItem
+	items   []*systray.MenuItem
+	onClick func(index int)
+}
+
+var (
+	// "Manage Pairs" menu and its per-pair submenus
+	mManagePairs *systray.MenuItem
+	mRemovePair  *pairSubmenu
+	mMoveUp      *pairSubmenu
+	mMoveDown    *pairSubmenu
+)
+
+// --- Manage Pairs Menu ---
+
+// addManagePairsMenu adds the "Manage Pairs" menu used to edit Config.Pairs.
+func addManagePairsMenu() {
+	mManagePairs = systray.AddMenuItem("Manage Pairs", "Add, remove and reorder the monitored pairs")
+
+	mAddPair := mManagePairs.AddSubMenuItem("Add pair…", "Search Binance symbols by base asset, e.g. SOL")
+	go func() {
+		for range mAddPair.ClickedCh {
+			addPairInteractive()
+		}
+	}()
+
+	mRemovePair = &pairSubmenu{
+		parent:  mManagePairs.AddSubMenuItem("Remove", "Remove a pair from the list"),
+		onClick: func(index int) { editPairs(index, removePairAt) },
+	}
+	mMoveUp = &pairSubmenu{
+		parent:  mManagePairs.AddSubMenuItem("Move Up", "Show a pair earlier in the rotation"),
+		onClick: func(index int) { editPairs(index, movePairUp) },
+	}
+	mMoveDown = &pairSubmenu{
+		parent:  mManagePairs.AddSubMenuItem("Move Down", "Show a pair later in the rotation"),
+		onClick: func(index int) { editPairs(index, movePairDown) },
+	}
+
+	updateManagePairsMenu()
+}
+
+// updateManagePairsMenu refreshes the per-pair submenus from the active config.
+func updateManagePairsMenu() {
+	if mManagePairs == nil {
+		return
+	}
+
+	configMutex.RLock()
+	pairs := activeConfig.Pairs
+	own := len(pairs) - activeConfig.SharedPairs
+	configMutex.RUnlock()
+
+	// Pairs from the shared watchlist are listed but cannot be changed
+	mRemovePair.update(pairs, func(i int) bool { return i < own && own > 1 })
+	mMoveUp.update(pairs, func(i int) bool { return i > 0 && i < own })
+	mMoveDown.update(pairs, func(i int) bool { return i < own-1 })
+}
+
+// update shows one item per pair, creating items as needed; items for which
+// enabled returns false are greyed out.
+func (m *pairSubmenu) update(pairs []string, enabled func(index int) bool) {
+	for i := len(m.items); i < len(pairs); i++ {
+		item := m.parent.AddSubMenuItem("", "")
+		m.items = append(m.items, item)
+
+		go func(index int, it *systray.MenuItem) {
+			for range it.ClickedCh {
+				m.onClick(index)
+			}
+		}(i, item)
+	}
+
+	for i, item := range m.items {
+		if i >= len(pairs) {
+			item.Hide()
+			continue
+		}
+		item.SetTitle(pairs[i])
+		if enabled(i) {
+			item.Enable()
+		} else {
+			item.Disable()
+		}
+		item.Show()
+	}
+}
+
+// --- Pair Edits ---
+
+// addPairInteractive asks for a base asset or symbol, lets the user pick one
+// of the matching exchange symbols and appends it to the pairs.
+func addPairInteractive() {
+	query, ok, err := promptText("Add Pair", "Base asset or symbol to add (e.g. SOL or SOLUSDC):")
+	if err != nil {
+		log.Printf("Error showing add pair dialog: %v", err)
+		showErrorAlert("Add Pair", fmt.Sprintf("Could not show dialog.\nError: %v", err))
+		return
+	}
+	if !ok {
+		return
+	}
+
+	configMutex.RLock()
+	configured := append([]string(nil), activeConfig.Pairs...)
+	own := ownPairs(activeConfig)
+	configMutex.RUnlock()
+
+	matches := searchSymbols(query, configured)
+	if len(matches) == 0 {
+		log.Printf("No symbols found for %q", query)
+		showErrorAlert("Add Pair", fmt.Sprintf("No tradable Binance symbols found for %q.", query))
+		return
+	}
+
+	pair := matches[0]
+	if len(matches) > 1 {
+		pair, ok, err = chooseFromList("Add Pair", fmt.Sprintf("Symbols matching %s:", strings.ToUpper(query)), matches)
+		if err != nil {
+			log.Printf("Error showing symbol list: %v", err)
+			return
+		}
+		if !ok {
+			return
+		}
+	}
+
+	if err := savePairs(append(own, pair)); err != nil {
+		log.Printf("Error adding pair %s: %v", pair, err)
+		showErrorAlert("Add Pair", fmt.Sprintf("Could not add %s.\nError: %v", pair, err))
+		return
+	}
+	log.Printf("Added pair %s", pair)
+}
+
+// searchSymbols returns the trading symbols matching query, excluding those
+// already configured: all symbols with query as base asset (SOL -> SOLUSDC,
+// SOLUSDT, SOLBTC, ...), or symbols starting with query when there are none.
+// Without exchange metadata the query itself is returned.
+func searchSymbols(query string, exclude []string) []string {
+	query = strings.ToUpper(strings.TrimSpace(query))
+	if query == "" {
+		return nil
+	}
+	skip := make(map[string]bool)
+	for _, p := range exclude {
+		skip[p] = true
+	}
+
+	symbolInfoMutex.RLock()
+	defer symbolInfoMutex.RUnlock()
+
+	if len(symbolInfo) == 0 {
+		if skip[query] {
+			return nil
+		}
+		return []string{query}
+	}
+
+	var byBase, byPrefix []string
+	for symbol, meta := range symbolInfo {
+		if meta.Status != "TRADING" || skip[symbol] {
+			continue
+		}
+		if meta.BaseAsset == query || symbol == query {
+			byBase = append(byBase, symbol)
+		} else if strings.HasPrefix(symbol, query) {
+			byPrefix = append(byPrefix, symbol)
+		}
+	}
+
+	res := byBase
+	if len(res) == 0 {
+		res = byPrefix
+	}
+	sort.Strings(res)
+	if len(res) > maxPairSearchResults {
+		res = res[:maxPairSearchResults]
+	}
+	return res
+}
+
+// editPairs applies edit to a copy of the pairs of the config file and saves
+// the result.
+func editPairs(index int, edit func(pairs []string, index int) []string) {
+	configMutex.RLock()
+	pairs := ownPairs(activeConfig)
+	configMutex.RUnlock()
+
+	if index < 0 || index >= len(pairs) {
+		return
+	}
+	if err := savePairs(edit(pairs, index)); err != nil {
+		log.Printf("Error updating pairs: %v", err)
+		showErrorAlert("Manage Pairs", fmt.Sprintf("Could not update the pairs.\nError: %v", err))
+	}
+}
+
+func removePairAt(pairs []string, index int) []string {
+	if len(pairs) <= 1 {
+		return pairs // Keep at least one pair to display
+	}
+	return append(pairs[:index], pairs[index+1:]...)
+}
+
+func movePairUp(pairs []string, index int) []string {
+	if index > 0 {
+		pairs[index-1], pairs[index] = pairs[index], pairs[index-1]
+	}
+	return pairs
+}
+
+func movePairDown(pairs []string, index int) []string {
+	if index < len(pairs)-1 {
+		pairs[index], pairs[index+1] = pairs[index+1], pairs[index]
+	}
+	return pairs
+}
+
+// savePairs writes pairs to the config file (to the active profile when it
+// sets pairs), keeping its comments, and reloads the config and menus.
+func savePairs(pairs []string) error {
+	table := configWriteTable("pairs")
+	err := updateConfigFile(func(doc []byte) ([]byte, error) {
+		return setStringArray(doc, table, "pairs", pairs)
+	})
+	if err != nil {
+		return err
+	}
+
+	loadAndSetConfig()
+	updatePairsMenu()
+	triggerBackfill() // Fetch history for newly added pairs
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSearchSymbols(t *testing.T) {
+	symbolInfoMutex.RLock()
+	oldInfo, oldFetchedAt := symbolInfo, symbolInfoFetchedAt
+	symbolInfoMutex.RUnlock()
+	t.Cleanup(func() { setSymbolInfo(oldInfo, oldFetchedAt) })
+
+	symbols := map[string]SymbolMeta{}
+	for _, s := range []struct{ symbol, base, quote, status string }{
+		{"SOLUSDC", "SOL", "USDC", "TRADING"},
+		{"SOLUSDT", "SOL", "USDT", "TRADING"},
+		{"SOLBTC", "SOL", "BTC", "TRADING"},
+		{"SOLEUR", "SOL", "EUR", "BREAK"},
+		{"SOLVUSDT", "SOLV", "USDT", "TRADING"},
+		{"BTCUSDC", "BTC", "USDC", "TRADING"},
+		{"ETHUSDC", "ETH", "USDC", "TRADING"},
+	} {
+		symbols[s.symbol] = SymbolMeta{Symbol: s.symbol, BaseAsset: s.base, QuoteAsset: s.quote, Status: s.status}
+	}
+	for i := range maxPairSearchResults + 5 {
+		symbol := fmt.Sprintf("MANY%02dUSDC", i)
+		symbols[symbol] = SymbolMeta{Symbol: symbol, BaseAsset: fmt.Sprintf("MANY%02d", i), QuoteAsset: "USDC", Status: "TRADING"}
+	}
+	setSymbolInfo(symbols, time.Now())
+
+	tests := []struct {
+		query   string
+		exclude []string
+		want    []string
+	}{
+		// Base asset matches only, not SOLVUSDT or the halted SOLEUR
+		{"SOL", nil, []string{"SOLBTC", "SOLUSDC", "SOLUSDT"}},
+		{" sol ", []string{"SOLUSDC"}, []string{"SOLBTC", "SOLUSDT"}},
+		{"BTCUSDC", nil, []string{"BTCUSDC"}},
+		// No base asset match: symbols with the prefix
+		{"SOLV", nil, []string{"SOLVUSDT"}},
+		{"SO", nil, []string{"SOLBTC", "SOLUSDC", "SOLUSDT", "SOLVUSDT"}},
+		{"XRP", nil, nil},
+		{"", nil, nil},
+	}
+	for _, tt := range tests {
+		if got := searchSymbols(tt.query, tt.exclude); !slices.Equal(got, tt.want) {
+			t.Errorf("searchSymbols(%q, %v) = %v, want %v", tt.query, tt.exclude, got, tt.want)
+		}
+	}
+
+	if got := searchSymbols("MANY", nil); len(got) != maxPairSearchResults || got[0] != "MANY00USDC" {
+		t.Errorf("searchSymbols(MANY): %d results starting with %v, want the first %d", len(got), got[:1], maxPairSearchResults)
+	}
+
+	// Without exchange metadata the query is taken as is
+	setSymbolInfo(nil, time.Time{})
+	if got := searchSymbols("dogeusdc", nil); !slices.Equal(got, []string{"DOGEUSDC"}) {
+		t.Errorf("without metadata: %v", got)
+	}
+	if got := searchSymbols("DOGEUSDC", []string{"DOGEUSDC"}); got != nil {
+		t.Errorf("without metadata, configured pair: %v", got)
+	}
+}
+
+func TestEditPairHelpers(t *testing.T) {
+	pairs := func() []string { return []string{"BTCUSDC", "ETHUSDC", "SOLUSDC"} }
+	tests := []struct {
+		name  string
+		edit  func([]string, int) []string
+		index int
+		want  []string
+	}{
+		{"remove first", removePairAt, 0, []string{"ETHUSDC", "SOLUSDC"}},
+		{"remove last", removePairAt, 2, []string{"BTCUSDC", "ETHUSDC"}},
+		{"move up", movePairUp, 2, []string{"BTCUSDC", "SOLUSDC", "ETHUSDC"}},
+		{"move first up", movePairUp, 0, []string{"BTCUSDC", "ETHUSDC", "SOLUSDC"}},
+		{"move down", movePairDown, 0, []string{"ETHUSDC", "BTCUSDC", "SOLUSDC"}},
+		{"move last down", movePairDown, 2, []string{"BTCUSDC", "ETHUSDC", "SOLUSDC"}},
+	}
+	for _, tt := range tests {
+		if got := tt.edit(pairs(), tt.index); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// The last pair is never removed
+	if got := removePairAt([]string{"BTCUSDC"}, 0); !slices.Equal(got, []string{"BTCUSDC"}) {
+		t.Errorf("removing the last pair: %v", got)
+	}
+}
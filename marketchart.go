@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+const (
+	defaultChartProvider = "binance"
+	defaultChartLocale   = "en"
+)
+
+// MarketChartConfig selects the website opened by the "Market Chart" menu item
+type MarketChartConfig struct {
+	Provider    string `toml:"provider,omitempty"`     // "binance" (default), "tradingview", "coingecko" or "custom"
+	Locale      string `toml:"locale,omitempty"`       // Site language, e.g. "en", "it" (default "en")
+	URLTemplate string `toml:"url_template,omitempty"` // For "custom", e.g. "https://example.com/{base}-{quote}"
+}
+
+// URL templates of the built-in chart providers. Placeholders: {symbol},
+// {base}, {quote}, {base_lower}, {quote_lower}, {locale}, {coin}, {host}.
+var chartProviderTemplates = map[string]string{
+	"binance":     "https://www.binance.com/{locale}/trade/{base}_{quote}?type=spot",
+	"tradingview": "https://{host}/chart/?symbol=BINANCE:{symbol}",
+	"coingecko":   "https://www.coingecko.com/{locale}/coins/{coin}",
+}
+
+// CoinGecko identifies coins by id rather than ticker; ids of common assets
+var coinGeckoIDs = map[string]string{
+	"BTC":   "bitcoin",
+	"ETH":   "ethereum",
+	"BNB":   "binancecoin",
+	"SOL":   "solana",
+	"XRP":   "ripple",
+	"ADA":   "cardano",
+	"DOGE":  "dogecoin",
+	"LTC":   "litecoin",
+	"DOT":   "polkadot",
+	"TRX":   "tron",
+	"AVAX":  "avalanche-2",
+	"LINK":  "chainlink",
+	"SHIB":  "shiba-inu",
+	"TON":   "the-open-network",
+	"XLM":   "stellar",
+	"MATIC": "matic-network",
+	"ATOM":  "cosmos",
+	"UNI":   "uniswap",
+	"NEAR":  "near",
+	"PEPE":  "pepe",
+}
+
+// Quote assets tried as suffix, longest first, when no exchange metadata is available
+var fallbackQuoteAssets = []string{
+	"FDUSD",
+	"USDT", "USDC", "BUSD", "TUSD", "USDP", "EURI", "DOGE",
+	"EUR", "TRY", "BRL", "GBP", "JPY", "ARS", "MXN", "PLN", "ZAR", "UAH",
+	"DAI", "BTC", "ETH", "BNB", "XRP", "TRX",
+}
+
+// --- Pair Helpers ---
+
+// splitPair returns the base and quote asset of pair using the exchange
+// metadata, falling back to matching known quote assets as suffix.
+func splitPair(pair string) (base, quote string, ok bool) {
+	if meta, found := getSymbolMeta(pair); found && meta.BaseAsset != "" {
+		return meta.BaseAsset, meta.QuoteAsset, true
+	}
+	for _, q := range fallbackQuoteAssets {
+		if strings.HasSuffix(pair, q) && len(pair) > len(q) {
+			return pair[:len(pair)-len(q)], q, true
+		}
+	}
+	return pair, "", false
+}
+
+// displayPair formats pair as "BASE/QUOTE" when the split is known.
+func displayPair(pair string) string {
+	base, quote, ok := splitPair(pair)
+	if !ok {
+		return pair
+	}
+	return base + "/" + quote
+}
+
+// --- Market Chart ---
+
+// marketChartURL builds the chart URL for pair from the configured provider.
+func marketChartURL(pair string) (string, error) {
+	configMutex.RLock()
+	cfg := MarketChartConfig{}
+	if activeConfig != nil && activeConfig.MarketChart != nil {
+		cfg = *activeConfig.MarketChart
+	}
+	configMutex.RUnlock()
+
+	if cfg.Provider == "" {
+		cfg.Provider = defaultChartProvider
+	}
+	if cfg.Locale == "" {
+		cfg.Locale = defaultChartLocale
+	}
+
+	tmpl := cfg.URLTemplate
+	if cfg.Provider != "custom" {
+		var ok bool
+		tmpl, ok = chartProviderTemplates[cfg.Provider]
+		if !ok {
+			return "", fmt.Errorf("unknown chart provider %q", cfg.Provider)
+		}
+	}
+	if tmpl == "" {
+		return "", fmt.Errorf("chart provider \"custom\" requires url_template")
+	}
+
+	base, quote, ok := splitPair(pair)
+	if !ok && strings.Contains(tmpl, "{quote") {
+		return "", fmt.Errorf("cannot determine base and quote asset of %s", pair)
+	}
+
+	coin, found := coinGeckoIDs[base]
+	if !found {
+		coin = strings.ToLower(base)
+	}
+	host := "www.tradingview.com"
+	if cfg.Locale != "en" {
+		host = cfg.Locale + ".tradingview.com"
+	}
+
+	r := strings.NewReplacer(
+		"{symbol}", pair,
+		"{base}", base,
+		"{quote}", quote,
+		"{base_lower}", strings.ToLower(base),
+		"{quote_lower}", strings.ToLower(quote),
+		"{locale}", cfg.Locale,
+		"{coin}", coin,
+		"{host}", host,
+	)
+	return r.Replace(tmpl), nil
+}
+
+// openURL opens a URL or file with the default application of the platform.
+func openURL(target string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", target)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", target)
+	default:
+		cmd = exec.Command("xdg-open", target)
+	}
+	return cmd.Run()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSplitPair(t *testing.T) {
+	symbolInfoMutex.RLock()
+	oldInfo, oldFetchedAt := symbolInfo, symbolInfoFetchedAt
+	symbolInfoMutex.RUnlock()
+	setSymbolInfo(map[string]SymbolMeta{
+		"BTCUSDC": {Symbol: "BTCUSDC", BaseAsset: "BTC", QuoteAsset: "USDC"},
+		"BTCIDR":  {Symbol: "BTCIDR", BaseAsset: "BTC", QuoteAsset: "IDR"},   // No known suffix
+		"USDCTRY": {Symbol: "USDCTRY", BaseAsset: "USDC", QuoteAsset: "TRY"}, // Metadata only
+	}, time.Now())
+	t.Cleanup(func() { setSymbolInfo(oldInfo, oldFetchedAt) })
+
+	tests := []struct {
+		pair, base, quote string
+		ok                bool
+	}{
+		// Exchange metadata
+		{"BTCUSDC", "BTC", "USDC", true},
+		{"BTCIDR", "BTC", "IDR", true},
+		{"USDCTRY", "USDC", "TRY", true},
+		// Known quote assets as suffix, longest first
+		{"BTCFDUSD", "BTC", "FDUSD", true},
+		{"ETHUSDT", "ETH", "USDT", true},
+		{"BTCTRY", "BTC", "TRY", true},
+		{"SHIBDOGE", "SHIB", "DOGE", true},
+		{"ETHBTC", "ETH", "BTC", true},
+		{"USDT", "USDT", "", false}, // Nothing left for the base
+		{"FOOBAR", "FOOBAR", "", false},
+	}
+	for _, tt := range tests {
+		base, quote, ok := splitPair(tt.pair)
+		if base != tt.base || quote != tt.quote || ok != tt.ok {
+			t.Errorf("splitPair(%s) = %q, %q, %v, want %q, %q, %v", tt.pair, base, quote, ok, tt.base, tt.quote, tt.ok)
+		}
+	}
+	if got := displayPair("BTCIDR"); got != "BTC/IDR" {
+		t.Errorf("displayPair(BTCIDR) = %q", got)
+	}
+	if got := displayPair("FOOBAR"); got != "FOOBAR" {
+		t.Errorf("displayPair(FOOBAR) = %q", got)
+	}
+}
+
+func TestMarketChartURL(t *testing.T) {
+	setTestSymbols(t)
+	configMutex.Lock()
+	old := activeConfig
+	configMutex.Unlock()
+	t.Cleanup(func() {
+		configMutex.Lock()
+		activeConfig = old
+		configMutex.Unlock()
+	})
+
+	tests := []struct {
+		chart *MarketChartConfig
+		pair  string
+		want  string
+	}{
+		{nil, "BTCUSDC", "https://www.binance.com/en/trade/BTC_USDC?type=spot"},
+		{&MarketChartConfig{Locale: "it"}, "ETHBTC", "https://www.binance.com/it/trade/ETH_BTC?type=spot"},
+		{&MarketChartConfig{Provider: "tradingview"}, "BTCUSDC", "https://www.tradingview.com/chart/?symbol=BINANCE:BTCUSDC"},
+		{&MarketChartConfig{Provider: "tradingview", Locale: "it"}, "BTCUSDC", "https://it.tradingview.com/chart/?symbol=BINANCE:BTCUSDC"},
+		{&MarketChartConfig{Provider: "coingecko"}, "SHIBUSDC", "https://www.coingecko.com/en/coins/shiba-inu"},
+		{&MarketChartConfig{Provider: "coingecko", Locale: "de"}, "ARBUSDT", "https://www.coingecko.com/de/coins/arb"}, // Not mapped: the ticker
+		{
+			&MarketChartConfig{Provider: "custom", Locale: "fr", URLTemplate: "https://example.com/{locale}/{base_lower}-{quote_lower}?s={symbol}&c={coin}"},
+			"BTCEUR", "https://example.com/fr/btc-eur?s=BTCEUR&c=bitcoin",
+		},
+		// Templates without the quote asset do not need a split
+		{&MarketChartConfig{Provider: "custom", URLTemplate: "https://example.com/{symbol}"}, "FOOBAR", "https://example.com/FOOBAR"},
+	}
+	for _, tt := range tests {
+		configMutex.Lock()
+		activeConfig = &Config{MarketChart: tt.chart}
+		configMutex.Unlock()
+		got, err := marketChartURL(tt.pair)
+		if err != nil || got != tt.want {
+			t.Errorf("marketChartURL(%s) with %+v = %q, %v, want %q", tt.pair, tt.chart, got, err, tt.want)
+		}
+	}
+
+	for _, chart := range []*MarketChartConfig{
+		{Provider: "kraken"},
+		{Provider: "custom"},
+		{Provider: "custom", URLTemplate: "https://example.com/{base}/{quote}"}, // FOOBAR cannot be split
+	} {
+		configMutex.Lock()
+		activeConfig = &Config{MarketChart: chart}
+		configMutex.Unlock()
+		if got, err := marketChartURL("FOOBAR"); err == nil {
+			t.Errorf("marketChartURL(FOOBAR) with %+v = %q, want an error", chart, got)
+		}
+	}
+}
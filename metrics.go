@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/binance/binance-connector-go/handlers"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Binance error code for too many requests
+const binanceTooManyRequests = -1003
+
+var (
+	// Registry of the metrics served on /metrics by the local API
+	metricsRegistry = prometheus.NewRegistry()
+	metrics         = promauto.With(metricsRegistry)
+
+	fetchDuration = metrics.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "criptomenu_fetch_duration_seconds",
+		Help:    "Duration of 24hr ticker requests to Binance.",
+		Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}, []string{"pair"})
+
+	fetchErrors = metrics.NewCounterVec(prometheus.CounterOpts{
+		Name: "criptomenu_fetch_errors_total",
+		Help: "Failed price fetches by pair and error type (api, rate_limit, timeout, network, parse, other).",
+	}, []string{"pair", "type"})
+
+	alertsTriggered = metrics.NewCounterVec(prometheus.CounterOpts{
+		Name: "criptomenu_alerts_triggered_total",
+		Help: "Triggered alerts by pair and condition.",
+	}, []string{"pair", "condition"})
+
+	notificationsSent = metrics.NewCounterVec(prometheus.CounterOpts{
+		Name: "criptomenu_notifications_total",
+		Help: "Alert notifications by delivery method and result (ok, error).",
+	}, []string{"method", "result"})
+
+	configReloads = metrics.NewCounterVec(prometheus.CounterOpts{
+		Name: "criptomenu_config_reloads_total",
+		Help: "Config reloads (file changes and reload requests) by result (applied, rejected).",
+	}, []string{"result"})
+)
+
+func init() {
+	metricsRegistry.MustRegister(
+		priceCollector{},
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// --- Metrics ---
+
+// metricsHandler serves the metrics in the Prometheus text format.
+func metricsHandler() http.Handler {
+	return promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{})
+}
+
+// recordFetch records the duration and, on failure, the error type of a
+// price fetch that started at start.
+func recordFetch(pair string, start time.Time, err error) {
+	fetchDuration.WithLabelValues(pair).Observe(time.Since(start).Seconds())
+	if err != nil {
+		fetchErrors.WithLabelValues(pair, fetchErrorType(err)).Inc()
+	}
+}
+
+// fetchErrorType classifies a fetch error for the errors counter.
+func fetchErrorType(err error) string {
+	var apiErr *handlers.APIError
+	var netErr net.Error
+	var numErr *strconv.NumError
+	switch {
+	case errors.As(err, &apiErr) && apiErr.Code == binanceTooManyRequests:
+		return "rate_limit"
+	case errors.As(err, &apiErr):
+		return "api"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.As(err, &netErr):
+		return "network"
+	case errors.As(err, &numErr):
+		return "parse"
+	default:
+		return "other"
+	}
+}
+
+// countAlert is the alert listener counting triggered alerts.
+func countAlert(alert Alert, price float64) {
+	alertsTriggered.WithLabelValues(alert.Pair, alert.Condition).Inc()
+}
+
+// recordNotification counts an alert notification sent with method.
+func recordNotification(method string, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	notificationsSent.WithLabelValues(method, result).Inc()
+}
+
+// recordConfigReload counts a reload of the config file.
+func recordConfigReload(applied bool) {
+	result := "applied"
+	if !applied {
+		result = "rejected"
+	}
+	configReloads.WithLabelValues(result).Inc()
+}
+
+// priceCollector exports the cached price, 24h change and age of each
+// configured pair, read from the price cache at scrape time so removed pairs
+// disappear.
+type priceCollector struct{}
+
+var (
+	priceDesc  = prometheus.NewDesc("criptomenu_price", "Last fetched price of the pair, in the quote asset.", []string{"pair"}, nil)
+	changeDesc = prometheus.NewDesc("criptomenu_change_24h_percent", "24h price change of the pair, in percent.", []string{"pair"}, nil)
+	ageDesc    = prometheus.NewDesc("criptomenu_price_age_seconds", "Time since the price of the pair was fetched.", []string{"pair"}, nil)
+)
+
+func (priceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- priceDesc
+	ch <- changeDesc
+	ch <- ageDesc
+}
+
+func (priceCollector) Collect(ch chan<- prometheus.Metric) {
+	configMutex.RLock()
+	if activeConfig == nil {
+		configMutex.RUnlock()
+		return
+	}
+	pairs := append([]string(nil), activeConfig.Pairs...)
+	configMutex.RUnlock()
+
+	for _, pair := range pairs {
+		entry, ok := getCachedPrice(pair)
+		if !ok {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(priceDesc, prometheus.GaugeValue, entry.Price, pair)
+		ch <- prometheus.MustNewConstMetric(changeDesc, prometheus.GaugeValue, entry.ChangePercent, pair)
+		ch <- prometheus.MustNewConstMetric(ageDesc, prometheus.GaugeValue, time.Since(entry.UpdatedAt).Seconds(), pair)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/binance/binance-connector-go/handlers"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	setAPITestState(t)
+	recordFetch("BTCUSDC", time.Now(), errors.New("boom"))
+	server := httptest.NewServer(newAPIHandler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`criptomenu_price{pair="BTCUSDC"} 92430.5`,
+		`criptomenu_change_24h_percent{pair="BTCUSDC"} 1.5`,
+		`criptomenu_fetch_errors_total{pair="BTCUSDC",type="other"}`,
+		`criptomenu_fetch_duration_seconds_count{pair="BTCUSDC"}`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("metrics do not contain %s", want)
+		}
+	}
+	if strings.Contains(string(body), `criptomenu_price{pair="ETHUSDC"}`) {
+		t.Error("pair without a price is exported")
+	}
+}
+
+func TestFetchErrorType(t *testing.T) {
+	_, parseErr := strconv.ParseFloat("x", 64)
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{&handlers.APIError{Code: -1121, Message: "Invalid symbol."}, "api"},
+		{&handlers.APIError{Code: binanceTooManyRequests}, "rate_limit"},
+		{context.DeadlineExceeded, "timeout"},
+		{fmt.Errorf("could not parse price: %w", parseErr), "parse"},
+		{errors.New("no ticker returned"), "other"},
+	}
+	for _, tt := range tests {
+		if got := fetchErrorType(tt.err); got != tt.want {
+			t.Errorf("fetchErrorType(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
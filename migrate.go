@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+)
+
+// Schema version written by this release. Files without a version key are
+// version 1.
+const currentConfigVersion = 2
+
+// configMigration upgrades a config document from version From to From+1
+type configMigration struct {
+	From        int
+	Description string
+	Apply       func(doc []byte) ([]byte, error)
+}
+
+// Migrations in order; each one is applied to files older than its target.
+var configMigrations = []configMigration{
+	{1, "rename Pairs and Alerts to pairs and alerts", migrateV1ToV2},
+}
+
+// --- Schema Versions ---
+
+// configVersion returns the schema version of a config document.
+func configVersion(doc []byte) (int, error) {
+	tables, err := scanDocument(doc)
+	if err != nil {
+		return 0, err
+	}
+	e, ok := tables[0].entry("version")
+	if !ok {
+		return 1, nil
+	}
+	v, err := strconv.Atoi(string(doc[e.ValueStart:e.ValueEnd]))
+	if err != nil || v < 1 {
+		return 0, fmt.Errorf("line %d: version must be a positive integer", lineNumber(doc, e.ValueStart))
+	}
+	return v, nil
+}
+
+// migrateConfigData upgrades doc to currentConfigVersion, returning the
+// version it started from. Documents written by a newer release are rejected.
+func migrateConfigData(doc []byte) ([]byte, int, error) {
+	version, err := configVersion(doc)
+	if err != nil {
+		return nil, 0, err
+	}
+	if version > currentConfigVersion {
+		return nil, version, fmt.Errorf("config version %d is newer than this release supports (%d)", version, currentConfigVersion)
+	}
+
+	from := version
+	for _, m := range configMigrations {
+		if m.From != version {
+			continue
+		}
+		if doc, err = m.Apply(doc); err != nil {
+			return nil, from, fmt.Errorf("migrating config from version %d (%s): %w", m.From, m.Description, err)
+		}
+		version = m.From + 1
+		if doc, err = setConfigVersion(doc, version); err != nil {
+			return nil, from, err
+		}
+	}
+	return doc, from, nil
+}
+
+// migrateConfigFile upgrades the config file at path in place. The old
+// version is kept in the backup directory first.
+func migrateConfigFile(path string) error {
+	doc, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	migrated, from, err := migrateConfigData(doc)
+	if err != nil {
+		return err
+	}
+	if from == currentConfigVersion {
+		return nil
+	}
+
+	if err := backupConfigFile(path); err != nil {
+		return fmt.Errorf("could not back up config before migrating: %w", err)
+	}
+	if err := writeFileAtomic(path, migrated); err != nil {
+		return err
+	}
+	log.Printf("Migrated config from version %d to %d", from, currentConfigVersion)
+	return nil
+}
+
+// setConfigVersion sets the version key, adding it above the first top-level
+// entry so it is the first setting in the file.
+func setConfigVersion(doc []byte, version int) ([]byte, error) {
+	tables, err := scanDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+	root := tables[0]
+	if _, ok := root.entry("version"); ok || len(root.Entries) == 0 {
+		return setTopLevelValue(doc, "version", strconv.Itoa(version))
+	}
+	pos := root.Entries[0].LineStart
+	return splice(doc, pos, pos, fmt.Sprintf("version = %d\n\n", version)), nil
+}
+
+// --- Migrations ---
+
+// Commented-out example tables, e.g. "# [[Alerts]]"
+var commentedAlertsHeader = regexp.MustCompile(`(?m)^(\s*#\s*)\[\[Alerts\]\]`)
+
+// migrateV1ToV2 renames the capitalized Pairs and Alerts keys to the snake
+// case used by every other key, including commented-out examples.
+func migrateV1ToV2(doc []byte) ([]byte, error) {
+	doc, err := renameTopLevelKey(doc, "Pairs", "pairs")
+	if err != nil {
+		return nil, err
+	}
+	if doc, err = renameTopLevelKey(doc, "Alerts", "alerts"); err != nil {
+		return nil, err
+	}
+	if doc, err = renameTables(doc, "Alerts", "alerts"); err != nil {
+		return nil, err
+	}
+	return commentedAlertsHeader.ReplaceAll(doc, []byte("${1}[[alerts]]")), nil
+}
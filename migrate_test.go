@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the .golden.toml files of testdata/migrate")
+
+// Every testdata/migrate/<name>.toml is migrated and compared with
+// <name>.golden.toml; files already at the current version must not change.
+func TestMigrateConfigFixtures(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "migrate", "*.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range files {
+		if strings.HasSuffix(path, ".golden.toml") {
+			continue
+		}
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			input, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			migrated, from, err := migrateConfigData(input)
+			if err != nil {
+				t.Fatalf("migrateConfigData: %v", err)
+			}
+
+			if from == currentConfigVersion {
+				if !bytes.Equal(migrated, input) {
+					t.Errorf("current version file changed by migration:\n%s", migrated)
+				}
+			} else {
+				golden := strings.TrimSuffix(path, ".toml") + ".golden.toml"
+				if *updateGolden {
+					if err := os.WriteFile(golden, migrated, 0644); err != nil {
+						t.Fatal(err)
+					}
+				}
+				want, err := os.ReadFile(golden)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(migrated, want) {
+					t.Errorf("migrated config differs from %s:\n%s", golden, migrated)
+				}
+			}
+
+			version, err := configVersion(migrated)
+			if err != nil || version != currentConfigVersion {
+				t.Errorf("configVersion = %d, %v; want %d", version, err, currentConfigVersion)
+			}
+			cfg, problems := checkConfigData(migrated)
+			for _, p := range problems {
+				t.Errorf("problem in migrated config: %s", p)
+			}
+			if cfg == nil || len(cfg.Pairs) == 0 {
+				t.Errorf("migrated config has no pairs")
+			}
+		})
+	}
+}
+
+func TestMigrateConfigKeepsValues(t *testing.T) {
+	input, err := os.ReadFile(filepath.Join("testdata", "migrate", "v1_marshaled.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrated, from, err := migrateConfigData(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != 1 {
+		t.Errorf("from = %d, want 1", from)
+	}
+
+	cfg, _ := checkConfigData(migrated)
+	if cfg == nil {
+		t.Fatal("migrated config does not decode")
+	}
+	if got := strings.Join(cfg.Pairs, ","); got != "BTCUSDC,ETHUSDC,SOLUSDC" {
+		t.Errorf("pairs = %s", got)
+	}
+	if cfg.PinnedPair != "ETHUSDC" {
+		t.Errorf("pinned_pair = %q", cfg.PinnedPair)
+	}
+	if len(cfg.Alerts) != 2 || cfg.Alerts[1].ID != "eth-dip" || cfg.Alerts[1].Target != 2000 || cfg.Alerts[1].Active {
+		t.Errorf("alerts = %+v", cfg.Alerts)
+	}
+}
+
+func TestMigrateConfigRejectsNewerVersion(t *testing.T) {
+	_, from, err := migrateConfigData([]byte("version = 99\npairs = [\"BTCUSDC\"]\n"))
+	if err == nil {
+		t.Fatal("expected an error for a newer config version")
+	}
+	if from != 99 {
+		t.Errorf("from = %d, want 99", from)
+	}
+}
+
+func TestMigrateConfigFileBacksUp(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "config"))
+	t.Setenv("AppData", filepath.Join(dir, "config"))
+
+	input, err := os.ReadFile(filepath.Join("testdata", "migrate", "v1_default.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, ".criptomenu.toml")
+	if err := os.WriteFile(path, input, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := migrateConfigFile(path); err != nil {
+		t.Fatal(err)
+	}
+	migrated, _ := os.ReadFile(path)
+	if v, _ := configVersion(migrated); v != currentConfigVersion {
+		t.Errorf("file version = %d after migration", v)
+	}
+
+	backups, err := listConfigBackups(path)
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("backups = %v, %v; want one backup", backups, err)
+	}
+	saved, _ := os.ReadFile(backups[0].Path)
+	if !bytes.Equal(saved, input) {
+		t.Error("backup does not contain the original file")
+	}
+
+	// A second run has nothing to migrate
+	if err := migrateConfigFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := os.ReadFile(path); !bytes.Equal(again, migrated) {
+		t.Error("second migration changed the file")
+	}
+}
@@ -4,16 +4,11 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os/exec"
-	"runtime"
 	"strconv"
-	"strings"
 	"sync"
 	"time"
 
 	binance_connector "github.com/binance/binance-connector-go"
-	"github.com/gen2brain/beeep"
-	"github.com/getlantern/systray"
 )
 
 var (
@@ -21,88 +16,81 @@ var (
 	currentPair      string
 	currentPairMutex sync.RWMutex
 
-	// Price Cache
-	latestPrices      = make(map[string]float64)
+	// Price Cache (restored from disk at startup, see pricecache.go)
+	latestPrices      = make(map[string]PriceEntry)
 	latestPricesMutex sync.RWMutex
 
 	// Channel to trigger immediate price update
 	updateChan = make(chan struct{}, 1)
 )
 
+const (
+	// Base URL of the Binance Spot REST API
+	binanceBaseURL = "https://api.binance.com"
+
+	// How long each pair is displayed before rotating to the next one
+	pairRotationInterval = 10 * time.Second
+)
+
 // --- Core Logic ---
 
 func rotatePairs() {
 	log.Println("Pair rotation started.")
-	ticker := time.NewTicker(10 * time.Second)
+	ticker := time.NewTicker(pairRotationInterval)
 	defer ticker.Stop()
 
 	for range ticker.C {
-		configMutex.RLock()
-		pairs := activeConfig.Pairs
-	
-pinned := activeConfig.PinnedPair
-		configMutex.RUnlock()
-
-		// Check if pinned
-		if pinned != "" {
-			setPair(pinned)
-			
-			// Update UI immediately from cache
-			latestPricesMutex.RLock()
-			price, ok := latestPrices[pinned]
-			latestPricesMutex.RUnlock()
-
-			if ok {
-				roundedPrice := fmt.Sprintf("%.2f", price)
-				systray.SetTitle(fmt.Sprintf("%s: %s", pinned, roundedPrice))
-			} else {
-				systray.SetTitle(fmt.Sprintf("%s: ...", pinned))
-			}
-			continue
-		}
-
-		if len(pairs) <= 1 {
+		current := getPair()
+		next := nextDisplayPair(current)
+		if next == current && !isPinned(current) {
+			// Nothing to rotate, but keep the stale indicator up to date
+			refreshTitle(current)
 			continue
 		}
+		setPair(next)
 
-		current := getPair()
-		nextIndex := 0
-		found := false
-
-		// Find current index
-		for i, p := range pairs {
-			if p == current {
-				nextIndex = (i + 1) % len(pairs)
-				found = true
-				break
-			}
-		}
-		
-		// If current pair isn't in list (e.g. config changed), start at 0
-		if !found {
-			nextIndex = 0
-		}
+		// Update UI immediately from cache
+		refreshTitle(next)
+		refreshIcon(next)
+	}
+}
 
-		nextPair := pairs[nextIndex]
-		setPair(nextPair)
+// nextDisplayPair returns the pair to display after current: the pinned pair
+// if there is one, else the next configured pair in rotation. It returns
+// current when there is nothing to rotate.
+func nextDisplayPair(current string) string {
+	configMutex.RLock()
+	pairs := activeConfig.Pairs
+	pinned := activeConfig.PinnedPair
+	configMutex.RUnlock()
 
-		// Update UI immediately from cache
-		latestPricesMutex.RLock()
-		price, ok := latestPrices[nextPair]
-		latestPricesMutex.RUnlock()
+	// Check if pinned
+	if pinned != "" {
+		return pinned
+	}
+	if len(pairs) == 0 {
+		return current
+	}
 
-		if ok {
-			roundedPrice := fmt.Sprintf("%.2f", price)
-			systray.SetTitle(fmt.Sprintf("%s: %s", nextPair, roundedPrice))
-		} else {
-			systray.SetTitle(fmt.Sprintf("%s: ...", nextPair))
+	// Find current index; if current isn't in list (e.g. config changed), start
+	// at 0. A single pair is its own successor.
+	for i, p := range pairs {
+		if p == current {
+			return pairs[(i+1)%len(pairs)]
 		}
 	}
+	return pairs[0]
+}
+
+func isPinned(pair string) bool {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+	return pair != "" && activeConfig.PinnedPair == pair
 }
 
 func fetchPrices() {
 	log.Println("Price fetching goroutine started.")
-	client := binance_connector.NewClient("", "", "https://api.binance.com")
+	client := binance_connector.NewClient("", "", binanceBaseURL)
 
 	// Initial update
 	updatePrice(client)
@@ -152,43 +140,77 @@ func updatePrice(client *binance_connector.Client) {
 
 	// Fetch prices for all identified pairs
 	for pair := range pairsToFetch {
-		res, err := client.NewTickerPriceService().Symbol(pair).Do(context.Background())
-		
-		// Handle fetch error
+		entry, err := fetchTicker(client, pair)
 		if err != nil {
 			log.Printf("Error fetching %s price: %v", pair, err)
 			continue
 		}
 
-		// Process response
-		if len(res) > 0 {
-			priceStr := res[0].Price
-			priceFloat, err := strconv.ParseFloat(priceStr, 64)
-			if err != nil {
-				log.Printf("Error parsing price string for %s: %v", pair, err)
-				continue
-			}
+		// Let the tray, terminal views etc. show the new price
+		notifyPrice(pair, entry)
 
-			// Update Cache
-			latestPricesMutex.Lock()
-			latestPrices[pair] = priceFloat
-			latestPricesMutex.Unlock()
+		// Check alerts for this pair (always, for background monitoring)
+		checkAlerts(pair, entry.Price)
+	}
+}
 
-			// Update UI ONLY if this is the currently selected pair
-			if pair == getPair() {
-				roundedPrice := fmt.Sprintf("%.2f", priceFloat)
-				systray.SetTitle(fmt.Sprintf("%s: %s", pair, roundedPrice))
-				systray.SetTooltip(fmt.Sprintf("%s: %s", pair, roundedPrice))
-			}
+// fetchTicker fetches the last price and 24h change of pair from the 24hr
+// ticker and stores them in the price cache.
+func fetchTicker(client *binance_connector.Client, pair string) (entry PriceEntry, err error) {
+	start := time.Now()
+	defer func() { recordFetch(pair, start, err) }()
+	res, err := client.NewTicker24hrService().Symbol(pair).Do(context.Background())
+	if err != nil {
+		return PriceEntry{}, err
+	}
+	if len(res) == 0 {
+		return PriceEntry{}, fmt.Errorf("no ticker returned for %s", pair)
+	}
 
-			// Check alerts for this pair (always, for background monitoring)
-			checkAlerts(pair, priceFloat)
-		}
+	priceFloat, err := strconv.ParseFloat(res[0].LastPrice, 64)
+	if err != nil {
+		return PriceEntry{}, fmt.Errorf("could not parse price %q: %w", res[0].LastPrice, err)
 	}
+	changePercent, err := strconv.ParseFloat(res[0].PriceChangePercent, 64)
+	if err != nil {
+		log.Printf("Error parsing 24h change for %s: %v", pair, err)
+	}
+
+	// Update Cache
+	entry = PriceEntry{Price: priceFloat, ChangePercent: changePercent, UpdatedAt: time.Now()}
+	latestPricesMutex.Lock()
+	latestPrices[pair] = entry
+	latestPricesMutex.Unlock()
+	return entry, nil
+}
+
+// alertTriggered reports whether price meets the alert's condition.
+func alertTriggered(alert Alert, price float64) bool {
+	switch alert.Condition {
+	case "above":
+		return price >= alert.Target
+	case "below":
+		return price <= alert.Target
+	}
+	return false
 }
 
-// checkAlerts iterates through configured alerts and triggers notifications if conditions are met.
+// alertMessage is the notification text of a triggered alert.
+func alertMessage(alert Alert, price float64) string {
+	return fmt.Sprintf("%s ha raggiunto %s (Target: %s)", alert.Pair, formatPairPrice(alert.Pair, price), formatPairPrice(alert.Pair, alert.Target))
+}
+
+// checkAlerts iterates through configured alerts and notifies the alert
+// listeners when conditions are met.
 func checkAlerts(pair string, price float64) {
+	var triggered []Alert
+	defer func() {
+		// Listeners run without the config lock held
+		for _, alert := range triggered {
+			notifyAlert(alert, price)
+		}
+	}()
+
 	configMutex.Lock()
 	defer configMutex.Unlock()
 
@@ -203,48 +225,11 @@ func checkAlerts(pair string, price float64) {
 			continue
 		}
 
-		triggered := false
-		if alert.Condition == "above" && price >= alert.Target {
-			triggered = true
-		} else if alert.Condition == "below" && price <= alert.Target {
-			triggered = true
-		}
+		if alertTriggered(alert, price) {
+			log.Printf("ALERT TRIGGERED: %s", alertMessage(alert, price))
 
-		if triggered {
-			msg := fmt.Sprintf("%s ha raggiunto %.2f (Target: %.2f)", pair, price, alert.Target)
-			log.Printf("ALERT TRIGGERED: %s", msg)
-			
-			// Fire notification
-			// Icon path is empty to use default or system icon
-			if runtime.GOOS == "darwin" {
-				// Use osascript display alert (modal) for better visibility
-				// Run in goroutine to not block price updates while waiting for user to dismiss
-				go func(message string) {
-					// Escape double quotes in the message to prevent script errors
-					safeMsg := strings.ReplaceAll(message, "\"", "\\\"")
-					
-					iconPath := "/Users/antedoro/Desktop/CriptoMenu-golang/icon.png"
-					script := fmt.Sprintf(`
-set iconPath to POSIX file "%s"
-try
-	beep
-	display dialog "%s" with title "CriptoMenu Alert" buttons {"OK"} default button "OK" with icon iconPath
-on error
-	beep
-	display alert "CriptoMenu Alert" message "%s"
-end try`, iconPath, safeMsg, safeMsg)
-
-					err := exec.Command("osascript", "-e", script).Run()
-					if err != nil {
-						log.Printf("Error sending macOS alert: %v", err)
-					}
-				}(msg)
-			} else {
-				err := beeep.Notify("CriptoMenu Alert", msg, "")
-				if err != nil {
-					log.Printf("Error sending notification: %v", err)
-				}
-			}
+			// Notifications are sent by the listeners (tray, daemon, ...)
+			triggered = append(triggered, alert)
 
 			// Deactivate alert
 			// cfg.Alerts[i].Active = false
@@ -253,8 +238,8 @@ end try`, iconPath, safeMsg, safeMsg)
 	}
 
 	if alertsChanged {
-		// Save config to persist the deactivated state
-		err := saveConfigInternal(cfg)
+		// Persist the deactivated state, keeping the rest of the file as is
+		err := saveAlertStates(cfg.Alerts)
 		if err != nil {
 			log.Printf("Error saving config after alert trigger: %v", err)
 		}
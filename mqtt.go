@@ -0,0 +1,417 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+const (
+	// Defaults of the [mqtt] table
+	defaultMQTTTopicPrefix     = "criptomenu"
+	defaultMQTTDiscoveryPrefix = "homeassistant"
+
+	// How long to wait for the broker when connecting and on exit
+	mqttTimeout = 5 * time.Second
+)
+
+// MQTTConfig is the [mqtt] table: prices, 24h changes and alert states
+// published to an MQTT broker, with Home Assistant discovery
+type MQTTConfig struct {
+	Broker          string `toml:"broker"`              // e.g. "tcp://localhost:1883", "ssl://broker:8883"
+	ClientID        string `toml:"client_id,omitempty"` // Default "criptomenu-<hostname>"
+	Username        string `toml:"username,omitempty"`
+	Password        string `toml:"password,omitempty"`         // Or CRIPTOMENU_MQTT_PASSWORD
+	TopicPrefix     string `toml:"topic_prefix,omitempty"`     // Default "criptomenu"
+	QoS             int    `toml:"qos,omitempty"`              // 0 (default), 1 or 2
+	Retain          *bool  `toml:"retain,omitempty"`           // Retain states, default true
+	Events          *bool  `toml:"events,omitempty"`           // Publish triggered alerts, default true
+	Discovery       *bool  `toml:"discovery,omitempty"`        // Home Assistant discovery, default true
+	DiscoveryPrefix string `toml:"discovery_prefix,omitempty"` // Default "homeassistant"
+}
+
+// mqttSettings is an [mqtt] table with the defaults applied
+type mqttSettings struct {
+	Broker, ClientID, Username, Password string
+	TopicPrefix, DiscoveryPrefix         string
+	QoS                                  byte
+	Retain, Events, Discovery            bool
+}
+
+// settings returns the resolved settings, or ok == false when no broker is set.
+func (c *MQTTConfig) settings() (s mqttSettings, ok bool) {
+	if c == nil || c.Broker == "" {
+		return s, false
+	}
+	s = mqttSettings{
+		Broker:          c.Broker,
+		ClientID:        c.ClientID,
+		Username:        c.Username,
+		Password:        c.Password,
+		TopicPrefix:     strings.TrimSuffix(c.TopicPrefix, "/"),
+		DiscoveryPrefix: strings.TrimSuffix(c.DiscoveryPrefix, "/"),
+		QoS:             byte(min(max(c.QoS, 0), 2)),
+		Retain:          c.Retain == nil || *c.Retain,
+		Events:          c.Events == nil || *c.Events,
+		Discovery:       c.Discovery == nil || *c.Discovery,
+	}
+	if s.ClientID == "" {
+		host, _ := os.Hostname()
+		s.ClientID = "criptomenu-" + host
+	}
+	if s.TopicPrefix == "" {
+		s.TopicPrefix = defaultMQTTTopicPrefix
+	}
+	if s.DiscoveryPrefix == "" {
+		s.DiscoveryPrefix = defaultMQTTDiscoveryPrefix
+	}
+	return s, true
+}
+
+// Topics below the topic prefix
+func (s mqttSettings) statusTopic() string { return s.TopicPrefix + "/status" }
+func (s mqttSettings) eventTopic() string  { return s.TopicPrefix + "/events/alert" }
+func (s mqttSettings) pairTopic(pair, field string) string {
+	return s.TopicPrefix + "/" + pair + "/" + field
+}
+
+// nodeID is the client id as a Home Assistant node id.
+func (s mqttSettings) nodeID() string {
+	return invalidNodeIDChars.ReplaceAllString(s.ClientID, "_")
+}
+
+// URL schemes the client can connect with
+var mqttBrokerSchemes = []string{"tcp", "mqtt", "ssl", "tls", "mqtts", "ws", "wss"}
+
+var invalidNodeIDChars = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+var (
+	// Connection to the broker, replaced when [mqtt] changes. MQTT only runs
+	// in the tray app and the daemon, which set mqttAllowed.
+	mqttMutex    sync.Mutex
+	mqttAllowed  bool
+	mqttClient   mqtt.Client
+	mqttCurrent  mqttSettings
+	mqttAnnounce map[string]bool // Pairs with published discovery configs
+
+	// Serializes updateMQTT and stopMQTT, which wait for the broker without
+	// holding mqttMutex
+	mqttUpdateMutex sync.Mutex
+)
+
+// --- Connection ---
+
+// startMQTT enables MQTT for this process and connects if the config asks
+// for it.
+func startMQTT() {
+	mqttMutex.Lock()
+	mqttAllowed = true
+	mqttMutex.Unlock()
+
+	addPriceListener(publishMQTTPrice)
+	addAlertListener(publishMQTTAlert)
+	updateMQTT()
+}
+
+// updateMQTT connects, disconnects or reconnects to match the active
+// config, and updates the published pairs. Called whenever the config is
+// loaded.
+func updateMQTT() {
+	mqttUpdateMutex.Lock()
+	defer mqttUpdateMutex.Unlock()
+
+	configMutex.RLock()
+	settings, enabled := activeConfig.MQTT.settings()
+	configMutex.RUnlock()
+
+	mqttMutex.Lock()
+	if !mqttAllowed {
+		mqttMutex.Unlock()
+		return
+	}
+	if mqttClient != nil && enabled && settings == mqttCurrent {
+		mqttMutex.Unlock()
+		syncMQTTPairs() // Pairs or alerts may have changed
+		return
+	}
+	old, oldSettings := mqttClient, mqttCurrent
+	mqttClient, mqttCurrent = nil, mqttSettings{}
+	mqttMutex.Unlock()
+
+	// The old client goes offline first: the new one may use the same
+	// client id and status topic
+	if old != nil {
+		disconnectMQTT(old, oldSettings)
+		log.Printf("MQTT: disconnected from %s.", oldSettings.Broker)
+	}
+	if enabled {
+		mqttMutex.Lock()
+		connectMQTT(settings)
+		mqttMutex.Unlock()
+	}
+}
+
+// connectMQTT creates the client and connects in the background, retrying
+// until the broker is reachable. Called with mqttMutex held.
+func connectMQTT(s mqttSettings) {
+	opts := mqtt.NewClientOptions().
+		AddBroker(s.Broker).
+		SetClientID(s.ClientID).
+		SetUsername(s.Username).
+		SetPassword(s.Password).
+		SetConnectTimeout(mqttTimeout).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetMaxReconnectInterval(time.Minute).
+		// Home Assistant marks the sensors unavailable when this process goes away
+		SetWill(s.statusTopic(), "offline", s.QoS, true)
+	opts.SetOnConnectHandler(func(c mqtt.Client) {
+		mqttMutex.Lock()
+		if c != mqttClient {
+			mqttMutex.Unlock()
+			return // Replaced meanwhile
+		}
+		mqttAnnounce = make(map[string]bool) // Announce again, the broker may have lost them
+		mqttMutex.Unlock()
+		log.Printf("MQTT: connected to %s.", s.Broker)
+		publishMQTT(s.statusTopic(), true, "online")
+		syncMQTTPairs()
+	})
+	opts.SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+		log.Printf("MQTT: connection to %s lost: %v", s.Broker, err)
+	})
+
+	mqttClient = mqtt.NewClient(opts)
+	mqttCurrent = s
+	mqttAnnounce = make(map[string]bool)
+	mqttClient.Connect() // Retries in the background
+	log.Printf("MQTT: connecting to %s as %s.", s.Broker, s.ClientID)
+}
+
+// disconnectMQTT marks client offline and closes its connection. Called
+// without mqttMutex, which publishing must not block for up to mqttTimeout;
+// the client has already been taken out of mqttClient.
+func disconnectMQTT(client mqtt.Client, s mqttSettings) {
+	if client.IsConnectionOpen() {
+		client.Publish(s.statusTopic(), s.QoS, true, "offline").WaitTimeout(mqttTimeout)
+	}
+	client.Disconnect(250)
+}
+
+// stopMQTT disconnects from the broker on exit.
+func stopMQTT() {
+	mqttUpdateMutex.Lock()
+	defer mqttUpdateMutex.Unlock()
+
+	mqttMutex.Lock()
+	client, s := mqttClient, mqttCurrent
+	mqttClient, mqttCurrent = nil, mqttSettings{}
+	mqttMutex.Unlock()
+	if client != nil {
+		disconnectMQTT(client, s)
+	}
+}
+
+// --- Publishing ---
+
+// publishMQTT publishes payload if connected. While disconnected messages are
+// dropped; the current states are published again on reconnect.
+func publishMQTT(topic string, retained bool, payload any) {
+	mqttMutex.Lock()
+	client, qos := mqttClient, mqttCurrent.QoS
+	mqttMutex.Unlock()
+	if client == nil || !client.IsConnectionOpen() {
+		return
+	}
+	if err := client.Publish(topic, qos, retained, payload).Error(); err != nil {
+		log.Printf("MQTT: error publishing to %s: %v", topic, err)
+	}
+}
+
+// publishMQTTPrice is the price listener publishing the price, 24h change and
+// alert state of configured pairs.
+func publishMQTTPrice(pair string, entry PriceEntry) {
+	mqttMutex.Lock()
+	s, connected := mqttCurrent, mqttClient != nil
+	mqttMutex.Unlock()
+	if !connected || !slices.Contains(commandPairs(nil), pair) {
+		return
+	}
+	announceMQTTPair(s, pair)
+	publishMQTT(s.pairTopic(pair, "price"), s.Retain, strconv.FormatFloat(entry.Price, 'f', -1, 64))
+	publishMQTT(s.pairTopic(pair, "change"), s.Retain, strconv.FormatFloat(entry.ChangePercent, 'f', 2, 64))
+	publishMQTT(s.pairTopic(pair, "alert"), s.Retain, pairAlertState(pair, entry.Price))
+}
+
+// publishMQTTAlert is the alert listener publishing triggered alerts as
+// events. The payload doubles as a Home Assistant event (event_type).
+func publishMQTTAlert(alert Alert, price float64) {
+	mqttMutex.Lock()
+	s, connected := mqttCurrent, mqttClient != nil
+	mqttMutex.Unlock()
+	if !connected || !s.Events {
+		return
+	}
+	data, err := json.Marshal(map[string]any{
+		"event_type": alert.Condition,
+		"id":         alert.ID,
+		"pair":       alert.Pair,
+		"condition":  alert.Condition,
+		"target":     alert.Target,
+		"price":      price,
+		"message":    alertMessage(alert, price),
+		"time":       time.Now().UTC(),
+	})
+	if err != nil {
+		log.Printf("MQTT: error encoding alert event: %v", err)
+		return
+	}
+	publishMQTT(s.eventTopic(), false, data)
+}
+
+// pairAlertState is "triggered" when an active alert of pair is met at price,
+// "armed" when pair has active alerts, else "none".
+func pairAlertState(pair string, price float64) string {
+	state := "none"
+	for _, a := range activeAlerts() {
+		if a.Pair != pair || !a.Active {
+			continue
+		}
+		if alertTriggered(a, price) {
+			return "triggered"
+		}
+		state = "armed"
+	}
+	return state
+}
+
+// syncMQTTPairs announces the configured pairs, publishes their cached
+// states and removes pairs that are no longer configured.
+func syncMQTTPairs() {
+	pairs := commandPairs(nil)
+	mqttMutex.Lock()
+	s, connected := mqttCurrent, mqttClient != nil && mqttClient.IsConnectionOpen()
+	var removed []string
+	for pair := range mqttAnnounce {
+		if !slices.Contains(pairs, pair) {
+			removed = append(removed, pair)
+			delete(mqttAnnounce, pair)
+		}
+	}
+	mqttMutex.Unlock()
+	if !connected {
+		return
+	}
+
+	for _, pair := range removed {
+		// Empty retained messages delete the sensors and the stored states
+		for _, d := range mqttPairSensors {
+			publishMQTT(s.discoveryTopic("sensor", pair, d.field), true, "")
+			publishMQTT(s.pairTopic(pair, d.field), true, "")
+		}
+		log.Printf("MQTT: removed %s.", pair)
+	}
+	if s.Discovery && s.Events {
+		publishMQTTDiscovery(s.discoveryTopic("event", "", "alert"), map[string]any{
+			"name":        "Alert",
+			"unique_id":   s.nodeID() + "_alert",
+			"state_topic": s.eventTopic(),
+			"event_types": []string{"above", "below"},
+			"icon":        "mdi:bell-ring",
+		}, s)
+	}
+	for _, pair := range pairs {
+		if entry, ok := getCachedPrice(pair); ok {
+			publishMQTTPrice(pair, entry)
+		} else {
+			announceMQTTPair(s, pair)
+		}
+	}
+}
+
+// --- Home Assistant Discovery ---
+
+// mqttPairSensor is a Home Assistant sensor of each pair
+type mqttPairSensor struct {
+	field, name, icon string
+}
+
+var mqttPairSensors = []mqttPairSensor{
+	{"price", "Price", "mdi:currency-usd"},
+	{"change", "24h change", "mdi:percent"},
+	{"alert", "Alert", "mdi:bell"},
+}
+
+// discoveryTopic is the config topic of a Home Assistant entity.
+func (s mqttSettings) discoveryTopic(component, pair, field string) string {
+	object := field
+	if pair != "" {
+		object = strings.ToLower(pair) + "_" + field
+	}
+	return fmt.Sprintf("%s/%s/%s/%s/config", s.DiscoveryPrefix, component, s.nodeID(), object)
+}
+
+// announceMQTTPair publishes the discovery configs of pair once per
+// connection, so Home Assistant creates its sensors.
+func announceMQTTPair(s mqttSettings, pair string) {
+	mqttMutex.Lock()
+	announced := mqttAnnounce[pair]
+	if mqttAnnounce != nil {
+		mqttAnnounce[pair] = true
+	}
+	mqttMutex.Unlock()
+	if announced || !s.Discovery {
+		return
+	}
+
+	name := displayPair(pair)
+	for _, d := range mqttPairSensors {
+		config := map[string]any{
+			"name":        name + " " + d.name,
+			"unique_id":   s.nodeID() + "_" + strings.ToLower(pair) + "_" + d.field,
+			"state_topic": s.pairTopic(pair, d.field),
+			"icon":        d.icon,
+		}
+		switch d.field {
+		case "price":
+			config["state_class"] = "measurement"
+			if _, quote, ok := splitPair(pair); ok {
+				config["unit_of_measurement"] = quote
+			}
+		case "change":
+			config["state_class"] = "measurement"
+			config["unit_of_measurement"] = "%"
+		case "alert":
+			config["device_class"] = "enum"
+			config["options"] = []string{"none", "armed", "triggered"}
+		}
+		publishMQTTDiscovery(s.discoveryTopic("sensor", pair, d.field), config, s)
+	}
+}
+
+// publishMQTTDiscovery adds the device and availability to an entity config
+// and publishes it retained, as Home Assistant expects.
+func publishMQTTDiscovery(topic string, config map[string]any, s mqttSettings) {
+	config["availability_topic"] = s.statusTopic()
+	config["device"] = map[string]any{
+		"identifiers":  []string{s.nodeID()},
+		"name":         "CriptoMenu",
+		"manufacturer": "CriptoMenu",
+		"sw_version":   CurrentVersion,
+	}
+	data, err := json.Marshal(config)
+	if err != nil {
+		log.Printf("MQTT: error encoding discovery config: %v", err)
+		return
+	}
+	publishMQTT(topic, true, data)
+}
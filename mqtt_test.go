@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+)
+
+// startTestBroker runs an embedded broker and returns its URL and a function
+// waiting for a message on a topic: one with payload want, or any when want
+// is empty. It returns the last payload received on the topic.
+func startTestBroker(t *testing.T) (string, func(topic, want string) (string, bool)) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	server := mochi.New(&mochi.Options{InlineClient: true, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+	server.AddHook(new(auth.AllowHook), nil)
+	if err := server.AddListener(listeners.NewTCP(listeners.Config{ID: "test", Address: addr})); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Serve(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	var mu sync.Mutex
+	messages := make(map[string]string)
+	err = server.Subscribe("#", 1, func(_ *mochi.Client, _ packets.Subscription, pk packets.Packet) {
+		mu.Lock()
+		messages[pk.TopicName] = string(pk.Payload)
+		mu.Unlock()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Messages arrive asynchronously
+	return "tcp://" + addr, func(topic, want string) (string, bool) {
+		var payload string
+		var ok bool
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+			mu.Lock()
+			payload, ok = messages[topic]
+			mu.Unlock()
+			if ok && (want == "" || payload == want) {
+				return payload, true
+			}
+		}
+		return payload, false
+	}
+}
+
+func TestMQTTPublishing(t *testing.T) {
+	setAPITestState(t)
+	broker, message := startTestBroker(t)
+
+	configMutex.Lock()
+	activeConfig.MQTT = &MQTTConfig{Broker: broker, ClientID: "test.host"}
+	configMutex.Unlock()
+	startMQTT()
+	t.Cleanup(func() {
+		stopMQTT()
+		mqttMutex.Lock()
+		mqttAllowed = false
+		mqttMutex.Unlock()
+	})
+
+	// The cached BTCUSDC price is published on connect
+	if got, _ := message("criptomenu/status", "online"); got != "online" {
+		t.Errorf("status = %q, want online", got)
+	}
+	if got, _ := message("criptomenu/BTCUSDC/price", "92430.5"); got != "92430.5" {
+		t.Errorf("price = %q", got)
+	}
+	if got, _ := message("criptomenu/BTCUSDC/change", "1.50"); got != "1.50" {
+		t.Errorf("change = %q", got)
+	}
+	if got, _ := message("criptomenu/BTCUSDC/alert", "armed"); got != "armed" {
+		t.Errorf("alert state = %q, want armed", got)
+	}
+
+	payload, ok := message("homeassistant/sensor/test_host/btcusdc_price/config", "")
+	if !ok {
+		t.Fatal("no discovery config for the BTCUSDC price")
+	}
+	var config struct {
+		UniqueID          string `json:"unique_id"`
+		StateTopic        string `json:"state_topic"`
+		AvailabilityTopic string `json:"availability_topic"`
+	}
+	if err := json.Unmarshal([]byte(payload), &config); err != nil {
+		t.Fatal(err)
+	}
+	if config.UniqueID != "test_host_btcusdc_price" || config.StateTopic != "criptomenu/BTCUSDC/price" || config.AvailabilityTopic != "criptomenu/status" {
+		t.Errorf("discovery config = %+v", config)
+	}
+	// Pairs without a price are announced too
+	if _, ok := message("homeassistant/sensor/test_host/ethusdc_price/config", ""); !ok {
+		t.Error("no discovery config for ETHUSDC")
+	}
+
+	notifyPrice("BTCUSDC", PriceEntry{Price: 100500, ChangePercent: 4.2, UpdatedAt: time.Now()})
+	notifyAlert(activeAlerts()[0], 100500)
+	if got, _ := message("criptomenu/BTCUSDC/alert", "triggered"); got != "triggered" {
+		t.Errorf("alert state = %q, want triggered", got)
+	}
+	payload, _ = message("criptomenu/events/alert", "")
+	var event struct {
+		EventType string  `json:"event_type"`
+		ID        string  `json:"id"`
+		Price     float64 `json:"price"`
+	}
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.EventType != "above" || event.ID != "btc-100k" || event.Price != 100500 {
+		t.Errorf("alert event = %+v", event)
+	}
+}
+
+func TestMQTTReconnect(t *testing.T) {
+	setAPITestState(t)
+	broker, message := startTestBroker(t)
+
+	configMutex.Lock()
+	activeConfig.MQTT = &MQTTConfig{Broker: broker, ClientID: "test.host"}
+	configMutex.Unlock()
+	startMQTT()
+	t.Cleanup(func() {
+		stopMQTT()
+		mqttMutex.Lock()
+		mqttAllowed = false
+		mqttMutex.Unlock()
+	})
+	if got, _ := message("criptomenu/status", "online"); got != "online" {
+		t.Fatalf("status = %q, want online", got)
+	}
+
+	// The old connection goes offline before the new one comes up
+	configMutex.Lock()
+	activeConfig.MQTT = &MQTTConfig{Broker: broker, ClientID: "test.host", TopicPrefix: "prices"}
+	configMutex.Unlock()
+	updateMQTT()
+	if got, _ := message("criptomenu/status", "offline"); got != "offline" {
+		t.Errorf("old status = %q, want offline", got)
+	}
+	if got, _ := message("prices/status", "online"); got != "online" {
+		t.Errorf("new status = %q, want online", got)
+	}
+
+	stopMQTT()
+	if got, _ := message("prices/status", "offline"); got != "offline" {
+		t.Errorf("status after stopping = %q, want offline", got)
+	}
+	mqttMutex.Lock()
+	defer mqttMutex.Unlock()
+	if mqttClient != nil {
+		t.Error("client left after stopping")
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gen2brain/beeep"
+	"github.com/getlantern/systray"
+)
+
+const (
+	// Maximum edit distance for "did you mean" suggestions
+	maxSuggestionDistance = 2
+	maxSuggestions        = 3
+)
+
+// PairProblem describes a configured pair that cannot be monitored as expected
+type PairProblem struct {
+	Pair        string
+	Source      string   // Where the pair is configured: "pairs", "alert" or "pinned_pair"
+	Kind        string   // "unknown", "halted" or "delisted"
+	Status      string   // Exchange status, e.g. "BREAK"
+	Suggestions []string // Close matches for unknown pairs
+}
+
+// String renders the problem for menus, logs and notifications.
+func (p PairProblem) String() string {
+	var msg string
+	switch p.Kind {
+	case "unknown":
+		msg = fmt.Sprintf("%s (%s): unknown symbol", p.Pair, p.Source)
+	case "halted":
+		msg = fmt.Sprintf("%s (%s): trading halted (%s)", p.Pair, p.Source, p.Status)
+	default:
+		msg = fmt.Sprintf("%s (%s): not trading, possibly delisted (%s)", p.Pair, p.Source, p.Status)
+	}
+	if len(p.Suggestions) > 0 {
+		msg += ", did you mean " + strings.Join(p.Suggestions, ", ") + "?"
+	}
+	return msg
+}
+
+var (
+	// Current pair problems and their menu items, all guarded by
+	// pairProblemsMutex as both the config watcher and the exchange info
+	// refresh update them
+	pairProblems      []PairProblem
+	pairProblemsMutex sync.Mutex
+	mPairWarnings     *systray.MenuItem
+	pairWarningItems  []*systray.MenuItem
+
+	// Last notified problem set, to notify only on changes
+	lastPairProblemsKey string
+)
+
+// --- Pair Validation ---
+
+// validateConfiguredPairs checks every pair of the active config (pairs,
+// alert pairs and the pinned pair) against the exchange symbol list. It does
+// nothing until the exchange metadata is available.
+func validateConfiguredPairs() {
+	symbolInfoMutex.RLock()
+	loaded := len(symbolInfo) > 0
+	symbolInfoMutex.RUnlock()
+	if !loaded {
+		return
+	}
+
+	configMutex.RLock()
+	if activeConfig == nil {
+		configMutex.RUnlock()
+		return
+	}
+	type source struct{ pair, from string }
+	var sources []source
+	for _, p := range activeConfig.Pairs {
+		sources = append(sources, source{p, "pairs"})
+	}
+	for _, a := range activeConfig.Alerts {
+		sources = append(sources, source{a.Pair, "alert"})
+	}
+	if activeConfig.PinnedPair != "" {
+		sources = append(sources, source{activeConfig.PinnedPair, "pinned_pair"})
+	}
+	configMutex.RUnlock()
+
+	var problems []PairProblem
+	seen := make(map[string]bool)
+	for _, s := range sources {
+		if seen[s.pair] {
+			continue
+		}
+		seen[s.pair] = true
+		if problem, ok := checkPair(s.pair); !ok {
+			problem.Source = s.from
+			problems = append(problems, problem)
+		}
+	}
+
+	setPairProblems(problems)
+}
+
+// checkPair validates a single symbol; ok is false when there is a problem.
+func checkPair(pair string) (PairProblem, bool) {
+	meta, found := getSymbolMeta(pair)
+	switch {
+	case !found:
+		return PairProblem{Pair: pair, Kind: "unknown", Suggestions: suggestPairs(pair)}, false
+	case meta.Status == "BREAK":
+		return PairProblem{Pair: pair, Kind: "halted", Status: meta.Status}, false
+	case meta.Status != "TRADING":
+		return PairProblem{Pair: pair, Kind: "delisted", Status: meta.Status}, false
+	}
+	return PairProblem{}, true
+}
+
+// suggestPairs returns the trading symbols closest to pair by edit distance.
+func suggestPairs(pair string) []string {
+	type candidate struct {
+		symbol   string
+		distance int
+	}
+	upper := strings.ToUpper(strings.TrimSpace(pair))
+
+	symbolInfoMutex.RLock()
+	var candidates []candidate
+	for symbol, meta := range symbolInfo {
+		if meta.Status != "TRADING" {
+			continue
+		}
+		if d := levenshtein(upper, symbol); d <= maxSuggestionDistance {
+			candidates = append(candidates, candidate{symbol, d})
+		}
+	}
+	symbolInfoMutex.RUnlock()
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].symbol < candidates[j].symbol
+	})
+
+	var res []string
+	for i := 0; i < len(candidates) && i < maxSuggestions; i++ {
+		res = append(res, candidates[i].symbol)
+	}
+	return res
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// setPairProblems stores the problems, updates the warning menu and notifies
+// the user when the set of problems changed.
+func setPairProblems(problems []PairProblem) {
+	var lines []string
+	for _, p := range problems {
+		lines = append(lines, p.String())
+	}
+	key := strings.Join(lines, "\n")
+
+	pairProblemsMutex.Lock()
+	pairProblems = problems
+	changed := key != lastPairProblemsKey
+	lastPairProblemsKey = key
+	pairProblemsMutex.Unlock()
+
+	updatePairWarningsMenu()
+
+	if !changed || len(problems) == 0 {
+		return
+	}
+	for _, line := range lines {
+		log.Printf("Pair warning: %s", line)
+	}
+	// A notification rather than a modal alert: the problems stay listed in
+	// the "Pair Warnings" menu
+	if !trayRunning {
+		return
+	}
+	msg := fmt.Sprintf("%d configured pair(s) cannot be monitored:\n%s", len(problems), key)
+	if err := beeep.Notify("CriptoMenu Pair Warning", msg, ""); err != nil {
+		log.Printf("Error sending notification: %v", err)
+	}
+}
+
+// --- Warning Menu ---
+
+// addPairWarningsMenu adds the "Pair Warnings" menu item, only visible when
+// configured pairs have problems.
+func addPairWarningsMenu() {
+	item := systray.AddMenuItem("Pair Warnings", "Configured pairs that cannot be monitored")
+	pairProblemsMutex.Lock()
+	mPairWarnings = item
+	pairProblemsMutex.Unlock()
+	updatePairWarningsMenu()
+}
+
+// updatePairWarningsMenu shows one menu entry per pair problem below a
+// "Pair Warnings" parent, hiding the whole section when there are none.
+func updatePairWarningsMenu() {
+	pairProblemsMutex.Lock()
+	defer pairProblemsMutex.Unlock()
+
+	if mPairWarnings == nil {
+		return
+	}
+	if len(pairProblems) == 0 {
+		mPairWarnings.Hide()
+		return
+	}
+	mPairWarnings.SetTitle(fmt.Sprintf("⚠ Pair Warnings (%d)", len(pairProblems)))
+	mPairWarnings.Show()
+
+	for i := len(pairWarningItems); i < len(pairProblems); i++ {
+		item := mPairWarnings.AddSubMenuItem("", "")
+		pairWarningItems = append(pairWarningItems, item)
+		go func(it *systray.MenuItem) {
+			for range it.ClickedCh {
+				openConfigInEditor()
+			}
+		}(item)
+	}
+
+	for i, item := range pairWarningItems {
+		if i < len(pairProblems) {
+			item.SetTitle(pairProblems[i].String())
+			item.SetTooltip("Edit the config to fix this pair")
+			item.Show()
+		} else {
+			item.Hide()
+		}
+	}
+}
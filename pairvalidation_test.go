@@ -0,0 +1,129 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"BTCUSDC", "BTCUSDC", 0},
+		{"BTCUSC", "BTCUSDC", 1},
+		{"BTCUDSC", "BTCUSDC", 2},
+		{"ETHUSDC", "BTCUSDC", 2},
+		{"", "BTC", 3},
+		{"SOL", "", 3},
+	}
+	for _, tt := range tests {
+		if got := levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := levenshtein(tt.b, tt.a); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+// setTestPairSymbols installs a small symbol list with a halted and a
+// delisted symbol.
+func setTestPairSymbols(t *testing.T) {
+	symbolInfoMutex.RLock()
+	oldInfo, oldFetchedAt := symbolInfo, symbolInfoFetchedAt
+	symbolInfoMutex.RUnlock()
+	symbols := map[string]SymbolMeta{}
+	for symbol, status := range map[string]string{
+		"BTCUSDC":  "TRADING",
+		"BTCUSDT":  "TRADING",
+		"ETHUSDC":  "TRADING",
+		"BTCUSD":   "BREAK",
+		"LUNAUSDC": "BREAK",
+		"FTTUSDC":  "END_OF_DAY",
+	} {
+		symbols[symbol] = SymbolMeta{Symbol: symbol, Status: status}
+	}
+	setSymbolInfo(symbols, time.Now())
+	t.Cleanup(func() { setSymbolInfo(oldInfo, oldFetchedAt) })
+}
+
+func TestSuggestPairs(t *testing.T) {
+	setTestPairSymbols(t)
+	tests := []struct {
+		pair string
+		want []string
+	}{
+		{"BTCUSC", []string{"BTCUSDC", "BTCUSDT"}},
+		{" btcusdc ", []string{"BTCUSDC", "BTCUSDT", "ETHUSDC"}},
+		// Halted symbols are not suggested
+		{"BTCUS", []string{"BTCUSDC", "BTCUSDT"}},
+		{"DOGEEUR", nil},
+	}
+	for _, tt := range tests {
+		if got := suggestPairs(tt.pair); !slices.Equal(got, tt.want) {
+			t.Errorf("suggestPairs(%q) = %v, want %v", tt.pair, got, tt.want)
+		}
+	}
+}
+
+func TestCheckPair(t *testing.T) {
+	setTestPairSymbols(t)
+	tests := []struct {
+		pair, kind string
+	}{
+		{"BTCUSDC", ""},
+		{"LUNAUSDC", "halted"},
+		{"FTTUSDC", "delisted"},
+		{"BTCUSC", "unknown"},
+	}
+	for _, tt := range tests {
+		problem, ok := checkPair(tt.pair)
+		if ok != (tt.kind == "") || problem.Kind != tt.kind {
+			t.Errorf("checkPair(%s) = %+v, %v; want kind %q", tt.pair, problem, ok, tt.kind)
+		}
+	}
+
+	problem, _ := checkPair("LUNAUSDC")
+	if got, want := problem.String(), "LUNAUSDC (): trading halted (BREAK)"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	problem, _ = checkPair("BTCUSC")
+	problem.Source = "alert"
+	if got, want := problem.String(), "BTCUSC (alert): unknown symbol, did you mean BTCUSDC, BTCUSDT?"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestValidateConfiguredPairs(t *testing.T) {
+	setTestPairSymbols(t)
+	configMutex.Lock()
+	old := activeConfig
+	activeConfig = &Config{
+		Pairs:      []string{"BTCUSDC", "BTCUSC", "LUNAUSDC"},
+		Alerts:     []Alert{{Pair: "FTTUSDC"}, {Pair: "BTCUSC"}},
+		PinnedPair: "ETHUSDT",
+	}
+	configMutex.Unlock()
+	t.Cleanup(func() {
+		configMutex.Lock()
+		activeConfig = old
+		configMutex.Unlock()
+		setPairProblems(nil)
+	})
+
+	validateConfiguredPairs()
+	pairProblemsMutex.Lock()
+	var got []string
+	for _, p := range pairProblems {
+		got = append(got, p.Pair+" "+p.Source+" "+p.Kind)
+	}
+	pairProblemsMutex.Unlock()
+	// Each pair is reported once, for the first place it is configured
+	want := []string{"BTCUSC pairs unknown", "LUNAUSDC pairs halted", "FTTUSDC alert delisted", "ETHUSDT pinned_pair unknown"}
+	if !slices.Equal(got, want) {
+		t.Errorf("problems = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// Default age after which a cached price is considered stale
+	defaultStaleAfter = 2 * time.Minute
+
+	// How often the price cache is flushed to disk
+	priceCacheSaveInterval = 1 * time.Minute
+)
+
+// PriceEntry is a cached price together with the time it was fetched
+type PriceEntry struct {
+	Price         float64   `json:"price"`
+	ChangePercent float64   `json:"change_percent"` // 24h change
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// --- Cache Helpers ---
+
+// getCacheDir returns the per-user directory used for persisted app state,
+// creating it if needed.
+func getCacheDir() (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(base, "criptomenu")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+func getPriceCachePath() (string, error) {
+	dir, err := getCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "prices.json"), nil
+}
+
+// loadPriceCache restores the last known prices saved by savePriceCache.
+// Entries already present in memory (fresher) are kept.
+func loadPriceCache() error {
+	path, err := getPriceCachePath()
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("could not read price cache: %w", err)
+	}
+
+	var cached map[string]PriceEntry
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return fmt.Errorf("could not parse price cache: %w", err)
+	}
+
+	latestPricesMutex.Lock()
+	defer latestPricesMutex.Unlock()
+	for pair, entry := range cached {
+		if current, ok := latestPrices[pair]; ok && current.UpdatedAt.After(entry.UpdatedAt) {
+			continue
+		}
+		latestPrices[pair] = entry
+	}
+	log.Printf("Restored %d cached prices from %s", len(cached), path)
+	return nil
+}
+
+// savePriceCache writes the in-memory price cache to disk.
+func savePriceCache() error {
+	path, err := getPriceCachePath()
+	if err != nil {
+		return err
+	}
+
+	latestPricesMutex.RLock()
+	data, err := json.MarshalIndent(latestPrices, "", "  ")
+	latestPricesMutex.RUnlock()
+	if err != nil {
+		return fmt.Errorf("could not marshal price cache: %w", err)
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("could not write price cache: %w", err)
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// persistPriceCache periodically flushes the price cache so a crash does not
+// lose the warm start data.
+func persistPriceCache() {
+	ticker := time.NewTicker(priceCacheSaveInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := savePriceCache(); err != nil {
+			log.Printf("Error saving price cache: %v", err)
+		}
+	}
+}
+
+func getCachedPrice(pair string) (PriceEntry, bool) {
+	latestPricesMutex.RLock()
+	defer latestPricesMutex.RUnlock()
+	entry, ok := latestPrices[pair]
+	return entry, ok
+}
+
+// --- Staleness ---
+
+// getStaleAfter returns the configured stale threshold, falling back to the default.
+func getStaleAfter() time.Duration {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	if activeConfig == nil || activeConfig.StaleAfter == "" {
+		return defaultStaleAfter
+	}
+	d, err := time.ParseDuration(activeConfig.StaleAfter)
+	if err != nil || d <= 0 {
+		log.Printf("Invalid stale_after %q, using default %s", activeConfig.StaleAfter, defaultStaleAfter)
+		return defaultStaleAfter
+	}
+	return d
+}
+
+// isStale reports whether a cached entry is older than the configured threshold.
+func isStale(entry PriceEntry) bool {
+	return time.Since(entry.UpdatedAt) > getStaleAfter()
+}
+
+// formatAge renders a duration as a short human readable age, e.g. "5m ago".
+func formatAge(d time.Duration) string {
+	switch {
+	case d < 10*time.Second:
+		return "just now"
+	case d < time.Minute:
+		return fmt.Sprintf("%ds ago", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	}
+}
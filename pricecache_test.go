@@ -0,0 +1,112 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// setTestPrices replaces the price cache with entries for the test.
+func setTestPrices(t *testing.T, entries map[string]PriceEntry) {
+	latestPricesMutex.Lock()
+	old := latestPrices
+	latestPrices = entries
+	latestPricesMutex.Unlock()
+	t.Cleanup(func() {
+		latestPricesMutex.Lock()
+		latestPrices = old
+		latestPricesMutex.Unlock()
+	})
+}
+
+func TestPriceCacheRoundTrip(t *testing.T) {
+	setTestCacheDir(t)
+	saved := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	setTestPrices(t, map[string]PriceEntry{
+		"BTCUSDC": {Price: 92430.5, ChangePercent: 1.5, UpdatedAt: saved},
+		"ETHUSDC": {Price: 3150.25, ChangePercent: -0.8, UpdatedAt: saved},
+	})
+	if err := savePriceCache(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A fresher price in memory is kept, an older one is replaced
+	fresh := PriceEntry{Price: 93000, ChangePercent: 2, UpdatedAt: saved.Add(time.Minute)}
+	setTestPrices(t, map[string]PriceEntry{
+		"BTCUSDC": fresh,
+		"ETHUSDC": {Price: 3000, UpdatedAt: saved.Add(-time.Minute)},
+	})
+	if err := loadPriceCache(); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := getCachedPrice("BTCUSDC"); got != fresh {
+		t.Errorf("BTCUSDC = %+v, want the in-memory %+v", got, fresh)
+	}
+	got, ok := getCachedPrice("ETHUSDC")
+	if !ok || got.Price != 3150.25 || got.ChangePercent != -0.8 || !got.UpdatedAt.Equal(saved) {
+		t.Errorf("ETHUSDC = %+v, want the saved entry", got)
+	}
+}
+
+func TestLoadPriceCacheMissing(t *testing.T) {
+	setTestCacheDir(t)
+	setTestPrices(t, make(map[string]PriceEntry))
+	if err := loadPriceCache(); err != nil {
+		t.Errorf("loadPriceCache without a cache file: %v", err)
+	}
+}
+
+func TestIsStale(t *testing.T) {
+	configMutex.Lock()
+	old := activeConfig
+	activeConfig = &Config{}
+	configMutex.Unlock()
+	t.Cleanup(func() {
+		configMutex.Lock()
+		activeConfig = old
+		configMutex.Unlock()
+	})
+
+	tests := []struct {
+		staleAfter string
+		age        time.Duration
+		want       bool
+	}{
+		{"", defaultStaleAfter - time.Second, false},
+		{"", defaultStaleAfter + time.Second, true},
+		{"10m", 5 * time.Minute, false},
+		{"10m", 11 * time.Minute, true},
+		{"soon", defaultStaleAfter + time.Second, true}, // Invalid: the default
+		{"-1m", time.Minute, false},
+	}
+	for _, tt := range tests {
+		configMutex.Lock()
+		activeConfig.StaleAfter = tt.staleAfter
+		configMutex.Unlock()
+		entry := PriceEntry{UpdatedAt: time.Now().Add(-tt.age)}
+		if got := isStale(entry); got != tt.want {
+			t.Errorf("stale_after %q, age %s: isStale = %v, want %v", tt.staleAfter, tt.age, got, tt.want)
+		}
+	}
+}
+
+func TestFormatAge(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "just now"},
+		{9 * time.Second, "just now"},
+		{10 * time.Second, "10s ago"},
+		{59 * time.Second, "59s ago"},
+		{time.Minute, "1m ago"},
+		{90 * time.Minute, "1h ago"},
+		{23*time.Hour + 59*time.Minute, "23h ago"},
+		{24 * time.Hour, "1d ago"},
+		{50 * time.Hour, "2d ago"},
+	}
+	for _, tt := range tests {
+		if got := formatAge(tt.d); got != tt.want {
+			t.Errorf("formatAge(%s) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
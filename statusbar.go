@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image/color"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	binance_connector "github.com/binance/binance-connector-go"
+)
+
+// Output formats of "criptomenu bar"
+var barFormats = []string{"plain", "tmux", "i3bar", "waybar", "polybar"}
+
+// barState is what a status bar line is rendered from
+type barState struct {
+	Pair    string
+	Title   string // Rendered title_template, as in the menu bar
+	Tooltip string
+	Change  float64
+	Stale   bool
+	HasData bool
+}
+
+// barClass is "up", "down" or "flat" from the 24h change, or "stale"/"loading".
+func (s barState) barClass() string {
+	switch {
+	case !s.HasData:
+		return "loading"
+	case s.Stale:
+		return "stale"
+	case s.Change > 0:
+		return "up"
+	case s.Change < 0:
+		return "down"
+	default:
+		return "flat"
+	}
+}
+
+// barColor is the color of the text for bars that take one, matching the
+// sparkline colors on a dark panel. Empty means the bar's default color.
+func (s barState) barColor() string {
+	var c color.NRGBA
+	switch s.barClass() {
+	case "up":
+		c = sparklineUpDark
+	case "down":
+		c = sparklineDownDark
+	default:
+		return ""
+	}
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// --- Status Bar Output ---
+
+// runBarCommand prints the rotating/pinned title for status bars until
+// interrupted, in the chosen format, one update per line.
+func runBarCommand(args []string) int {
+	fs := flag.NewFlagSet("bar", flag.ContinueOnError)
+	format := fs.String("format", "plain", "output format: "+strings.Join(barFormats, ", "))
+	once := fs.Bool("once", false, "print the current title once and exit")
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 {
+		return 2
+	}
+	if !slices.Contains(barFormats, *format) {
+		fmt.Fprintf(os.Stderr, "Unknown format %q, expected one of: %s\n", *format, strings.Join(barFormats, ", "))
+		return 2
+	}
+
+	startHeadless()
+	w := &barWriter{out: os.Stdout, format: *format}
+	if *once {
+		pair := nextDisplayPair("")
+		if pair == "" {
+			fmt.Fprintln(os.Stderr, "Error: no pairs configured")
+			return 1
+		}
+		client := binance_connector.NewClient("", "", binanceBaseURL)
+		if _, err := fetchTicker(client, pair); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", pair, err)
+		}
+		w.writeHeader()
+		w.write(newBarState(pair))
+		return 0
+	}
+
+	setPair(nextDisplayPair(""))
+	w.writeHeader()
+	w.write(newBarState(getPair()))
+
+	addPriceListener(func(pair string, entry PriceEntry) {
+		if pair == getPair() {
+			w.write(newBarState(pair))
+		}
+	})
+	go watchConfig()
+	go watchExchangeInfo()
+	go watchWatchlist()
+	go persistPriceCache()
+	go fetchPrices()
+
+	// Same rotation as the menu bar; the title is re-rendered on every tick so
+	// the stale marker stays current
+	ticker := time.NewTicker(pairRotationInterval)
+	defer ticker.Stop()
+	sig := interruptChannel()
+	for {
+		select {
+		case <-ticker.C:
+			pair := nextDisplayPair(getPair())
+			setPair(pair)
+			w.write(newBarState(pair))
+		case <-sig:
+			if err := savePriceCache(); err != nil {
+				log.Printf("Error saving price cache: %v", err)
+			}
+			return 0
+		}
+	}
+}
+
+func newBarState(pair string) barState {
+	title, tooltip := renderTitle(pair)
+	state := barState{Pair: pair, Title: title, Tooltip: tooltip}
+	if entry, ok := getCachedPrice(pair); ok {
+		state.HasData = true
+		state.Change = entry.ChangePercent
+		state.Stale = isStale(entry)
+	}
+	return state
+}
+
+// barWriter writes status lines in one format. Writes come from the rotation
+// loop and the price listener, so they are serialized.
+type barWriter struct {
+	mu     sync.Mutex
+	out    io.Writer
+	format string
+	last   string
+}
+
+// writeHeader starts the i3bar protocol stream: a header and an endless array.
+func (w *barWriter) writeHeader() {
+	if w.format == "i3bar" {
+		fmt.Fprintln(w.out, `{"version":1}`)
+		fmt.Fprintln(w.out, "[")
+	}
+}
+
+// write prints the line for s, skipping it when nothing changed.
+func (w *barWriter) write(s barState) {
+	line := formatBarLine(w.format, s)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if line == w.last {
+		return
+	}
+	w.last = line
+	if w.format == "i3bar" {
+		line += "," // Elements of the endless array
+	}
+	fmt.Fprintln(w.out, line)
+}
+
+// formatBarLine renders s for a status bar:
+//   - plain: the title
+//   - tmux: the title colored with #[fg=...] (for #(criptomenu bar --format tmux --once))
+//   - polybar: the title colored with %{F...} (for a "tail = true" script module)
+//   - i3bar: one status block of the i3bar JSON protocol
+//   - waybar: the JSON of a custom module with "return-type": "json"
+func formatBarLine(format string, s barState) string {
+	fg := s.barColor()
+	switch format {
+	case "tmux":
+		title := strings.ReplaceAll(s.Title, "#", "##")
+		if fg == "" {
+			return title
+		}
+		return "#[fg=" + fg + "]" + title + "#[default]"
+	case "polybar":
+		if fg == "" {
+			return s.Title
+		}
+		return "%{F" + fg + "}" + s.Title + "%{F-}"
+	case "i3bar":
+		block := map[string]string{"name": "criptomenu", "instance": s.Pair, "full_text": s.Title}
+		if fg != "" {
+			block["color"] = fg
+		}
+		data, _ := json.Marshal([]map[string]string{block})
+		return string(data)
+	case "waybar":
+		data, _ := json.Marshal(map[string]string{"text": s.Title, "tooltip": s.Tooltip, "class": s.barClass(), "alt": s.Pair})
+		return string(data)
+	default:
+		return s.Title
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFormatBarLine(t *testing.T) {
+	up := barState{Pair: "BTCUSDC", Title: "BTC #1 $92,430", Tooltip: "BTC/USDC +1.20%", Change: 1.2, HasData: true}
+	down := barState{Pair: "ETHUSDC", Title: "ETH $3,150", Tooltip: "ETH/USDC -0.80%", Change: -0.8, HasData: true}
+	flat := barState{Pair: "BTCUSDC", Title: "BTC $92,430", HasData: true}
+	stale := barState{Pair: "BTCUSDC", Title: "BTC #1 $92,430 (5m ago)", Change: 1.2, Stale: true, HasData: true}
+	loading := barState{Pair: "BTCUSDC", Title: "BTCUSDC: Loading..."}
+
+	tests := []struct {
+		format string
+		state  barState
+		want   string
+	}{
+		{"plain", up, "BTC #1 $92,430"},
+		{"plain", down, "ETH $3,150"},
+		// tmux: "#" starts a format, so a literal one is doubled
+		{"tmux", up, "#[fg=#3fb950]BTC ##1 $92,430#[default]"},
+		{"tmux", down, "#[fg=#f85149]ETH $3,150#[default]"},
+		{"tmux", stale, "BTC ##1 $92,430 (5m ago)"},
+		{"polybar", up, "%{F#3fb950}BTC #1 $92,430%{F-}"},
+		{"polybar", down, "%{F#f85149}ETH $3,150%{F-}"},
+		{"polybar", flat, "BTC $92,430"},
+		{"i3bar", up, `[{"color":"#3fb950","full_text":"BTC #1 $92,430","instance":"BTCUSDC","name":"criptomenu"}]`},
+		{"i3bar", loading, `[{"full_text":"BTCUSDC: Loading...","instance":"BTCUSDC","name":"criptomenu"}]`},
+		{"waybar", up, `{"alt":"BTCUSDC","class":"up","text":"BTC #1 $92,430","tooltip":"BTC/USDC +1.20%"}`},
+		{"waybar", down, `{"alt":"ETHUSDC","class":"down","text":"ETH $3,150","tooltip":"ETH/USDC -0.80%"}`},
+		{"waybar", flat, `{"alt":"BTCUSDC","class":"flat","text":"BTC $92,430","tooltip":""}`},
+		{"waybar", stale, `{"alt":"BTCUSDC","class":"stale","text":"BTC #1 $92,430 (5m ago)","tooltip":""}`},
+		{"waybar", loading, `{"alt":"BTCUSDC","class":"loading","text":"BTCUSDC: Loading...","tooltip":""}`},
+	}
+	for _, tt := range tests {
+		if got := formatBarLine(tt.format, tt.state); got != tt.want {
+			t.Errorf("formatBarLine(%s, %+v) = %s\nwant %s", tt.format, tt.state, got, tt.want)
+		}
+	}
+}
+
+func TestBarWriter(t *testing.T) {
+	first := barState{Pair: "BTCUSDC", Title: "BTC $92,430", HasData: true}
+	second := barState{Pair: "ETHUSDC", Title: "ETH $3,150", HasData: true}
+
+	var out bytes.Buffer
+	w := &barWriter{out: &out, format: "i3bar"}
+	w.writeHeader()
+	w.write(first)
+	w.write(first) // Unchanged: skipped
+	w.write(second)
+	want := `{"version":1}
+[
+[{"full_text":"BTC $92,430","instance":"BTCUSDC","name":"criptomenu"}],
+[{"full_text":"ETH $3,150","instance":"ETHUSDC","name":"criptomenu"}],
+`
+	if out.String() != want {
+		t.Errorf("i3bar output:\n%s\nwant\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	w = &barWriter{out: &out, format: "plain"}
+	w.writeHeader()
+	w.write(first)
+	w.write(first)
+	w.write(second)
+	w.write(first)
+	if want := "BTC $92,430\nETH $3,150\nBTC $92,430\n"; out.String() != want {
+		t.Errorf("plain output %q, want %q", out.String(), want)
+	}
+}
+
+func TestNextDisplayPair(t *testing.T) {
+	configMutex.Lock()
+	old := activeConfig
+	configMutex.Unlock()
+	t.Cleanup(func() {
+		configMutex.Lock()
+		activeConfig = old
+		configMutex.Unlock()
+	})
+
+	tests := []struct {
+		pairs         []string
+		pinned        string
+		current, want string
+	}{
+		{[]string{"BTCUSDC", "ETHUSDC", "SOLUSDC"}, "", "", "BTCUSDC"},
+		{[]string{"BTCUSDC", "ETHUSDC", "SOLUSDC"}, "", "ETHUSDC", "SOLUSDC"},
+		{[]string{"BTCUSDC", "ETHUSDC", "SOLUSDC"}, "", "SOLUSDC", "BTCUSDC"},
+		{[]string{"BTCUSDC", "ETHUSDC"}, "", "ADAUSDC", "BTCUSDC"}, // Removed from the config
+		{[]string{"BTCUSDC", "ETHUSDC"}, "SOLUSDC", "BTCUSDC", "SOLUSDC"},
+		{[]string{"BTCUSDC"}, "", "", "BTCUSDC"},
+		{[]string{"BTCUSDC"}, "", "BTCUSDC", "BTCUSDC"},
+		{[]string{"BTCUSDC"}, "", "ETHUSDC", "BTCUSDC"},
+		{nil, "", "ETHUSDC", "ETHUSDC"},
+	}
+	for _, tt := range tests {
+		configMutex.Lock()
+		activeConfig = &Config{Pairs: tt.pairs, PinnedPair: tt.pinned}
+		configMutex.Unlock()
+		if got := nextDisplayPair(tt.current); got != tt.want {
+			t.Errorf("nextDisplayPair(%q) with pairs %v, pinned %q = %q, want %q", tt.current, tt.pairs, tt.pinned, got, tt.want)
+		}
+	}
+}
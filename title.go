@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/getlantern/systray"
+)
+
+const (
+	// Template used when title_template is not set, e.g. "BTCUSDC: 92430.00"
+	defaultTitleTemplate = `{{.Pair}}: {{price .Price}}`
+
+	// Marker appended to the title when the displayed price is stale
+	staleMarker = " ⌛"
+)
+
+// titleData is the data available to title_template
+type titleData struct {
+	Pair     string        // Trading pair, e.g. "BTCUSDC"
+	Base     string        // Base asset, e.g. "BTC"
+	Quote    string        // Quote asset, e.g. "USDC"
+	Price    float64       // Last price
+	Change   float64       // 24h change in percent
+	Stale    bool          // Price older than stale_after
+	Age      time.Duration // Time since the price was fetched
+	Holdings float64       // Amount of the base asset held (from [holdings])
+	Value    float64       // Holdings * Price, in the quote asset
+}
+
+var (
+	// Parsed title template, re-parsed when title_template changes
+	titleTemplateMutex  sync.Mutex
+	titleTemplate       *template.Template
+	titleTemplateSource string
+)
+
+// --- Title Rendering ---
+
+// refreshTitle updates the tray title and tooltip for pair from the price cache.
+func refreshTitle(pair string) {
+	title, tooltip := renderTitle(pair)
+	systray.SetTitle(title)
+	systray.SetTooltip(tooltip)
+}
+
+// renderTitle builds the title (from title_template) and tooltip for pair,
+// marking the value when it is older than the configured stale threshold.
+func renderTitle(pair string) (title, tooltip string) {
+	entry, ok := getCachedPrice(pair)
+	if !ok {
+		return fmt.Sprintf("%s: ...", aliasFor(pair)), fmt.Sprintf("%s: waiting for first price", pair)
+	}
+
+	data := newTitleData(pair, entry)
+	title = executeTitleTemplate(data)
+	tooltip = fmt.Sprintf("%s: %s (%s 24h), updated %s", displayPair(pair), formatPairPrice(pair, entry.Price), formatChange(entry.ChangePercent), formatAge(data.Age))
+	if data.Holdings > 0 {
+		tooltip += fmt.Sprintf("\nHoldings: %s = %s", strconv.FormatFloat(data.Holdings, 'f', -1, 64), formatQuoteValue(pair, data.Value))
+	}
+	if data.Stale {
+		title += staleMarker
+		tooltip += ", stale"
+	}
+	return title, tooltip
+}
+
+func newTitleData(pair string, entry PriceEntry) titleData {
+	configMutex.RLock()
+	holdings := 0.0
+	if activeConfig != nil {
+		holdings = activeConfig.Holdings[pair]
+	}
+	configMutex.RUnlock()
+
+	base, quote, _ := splitPair(pair)
+	return titleData{
+		Pair:     pair,
+		Base:     base,
+		Quote:    quote,
+		Price:    entry.Price,
+		Change:   entry.ChangePercent,
+		Stale:    isStale(entry),
+		Age:      time.Since(entry.UpdatedAt),
+		Holdings: holdings,
+		Value:    holdings * entry.Price,
+	}
+}
+
+// executeTitleTemplate renders data with the configured template, falling back
+// to the default one if the template is invalid or fails.
+func executeTitleTemplate(data titleData) string {
+	configMutex.RLock()
+	source := ""
+	if activeConfig != nil {
+		source = activeConfig.TitleTemplate
+	}
+	configMutex.RUnlock()
+	if source == "" {
+		source = defaultTitleTemplate
+	}
+
+	var b strings.Builder
+	if err := executeForPair(getTitleTemplate(source), &b, data); err != nil {
+		log.Printf("Error rendering title_template: %v", err)
+		b.Reset()
+		_ = executeForPair(getTitleTemplate(defaultTitleTemplate), &b, data)
+	}
+	return b.String()
+}
+
+// executeForPair runs tmpl with the price function bound to data.Pair.
+func executeForPair(tmpl *template.Template, w io.Writer, data titleData) error {
+	clone, err := tmpl.Clone()
+	if err != nil {
+		return err
+	}
+	clone.Funcs(template.FuncMap{
+		"price": func(v float64) string { return formatPairPrice(data.Pair, v) },
+	})
+	return clone.Execute(w, data)
+}
+
+// getTitleTemplate returns the parsed template for source, parsing it only
+// when it changed.
+func getTitleTemplate(source string) *template.Template {
+	titleTemplateMutex.Lock()
+	defer titleTemplateMutex.Unlock()
+
+	if titleTemplate != nil && titleTemplateSource == source {
+		return titleTemplate
+	}
+
+	tmpl, err := template.New("title").Funcs(titleFuncs).Parse(source)
+	if err != nil {
+		log.Printf("Invalid title_template, using default: %v", err)
+		tmpl = template.Must(template.New("title").Funcs(titleFuncs).Parse(defaultTitleTemplate))
+	}
+	titleTemplate = tmpl
+	titleTemplateSource = source
+	return tmpl
+}
+
+// Functions available in title_template. price is bound to the displayed pair
+// in executeForPair, as its precision depends on the symbol.
+var titleFuncs = template.FuncMap{
+	"price":   formatPrice,         // {{price .Price}} -> "$92,430.00", precision from the symbol tick size
+	"change":  formatChange,        // {{change .Change}} -> "+1.23%"
+	"arrow":   formatArrow,         // {{arrow .Change}} -> "▲"
+	"compact": formatCompact,       // {{compact .Price}} -> "92.4k"
+	"number":  formatNumber,        // {{number .Price 0}} -> "92,430"
+	"alias":   aliasFor,            // {{alias .Pair}} -> "₿"
+	"value":   holdingsValue,       // {{compact (value .Pair)}} -> holdings value as a number, e.g. "46.2k"
+	"worth":   formatHoldingsValue, // {{worth .Pair}} -> holdings value in the quote asset, e.g. "$46,215.00"
+}
+
+// holdingsValue returns the value of the configured holdings of pair at its
+// last cached price.
+func holdingsValue(pair string) float64 {
+	configMutex.RLock()
+	holdings := 0.0
+	if activeConfig != nil {
+		holdings = activeConfig.Holdings[pair]
+	}
+	configMutex.RUnlock()
+
+	entry, ok := getCachedPrice(pair)
+	if !ok {
+		return 0
+	}
+	return holdings * entry.Price
+}
+
+// formatHoldingsValue formats holdingsValue in the quote asset of pair.
+func formatHoldingsValue(pair string) string {
+	return formatQuoteValue(pair, holdingsValue(pair))
+}
+
+// aliasFor returns the configured alias for pair, or the pair itself.
+func aliasFor(pair string) string {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+	if activeConfig != nil {
+		if alias, ok := activeConfig.Aliases[pair]; ok && alias != "" {
+			return alias
+		}
+	}
+	return pair
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExecuteTitleTemplate(t *testing.T) {
+	setTestSymbols(t)
+	configMutex.Lock()
+	old := activeConfig
+	activeConfig = &Config{
+		Aliases:  map[string]string{"BTCUSDC": "₿"},
+		Holdings: map[string]float64{"BTCUSDC": 0.5},
+	}
+	configMutex.Unlock()
+	latestPricesMutex.Lock()
+	latestPrices["BTCUSDC"] = PriceEntry{Price: 92430, ChangePercent: 1.2, UpdatedAt: time.Now()}
+	latestPricesMutex.Unlock()
+	t.Cleanup(func() {
+		configMutex.Lock()
+		activeConfig = old
+		configMutex.Unlock()
+		latestPricesMutex.Lock()
+		delete(latestPrices, "BTCUSDC")
+		latestPricesMutex.Unlock()
+		titleTemplateMutex.Lock()
+		titleTemplate, titleTemplateSource = nil, ""
+		titleTemplateMutex.Unlock()
+	})
+
+	tests := []struct {
+		template, want string
+	}{
+		{"", "BTCUSDC: $92,430.00"},
+		{"{{alias .Pair}} {{compact .Price}} {{arrow .Change}}{{change .Change}}", "₿ 92.4k ▲+1.20%"},
+		{"{{number .Price 0}}", "92,430"},
+		{"{{compact (value .Pair)}}", "46.2k"},
+		{"{{worth .Pair}}", "$46,215.00"},
+		{"{{price .Value}}", "$46,215.00"},
+		// A template failing at render time falls back to the default
+		{"{{compact .Pair}}", "BTCUSDC: $92,430.00"},
+	}
+	for _, tt := range tests {
+		configMutex.Lock()
+		activeConfig.TitleTemplate = tt.template
+		configMutex.Unlock()
+		entry, _ := getCachedPrice("BTCUSDC")
+		if got := executeTitleTemplate(newTitleData("BTCUSDC", entry)); got != tt.want {
+			t.Errorf("template %q = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getlantern/systray"
+)
+
+const (
+	// Number of history points drawn in the sparkline by default
+	defaultSparklinePoints = 24
+
+	// How long a detected menubar/panel theme is reused
+	themeCheckInterval = 30 * time.Second
+)
+
+// IconConfig controls the tray icon
+type IconConfig struct {
+	Style    string `toml:"style,omitempty"`    // "sparkline" (default) or "static"
+	Theme    string `toml:"theme,omitempty"`    // "auto" (default), "light" or "dark"
+	Template bool   `toml:"template,omitempty"` // macOS only: monochrome template image that follows the menubar color
+	Points   int    `toml:"points,omitempty"`   // Number of history points to draw
+}
+
+// iconOptions describes how a sparkline icon is rendered
+type iconOptions struct {
+	Width    int  // Size in points
+	Height   int  // Size in points
+	Scale    int  // Pixels per point (2 for Retina)
+	Dark     bool // Render for a dark menubar/panel
+	Template bool // Monochrome black, for macOS template images
+}
+
+// Sparkline colors for each theme, chosen for contrast against the menubar
+var (
+	sparklineUpLight   = color.NRGBA{R: 0x1a, G: 0x7f, B: 0x37, A: 0xff}
+	sparklineDownLight = color.NRGBA{R: 0xcf, G: 0x22, B: 0x2e, A: 0xff}
+	sparklineUpDark    = color.NRGBA{R: 0x3f, G: 0xb9, B: 0x50, A: 0xff}
+	sparklineDownDark  = color.NRGBA{R: 0xf8, G: 0x51, B: 0x49, A: 0xff}
+	sparklineTemplate  = color.NRGBA{A: 0xff}
+)
+
+var (
+	// Last icon set on the tray, to avoid redundant updates
+	lastIconMutex sync.Mutex
+	lastIconBytes []byte
+
+	// History candles of each pair's sparkline, reused until a newer candle
+	// is stored so refreshes do not read the history file
+	sparklineMutex sync.Mutex
+	sparklineCache = make(map[string]sparklineHistory)
+
+	// Detected theme, the check forks a process on macOS
+	themeMutex     sync.Mutex
+	themeDark      bool
+	themeCheckedAt time.Time
+)
+
+// sparklineHistory is the history part of a sparkline
+type sparklineHistory struct {
+	Interval string
+	Points   int
+	Newest   time.Time // Newest stored candle when loaded
+	Candles  []Candle
+}
+
+// --- Tray Icon ---
+
+// refreshIcon redraws the tray icon from the recent history of pair.
+// Falls back to the static icon when there is not enough data.
+func refreshIcon(pair string) {
+	configMutex.RLock()
+	cfg := IconConfig{}
+	if activeConfig != nil && activeConfig.Icon != nil {
+		cfg = *activeConfig.Icon
+	}
+	configMutex.RUnlock()
+
+	var icon []byte
+	if cfg.Style != "static" && runtime.GOOS != "windows" {
+		values := getSparklineValues(pair, cfg.Points)
+		if len(values) >= 2 {
+			opts := getPlatformIconOptions(cfg)
+			rendered, err := renderSparkline(values, opts)
+			if err != nil {
+				log.Printf("Error rendering sparkline icon: %v", err)
+			} else {
+				icon = rendered
+			}
+		}
+	}
+
+	template := cfg.Template && icon != nil
+	if icon == nil {
+		icon = getIcon()
+	}
+
+	lastIconMutex.Lock()
+	defer lastIconMutex.Unlock()
+	if bytes.Equal(icon, lastIconBytes) {
+		return
+	}
+	lastIconBytes = icon
+
+	if template {
+		systray.SetTemplateIcon(icon, icon)
+	} else {
+		systray.SetIcon(icon)
+	}
+}
+
+// getSparklineValues returns the closes of the last n stored candles for pair,
+// followed by the latest cached price when it is newer.
+func getSparklineValues(pair string, n int) []float64 {
+	if n <= 0 {
+		n = defaultSparklinePoints
+	}
+
+	interval := defaultBackfillInterval
+	if plan, err := getBackfillPlan(); err == nil {
+		interval = plan.Interval
+	}
+
+	from := time.Now().Add(-time.Duration(n+1) * klineIntervals[interval])
+	candles := sparklineCandles(pair, interval, n, from)
+
+	values := make([]float64, 0, len(candles)+1)
+	var lastTime time.Time
+	for _, c := range candles {
+		if c.Time.Before(from) {
+			continue
+		}
+		values = append(values, c.Close)
+		lastTime = c.Time
+	}
+	if entry, ok := getCachedPrice(pair); ok && entry.UpdatedAt.After(lastTime) {
+		values = append(values, entry.Price)
+	}
+	return values
+}
+
+// sparklineCandles returns the last n stored candles of pair since from,
+// loading them again only when a newer candle was stored.
+func sparklineCandles(pair, interval string, n int, from time.Time) []Candle {
+	newest, err := lastHistoryTime(pair, interval)
+	if err != nil {
+		log.Printf("Error loading history for %s icon: %v", pair, err)
+		return nil
+	}
+
+	sparklineMutex.Lock()
+	cached, ok := sparklineCache[pair]
+	sparklineMutex.Unlock()
+	if ok && cached.Interval == interval && cached.Points == n && cached.Newest.Equal(newest) {
+		return cached.Candles
+	}
+
+	candles, err := loadHistory(pair, interval, from, time.Time{})
+	if err != nil {
+		log.Printf("Error loading history for %s icon: %v", pair, err)
+		return nil
+	}
+	if len(candles) > n {
+		candles = candles[len(candles)-n:]
+	}
+	sparklineMutex.Lock()
+	sparklineCache[pair] = sparklineHistory{Interval: interval, Points: n, Newest: newest, Candles: candles}
+	sparklineMutex.Unlock()
+	return candles
+}
+
+// getPlatformIconOptions returns the icon size for the current platform:
+// macOS status items are 22pt tall and drawn at 2x on Retina displays, while
+// Linux app indicators expect a small square image.
+func getPlatformIconOptions(cfg IconConfig) iconOptions {
+	opts := iconOptions{Width: 24, Height: 24, Scale: 1}
+	if runtime.GOOS == "darwin" {
+		opts = iconOptions{Width: 32, Height: 18, Scale: 2, Template: cfg.Template}
+	}
+
+	switch cfg.Theme {
+	case "light":
+		opts.Dark = false
+	case "dark":
+		opts.Dark = true
+	default:
+		opts.Dark = isDarkTheme()
+	}
+	return opts
+}
+
+// isDarkTheme guesses whether the menubar/panel uses a dark appearance,
+// checking again at most every themeCheckInterval.
+func isDarkTheme() bool {
+	themeMutex.Lock()
+	defer themeMutex.Unlock()
+	if themeCheckedAt.IsZero() || time.Since(themeCheckedAt) >= themeCheckInterval {
+		themeDark = detectDarkTheme()
+		themeCheckedAt = time.Now()
+	}
+	return themeDark
+}
+
+func detectDarkTheme() bool {
+	if runtime.GOOS == "darwin" {
+		// Prints "Dark" in dark mode and fails when light mode is active
+		out, err := exec.Command("defaults", "read", "-g", "AppleInterfaceStyle").Output()
+		return err == nil && strings.Contains(string(out), "Dark")
+	}
+	if theme := os.Getenv("GTK_THEME"); theme != "" {
+		return strings.Contains(strings.ToLower(theme), "dark")
+	}
+	// Most Linux panels are dark
+	return true
+}
+
+// --- Sparkline Rendering ---
+
+// renderSparkline draws values as a line chart and encodes it as PNG. The
+// line is green when the last value is at or above the first one, red
+// otherwise. The output only depends on its inputs.
+func renderSparkline(values []float64, opts iconOptions) ([]byte, error) {
+	scale := opts.Scale
+	if scale < 1 {
+		scale = 1
+	}
+	w, h := opts.Width*scale, opts.Height*scale
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+
+	lineColor := sparklineColor(values, opts)
+	stroke := 1.0 * float64(scale)
+	pad := stroke + 1
+
+	min, max := values[0], values[0]
+	for _, v := range values {
+		min = math.Min(min, v)
+		max = math.Max(max, v)
+	}
+	span := max - min
+
+	point := func(i int) (float64, float64) {
+		x := pad + float64(i)*(float64(w)-2*pad)/float64(len(values)-1)
+		y := float64(h) / 2
+		if span > 0 {
+			y = pad + (max-values[i])*(float64(h)-2*pad)/span
+		}
+		return x, y
+	}
+
+	for i := 1; i < len(values); i++ {
+		x0, y0 := point(i - 1)
+		x1, y1 := point(i)
+		drawSegment(img, x0, y0, x1, y1, stroke, lineColor)
+	}
+
+	// Fully transparent pixels carry no color; clear it so the PNG, and so
+	// the redundant-update check, only depends on what is visible
+	for i := 0; i < len(img.Pix); i += 4 {
+		if img.Pix[i+3] == 0 {
+			clear(img.Pix[i : i+3])
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return setPNGDensity(buf.Bytes(), scale), nil
+}
+
+func sparklineColor(values []float64, opts iconOptions) color.NRGBA {
+	if opts.Template {
+		return sparklineTemplate
+	}
+	up := values[len(values)-1] >= values[0]
+	switch {
+	case up && opts.Dark:
+		return sparklineUpDark
+	case up:
+		return sparklineUpLight
+	case opts.Dark:
+		return sparklineDownDark
+	default:
+		return sparklineDownLight
+	}
+}
+
+// drawSegment draws an anti-aliased line of the given width by computing the
+// coverage of each pixel near the segment.
+func drawSegment(img *image.NRGBA, x0, y0, x1, y1, width float64, c color.NRGBA) {
+	half := width / 2
+	minX := int(math.Floor(math.Min(x0, x1) - half - 1))
+	maxX := int(math.Ceil(math.Max(x0, x1) + half + 1))
+	minY := int(math.Floor(math.Min(y0, y1) - half - 1))
+	maxY := int(math.Ceil(math.Max(y0, y1) + half + 1))
+
+	for py := minY; py <= maxY; py++ {
+		for px := minX; px <= maxX; px++ {
+			if !(image.Point{X: px, Y: py}.In(img.Rect)) {
+				continue
+			}
+			d := distanceToSegment(float64(px)+0.5, float64(py)+0.5, x0, y0, x1, y1)
+			coverage := math.Max(0, math.Min(1, half+0.5-d))
+			if coverage == 0 {
+				continue
+			}
+			alpha := uint8(math.Round(coverage * float64(c.A)))
+			if existing := img.NRGBAAt(px, py); existing.A >= alpha {
+				continue
+			}
+			img.SetNRGBA(px, py, color.NRGBA{R: c.R, G: c.G, B: c.B, A: alpha})
+		}
+	}
+}
+
+func distanceToSegment(px, py, x0, y0, x1, y1 float64) float64 {
+	dx, dy := x1-x0, y1-y0
+	lenSq := dx*dx + dy*dy
+	t := 0.0
+	if lenSq > 0 {
+		t = math.Max(0, math.Min(1, ((px-x0)*dx+(py-y0)*dy)/lenSq))
+	}
+	cx, cy := x0+t*dx, y0+t*dy
+	return math.Hypot(px-cx, py-cy)
+}
+
+// setPNGDensity inserts a pHYs chunk so macOS displays a @2x image at its
+// point size instead of its pixel size.
+func setPNGDensity(data []byte, scale int) []byte {
+	const pngSignatureLen = 8
+	const ihdrChunkLen = 4 + 4 + 13 + 4 // length, type, data, crc
+	if scale <= 1 || len(data) < pngSignatureLen+ihdrChunkLen {
+		return data
+	}
+
+	// 72 dpi per point, expressed in pixels per meter
+	ppm := uint32(math.Round(float64(scale) * 72 / 0.0254))
+	chunk := make([]byte, 4+4+9+4)
+	binary.BigEndian.PutUint32(chunk[0:], 9)
+	copy(chunk[4:], "pHYs")
+	binary.BigEndian.PutUint32(chunk[8:], ppm)
+	binary.BigEndian.PutUint32(chunk[12:], ppm)
+	chunk[16] = 1 // Unit: meter
+	binary.BigEndian.PutUint32(chunk[17:], crc32.ChecksumIEEE(chunk[4:17]))
+
+	insertAt := pngSignatureLen + ihdrChunkLen
+	out := make([]byte, 0, len(data)+len(chunk))
+	out = append(out, data[:insertAt]...)
+	out = append(out, chunk...)
+	out = append(out, data[insertAt:]...)
+	return out
+}
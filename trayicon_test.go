@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Every sparkline variant is compared with testdata/sparkline/<name>.png;
+// run "go test -update" to rewrite them after an intended change.
+func TestRenderSparklineGolden(t *testing.T) {
+	up := []float64{100, 102, 101, 104, 103, 107, 106, 110}
+	down := []float64{110, 106, 107, 103, 104, 101, 102, 100}
+	platforms := []struct {
+		name string
+		opts iconOptions
+	}{
+		{"linux", iconOptions{Width: 24, Height: 24, Scale: 1}},
+		{"darwin", iconOptions{Width: 32, Height: 18, Scale: 2}},
+	}
+
+	for _, p := range platforms {
+		for _, trend := range []string{"up", "down"} {
+			values := up
+			if trend == "down" {
+				values = down
+			}
+			for _, theme := range []string{"light", "dark", "template"} {
+				opts := p.opts
+				opts.Dark = theme == "dark"
+				opts.Template = theme == "template"
+				name := fmt.Sprintf("%s_%s_%s.png", p.name, trend, theme)
+				t.Run(name, func(t *testing.T) {
+					checkSparklineGolden(t, name, values, opts)
+				})
+			}
+		}
+	}
+
+	t.Run("flat", func(t *testing.T) {
+		checkSparklineGolden(t, "linux_flat_light.png", []float64{5, 5, 5}, platforms[0].opts)
+	})
+}
+
+func checkSparklineGolden(t *testing.T, name string, values []float64, opts iconOptions) {
+	got, err := renderSparkline(values, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := filepath.Join("testdata", "sparkline", name)
+	if *updateGolden {
+		if err := os.WriteFile(golden, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("rendered icon differs from %s", golden)
+	}
+
+	img, err := png.Decode(bytes.NewReader(got))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := img.Bounds().Size(); size.X != opts.Width*opts.Scale || size.Y != opts.Height*opts.Scale {
+		t.Errorf("size %v, want %dx%d", size, opts.Width*opts.Scale, opts.Height*opts.Scale)
+	}
+}
+
+func TestSparklineColor(t *testing.T) {
+	tests := []struct {
+		values []float64
+		opts   iconOptions
+		want   string
+	}{
+		{[]float64{1, 2}, iconOptions{}, "up light"},
+		{[]float64{1, 1}, iconOptions{Dark: true}, "up dark"},
+		{[]float64{2, 1}, iconOptions{}, "down light"},
+		{[]float64{2, 1}, iconOptions{Dark: true}, "down dark"},
+		{[]float64{2, 1}, iconOptions{Dark: true, Template: true}, "template"},
+	}
+	colors := map[string]any{
+		"up light": sparklineUpLight, "up dark": sparklineUpDark,
+		"down light": sparklineDownLight, "down dark": sparklineDownDark,
+		"template": sparklineTemplate,
+	}
+	for _, tt := range tests {
+		if got := sparklineColor(tt.values, tt.opts); got != colors[tt.want] {
+			t.Errorf("sparklineColor(%v, %+v) = %v, want %s", tt.values, tt.opts, got, tt.want)
+		}
+	}
+}
+
+// The history part of a sparkline is reused until a newer candle is stored.
+func TestSparklineValuesCache(t *testing.T) {
+	setTestCacheDir(t)
+	configMutex.Lock()
+	old := activeConfig
+	activeConfig = &Config{Pairs: []string{"BTCUSDC"}, Backfill: &BackfillConfig{Interval: "1m"}}
+	configMutex.Unlock()
+	t.Cleanup(func() {
+		configMutex.Lock()
+		activeConfig = old
+		configMutex.Unlock()
+		sparklineMutex.Lock()
+		clear(sparklineCache)
+		sparklineMutex.Unlock()
+	})
+
+	start := time.Now().Truncate(time.Minute).Add(-6 * time.Minute)
+	candles := testCandles(start, 6)
+	if _, err := appendHistory("BTCUSDC", "1m", candles[:5]); err != nil {
+		t.Fatal(err)
+	}
+	if got := getSparklineValues("BTCUSDC", 24); len(got) != 5 || got[4] != candles[4].Close {
+		t.Fatalf("values = %v", got)
+	}
+
+	if _, err := appendHistory("BTCUSDC", "1m", candles[5:]); err != nil {
+		t.Fatal(err)
+	}
+	if got := getSparklineValues("BTCUSDC", 24); len(got) != 6 || got[5] != candles[5].Close {
+		t.Errorf("values after a new candle = %v", got)
+	}
+	if got := getSparklineValues("BTCUSDC", 3); len(got) != 3 || got[0] != candles[3].Close {
+		t.Errorf("values with 3 points = %v", got)
+	}
+}
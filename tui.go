@@ -0,0 +1,502 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const (
+	// Number of points of the sparkline column
+	tuiSparklinePoints = 20
+
+	// Number of log lines kept for the log pane
+	tuiLogLines = 200
+)
+
+// Blocks used to draw sparklines, from lowest to highest
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// tuiState is the state of the terminal dashboard, guarded by mu
+type tuiState struct {
+	mu sync.Mutex
+
+	selected   int
+	sparklines map[string]string
+	triggered  map[Alert]float64 // Triggered alerts not yet acknowledged, with the price
+	status     string            // One-line feedback of the last action
+
+	prompt     string // Non-empty while reading the target of a new alert
+	promptPair string
+	input      []rune
+
+	logPane tuiLog
+}
+
+// tuiLog is the log pane: it receives the log output while the dashboard
+// runs. It has its own lock because code logs while holding the config lock.
+type tuiLog struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *tuiLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, strings.Split(strings.TrimRight(string(p), "\n"), "\n")...)
+	if n := len(l.lines); n > tuiLogLines {
+		l.lines = l.lines[n-tuiLogLines:]
+	}
+	return len(p), nil
+}
+
+// last returns up to n of the latest log lines.
+func (l *tuiLog) last(n int) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.lines[max(len(l.lines)-n, 0):]...)
+}
+
+// --- Terminal Dashboard ---
+
+// runTUI shows a full-screen dashboard of the configured pairs until the user
+// quits. It mirrors the tray: prices are fetched and alerts checked by the
+// same code, and pins and alerts are saved to the config file.
+func runTUI(args []string) int {
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "Usage: criptomenu tui")
+		return 2
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) || !isTerminal(os.Stdout) {
+		fmt.Fprintln(os.Stderr, "Error: tui needs an interactive terminal; use 'criptomenu watch' for plain output")
+		return 1
+	}
+
+	// The dashboard polls and evaluates alerts like the app and the daemon
+	if locked, pid, err := acquireInstanceLock(); err != nil {
+		log.Printf("Error taking the instance lock, starting anyway: %v", err)
+	} else if !locked {
+		fmt.Fprintf(os.Stderr, "Error: %s.\n", alreadyRunning(pid))
+		return 1
+	}
+
+	state := &tuiState{sparklines: make(map[string]string), triggered: make(map[Alert]float64)}
+	log.SetOutput(&state.logPane)
+	defer log.SetOutput(os.Stderr)
+
+	startHeadless()
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	defer term.Restore(fd, oldState)
+	fmt.Print("\033[?1049h\033[?25l")       // Alternate screen, hide cursor
+	defer fmt.Print("\033[?25h\033[?1049l") // Restore both
+
+	redraw := make(chan struct{}, 1)
+	requestRedraw := func() {
+		select {
+		case redraw <- struct{}{}:
+		default:
+		}
+	}
+
+	addPriceListener(func(pair string, entry PriceEntry) {
+		spark := sparkline(getSparklineValues(pair, tuiSparklinePoints))
+		state.mu.Lock()
+		state.sparklines[pair] = spark
+		state.mu.Unlock()
+		requestRedraw()
+	})
+	addAlertListener(func(alert Alert, price float64) {
+		state.mu.Lock()
+		state.triggered[alert] = price
+		state.mu.Unlock()
+		requestRedraw()
+	})
+	for _, pair := range commandPairs(nil) {
+		state.sparklines[pair] = sparkline(getSparklineValues(pair, tuiSparklinePoints))
+	}
+
+	go watchConfig()
+	go watchExchangeInfo()
+	go watchWatchlist()
+	go runBackfill()
+	go persistPriceCache()
+	go fetchPrices()
+	startControlServer()
+	defer stopControlServer()
+
+	keys := make(chan string)
+	go readKeys(keys)
+	ticker := time.NewTicker(time.Second) // Keeps ages and the terminal size current
+	defer ticker.Stop()
+
+	for {
+		drawTUI(state)
+		select {
+		case <-redraw:
+		case <-ticker.C:
+		case key, ok := <-keys:
+			if !ok || !handleTUIKey(state, key) {
+				if err := savePriceCache(); err != nil {
+					log.Printf("Error saving price cache: %v", err)
+				}
+				return 0
+			}
+		}
+	}
+}
+
+// readKeys sends each key read from stdin, with arrow keys as "up"/"down",
+// and closes keys when stdin ends.
+func readKeys(keys chan<- string) {
+	defer close(keys)
+	buf := make([]byte, 64)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return
+		}
+		switch s := string(buf[:n]); s {
+		case "\033[A", "\033OA":
+			keys <- "up"
+		case "\033[B", "\033OB":
+			keys <- "down"
+		default:
+			for _, r := range s {
+				keys <- string(r)
+			}
+		}
+	}
+}
+
+// handleTUIKey applies a key press and returns false when the user quits.
+func handleTUIKey(state *tuiState, key string) bool {
+	state.mu.Lock()
+	prompting := state.prompt != ""
+	state.mu.Unlock()
+	if prompting {
+		handlePromptKey(state, key)
+		return true
+	}
+
+	pairs := commandPairs(nil)
+	state.mu.Lock()
+	if state.selected >= len(pairs) {
+		state.selected = max(len(pairs)-1, 0)
+	}
+	var pair string
+	if len(pairs) > 0 {
+		pair = pairs[state.selected]
+	}
+	state.mu.Unlock()
+
+	switch key {
+	case "q", "\003": // Ctrl-C is not a signal in raw mode
+		return false
+	case "up", "k":
+		state.mu.Lock()
+		state.selected = max(state.selected-1, 0)
+		state.mu.Unlock()
+	case "down", "j":
+		state.mu.Lock()
+		state.selected = min(state.selected+1, max(len(pairs)-1, 0))
+		state.mu.Unlock()
+	case "p":
+		if pair != "" {
+			togglePin(state, pair)
+		}
+	case "a":
+		if pair != "" {
+			state.mu.Lock()
+			state.prompt = "Alert for " + pair + " (above|below TARGET): "
+			state.promptPair = pair
+			state.input = nil
+			state.mu.Unlock()
+		}
+	case "x":
+		if pair != "" {
+			acknowledgeAlerts(state, pair)
+		}
+	case "r":
+		select {
+		case updateChan <- struct{}{}:
+		default:
+		}
+		setTUIStatus(state, "Refreshing prices…")
+	}
+	return true
+}
+
+// handlePromptKey edits the prompt line; Enter submits it, Esc cancels it.
+func handlePromptKey(state *tuiState, key string) {
+	state.mu.Lock()
+	switch key {
+	case "\033", "\003":
+		state.prompt = ""
+		state.status = "Cancelled."
+	case "\177", "\b":
+		if len(state.input) > 0 {
+			state.input = state.input[:len(state.input)-1]
+		}
+	case "\r", "\n":
+		pair, input := state.promptPair, string(state.input)
+		state.prompt = ""
+		state.mu.Unlock()
+		addTUIAlert(state, pair, input)
+		return
+	default:
+		if r := []rune(key); len(r) == 1 && r[0] >= ' ' {
+			state.input = append(state.input, r[0])
+		}
+	}
+	state.mu.Unlock()
+}
+
+func setTUIStatus(state *tuiState, status string) {
+	state.mu.Lock()
+	state.status = status
+	state.mu.Unlock()
+}
+
+// togglePin pins pair, or unpins it when it is already pinned, like the
+// tray's "Pin" menu item.
+func togglePin(state *tuiState, pair string) {
+	pinned := pair
+	if isPinned(pair) {
+		pinned = ""
+	}
+	switch err := setPinnedPair(pinned); {
+	case err != nil:
+		log.Printf("Error saving config after pin/unpin: %v", err)
+		setTUIStatus(state, fmt.Sprintf("Could not save the pin: %v", err))
+	case pinned == "":
+		setTUIStatus(state, "Unpinned "+pair+".")
+	default:
+		setTUIStatus(state, "Pinned "+pair+".")
+	}
+}
+
+// addTUIAlert parses "above|below TARGET" and adds the alert for pair.
+func addTUIAlert(state *tuiState, pair, input string) {
+	fields := strings.Fields(input)
+	if len(fields) != 2 || (fields[0] != "above" && fields[0] != "below") {
+		setTUIStatus(state, "Expected \"above TARGET\" or \"below TARGET\".")
+		return
+	}
+	target, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil || !validTarget(target) {
+		setTUIStatus(state, fmt.Sprintf("Target %q must be a number greater than zero.", fields[1]))
+		return
+	}
+
+	alert := Alert{Pair: pair, Condition: fields[0], Target: target, Active: true}
+	if err := addAlert(alert); err != nil {
+		log.Printf("Error adding alert: %v", err)
+		setTUIStatus(state, fmt.Sprintf("Could not add the alert: %v", err))
+		return
+	}
+	loadAndSetConfig()
+	setTUIStatus(state, fmt.Sprintf("Added alert: %s %s %s.", pair, alert.Condition, formatPairPrice(pair, target)))
+}
+
+// acknowledgeAlerts deactivates the triggered alerts of pair so they stop
+// firing. Shared alerts cannot be saved and are only dismissed.
+func acknowledgeAlerts(state *tuiState, pair string) {
+	state.mu.Lock()
+	var acked []Alert
+	for alert := range state.triggered {
+		if alert.Pair == pair {
+			acked = append(acked, alert)
+		}
+	}
+	state.mu.Unlock()
+	if len(acked) == 0 {
+		setTUIStatus(state, "No triggered alerts for "+pair+".")
+		return
+	}
+
+	// Write a copy without holding the lock; the loaded config only changes
+	// once the file has
+	configMutex.RLock()
+	cfg := activeConfig
+	alerts := slices.Clone(cfg.Alerts)
+	configMutex.RUnlock()
+	for i, a := range alerts {
+		if slices.Contains(acked, a) {
+			alerts[i].Active = false
+		}
+	}
+	if err := saveAlertStates(alerts); err != nil {
+		log.Printf("Error saving alert states: %v", err)
+		setTUIStatus(state, fmt.Sprintf("Could not save the alerts: %v", err))
+		return
+	}
+	configMutex.Lock()
+	if activeConfig == cfg { // Not reloaded meanwhile
+		activeConfig.Alerts = alerts
+	}
+	configMutex.Unlock()
+
+	state.mu.Lock()
+	for _, alert := range acked {
+		delete(state.triggered, alert)
+	}
+	state.mu.Unlock()
+	setTUIStatus(state, fmt.Sprintf("Acknowledged %d alert(s) for %s.", len(acked), pair))
+}
+
+// --- Rendering ---
+
+// drawTUI redraws the whole screen: the pair table, the status line and as
+// much of the log as fits below.
+func drawTUI(state *tuiState) {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		width, height = 80, 24
+	}
+
+	configMutex.RLock()
+	pairs := append([]string(nil), activeConfig.Pairs...)
+	alerts := append([]Alert(nil), activeConfig.Alerts...)
+	pinned := activeConfig.PinnedPair
+	configMutex.RUnlock()
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	state.selected = min(state.selected, max(len(pairs)-1, 0))
+
+	lines := []string{
+		fmt.Sprintf("CriptoMenu %s  %s", CurrentVersion, time.Now().Format("15:04:05")),
+		"",
+		fmt.Sprintf("  %-12s %16s %9s  %-*s  %s", "PAIR", "PRICE", "24H", tuiSparklinePoints, "TREND", "ALERT"),
+	}
+	for i, pair := range pairs {
+		line := tuiPairLine(state, pair, pair == pinned, alerts)
+		if i == state.selected {
+			line = "\033[7m" + fitWidth(line, width) + "\033[0m"
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, "")
+	switch {
+	case state.prompt != "":
+		lines = append(lines, "\033[1m"+state.prompt+"\033[0m"+string(state.input)+"█")
+	case state.status != "":
+		lines = append(lines, state.status)
+	default:
+		lines = append(lines, "↑/↓ select · p pin/unpin · a add alert · x acknowledge alerts · r refresh · q quit")
+	}
+	lines = append(lines, "", "\033[1mLog\033[0m")
+
+	// Fill the rest of the screen with the latest log lines
+	if room := height - len(lines); room > 0 {
+		lines = append(lines, state.logPane.last(room)...)
+	}
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+
+	var b strings.Builder
+	b.WriteString("\033[H")
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		if !strings.Contains(line, "\033[") {
+			line = fitWidth(line, width)
+		}
+		b.WriteString(line)
+		b.WriteString("\033[K")
+	}
+	b.WriteString("\033[J")
+	os.Stdout.WriteString(b.String())
+}
+
+// tuiPairLine renders the table row of pair.
+func tuiPairLine(state *tuiState, pair string, pinned bool, alerts []Alert) string {
+	marker := "  "
+	if pinned {
+		marker = "📌"
+	}
+	price, change := "-", ""
+	entry, ok := getCachedPrice(pair)
+	if ok {
+		price = formatPairPrice(pair, entry.Price)
+		change = formatChange(entry.ChangePercent)
+		if isStale(entry) {
+			price += staleMarker
+		}
+	}
+	return fmt.Sprintf("%s%-12s %16s %9s  %-*s  %s", marker, pair, price, change,
+		tuiSparklinePoints, state.sparklines[pair], alertSummary(state, pair, entry, ok, alerts))
+}
+
+// alertSummary describes the alerts of pair: triggered ones first, else the
+// nearest active target and its distance from the price.
+func alertSummary(state *tuiState, pair string, entry PriceEntry, hasPrice bool, alerts []Alert) string {
+	var triggered []string
+	for alert, price := range state.triggered {
+		if alert.Pair == pair {
+			triggered = append(triggered, fmt.Sprintf("%s %s at %s", alert.Condition, formatPairPrice(pair, alert.Target), formatPairPrice(pair, price)))
+		}
+	}
+	if len(triggered) > 0 {
+		slices.Sort(triggered)
+		return "⚠ TRIGGERED " + strings.Join(triggered, ", ") + " (x to acknowledge)"
+	}
+
+	var nearest *Alert
+	for i, a := range alerts {
+		if a.Pair != pair || !a.Active {
+			continue
+		}
+		if nearest == nil || !hasPrice || math.Abs(a.Target-entry.Price) < math.Abs(nearest.Target-entry.Price) {
+			nearest = &alerts[i]
+		}
+	}
+	if nearest == nil {
+		return ""
+	}
+	summary := nearest.Condition + " " + formatPairPrice(pair, nearest.Target)
+	if hasPrice && entry.Price > 0 {
+		summary += " (" + formatChange((nearest.Target-entry.Price)/entry.Price*100) + ")"
+	}
+	return summary
+}
+
+// sparkline draws values as a line of block characters.
+func sparkline(values []float64) string {
+	if len(values) == 0 {
+		return ""
+	}
+	lo, hi := slices.Min(values), slices.Max(values)
+	out := make([]rune, len(values))
+	for i, v := range values {
+		level := len(sparkBlocks) / 2
+		if hi > lo {
+			level = int((v - lo) / (hi - lo) * float64(len(sparkBlocks)-1))
+		}
+		out[i] = sparkBlocks[level]
+	}
+	return string(out)
+}
+
+// fitWidth cuts s to width runes.
+func fitWidth(s string, width int) string {
+	if r := []rune(s); len(r) > width {
+		return string(r[:width])
+	}
+	return s
+}
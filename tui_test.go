@@ -0,0 +1,127 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSparkline(t *testing.T) {
+	tests := []struct {
+		values []float64
+		want   string
+	}{
+		{nil, ""},
+		{[]float64{1, 2, 3, 4, 5, 6, 7, 8}, "▁▂▃▄▅▆▇█"},
+		{[]float64{10, 0, 5}, "█▁▄"},
+		{[]float64{3, 3, 3}, "▅▅▅"}, // Flat: the middle block
+		{[]float64{42}, "▅"},
+	}
+	for _, tt := range tests {
+		if got := sparkline(tt.values); got != tt.want {
+			t.Errorf("sparkline(%v) = %q, want %q", tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestFitWidth(t *testing.T) {
+	tests := []struct {
+		s     string
+		width int
+		want  string
+	}{
+		{"BTCUSDC", 10, "BTCUSDC"},
+		{"BTCUSDC", 3, "BTC"},
+		{"📌BTC ▲", 4, "📌BTC"}, // Runes, not bytes
+		{"abc", 0, ""},
+	}
+	for _, tt := range tests {
+		if got := fitWidth(tt.s, tt.width); got != tt.want {
+			t.Errorf("fitWidth(%q, %d) = %q, want %q", tt.s, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestAlertSummary(t *testing.T) {
+	setTestSymbols(t)
+	setTestFormat(t, nil)
+	alerts := []Alert{
+		{Pair: "BTCUSDC", Condition: "above", Target: 100000, Active: true},
+		{Pair: "BTCUSDC", Condition: "below", Target: 90000, Active: true},
+		{Pair: "BTCUSDC", Condition: "below", Target: 94000, Active: false},
+		{Pair: "ETHUSDC", Condition: "above", Target: 4000, Active: true},
+	}
+	state := &tuiState{triggered: make(map[Alert]float64)}
+	entry := PriceEntry{Price: 92000}
+
+	if got, want := alertSummary(state, "BTCUSDC", entry, true, alerts), "below $90,000.00 (-2.17%)"; got != want {
+		t.Errorf("nearest alert = %q, want %q", got, want)
+	}
+	if got, want := alertSummary(state, "BTCUSDC", PriceEntry{}, false, alerts), "below $90,000.00"; got != want {
+		t.Errorf("without a price = %q, want %q", got, want)
+	}
+	if got := alertSummary(state, "SOLUSDC", entry, true, alerts); got != "" {
+		t.Errorf("pair without alerts = %q", got)
+	}
+
+	state.triggered[alerts[1]] = 89950.5
+	state.triggered[alerts[0]] = 100010
+	want := "⚠ TRIGGERED above $100,000.00 at $100,010.00, below $90,000.00 at $89,950.50 (x to acknowledge)"
+	if got := alertSummary(state, "BTCUSDC", entry, true, alerts); got != want {
+		t.Errorf("triggered = %q, want %q", got, want)
+	}
+}
+
+func TestAcknowledgeAlerts(t *testing.T) {
+	const doc = `version = 2
+pairs = ["BTCUSDC"]
+
+[[alerts]]
+pair = "BTCUSDC"
+target = 100000.0
+condition = "above"
+active = true
+`
+	dir := writeTestConfigFiles(t, map[string]string{"config.toml": doc})
+	path := filepath.Join(dir, "config.toml")
+	setTestConfigFile(t, path)
+	cfg, _, _, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	configMutex.Lock()
+	old := activeConfig
+	activeConfig = cfg
+	configMutex.Unlock()
+	t.Cleanup(func() {
+		configMutex.Lock()
+		activeConfig = old
+		configMutex.Unlock()
+	})
+
+	state := &tuiState{triggered: map[Alert]float64{cfg.Alerts[0]: 100010}}
+	acknowledgeAlerts(state, "BTCUSDC")
+	if len(state.triggered) != 0 || !strings.HasPrefix(state.status, "Acknowledged 1 alert(s)") {
+		t.Errorf("triggered %v, status %q", state.triggered, state.status)
+	}
+	if data, _ := os.ReadFile(path); string(data) != strings.Replace(doc, "active = true", "active = false", 1) {
+		t.Errorf("config after acknowledging:\n%s", data)
+	}
+	if cfg.Alerts[0].Active {
+		t.Error("the loaded alert is still active")
+	}
+
+	// A failed write keeps the alert triggered
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	os.Mkdir(path, 0755)
+	alert := Alert{Pair: "BTCUSDC", Condition: "above", Target: 100000, Active: true}
+	cfg.Alerts[0] = alert
+	state.triggered[alert] = 100010
+	acknowledgeAlerts(state, "BTCUSDC")
+	if len(state.triggered) != 1 || !cfg.Alerts[0].Active {
+		t.Errorf("after a failed write: triggered %v, alert %+v, status %q", state.triggered, cfg.Alerts[0], state.status)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"runtime"
 	"strings"
 
+	"github.com/gen2brain/beeep"
 	"github.com/getlantern/systray"
 )
 
@@ -15,10 +16,15 @@ var (
 	mPairs        *systray.MenuItem
 	mPin          *systray.MenuItem // New Pinned Item
 	pairMenuItems []*systray.MenuItem
+
+	// Set when running in the menu bar; headless commands leave it false so
+	// no dialogs are shown
+	trayRunning bool
 )
 
 func onReady() {
 	log.Println("onReady started.")
+	trayRunning = true
 	systray.SetIcon(getIcon())
 	systray.SetTitle("Loading...")
 	systray.SetTooltip("CriptoMenu")
@@ -26,14 +32,16 @@ func onReady() {
 	// Initialize config
 	loadAndSetConfig()
 
-	// Set initial monitored pair
-	configMutex.RLock()
-	if len(activeConfig.Pairs) > 0 {
-		setPair(activeConfig.Pairs[0])
-	} else {
-		setPair("BTCUSDC") // Fallback
+	// Warm start: restore last known prices so the title is not empty while offline
+	if err := loadPriceCache(); err != nil {
+		log.Printf("Error restoring price cache: %v", err)
 	}
-	configMutex.RUnlock()
+
+	// Set initial monitored pair
+	pair := initialPair()
+	setPair(pair)
+	refreshTitle(pair)
+	refreshIcon(pair)
 
 	// "Monitored Pairs" Parent Menu
 	mPairs = systray.AddMenuItem("Monitored Pairs", "Select a pair to display")
@@ -41,58 +49,95 @@ func onReady() {
 	// Initialize the submenus based on current config
 	updatePairsMenu()
 
+	// "Manage Pairs" menu to add, remove and reorder pairs without editing the file
+	addManagePairsMenu()
+
+	// "Profile" menu to switch between the profiles of the config, if any
+	addProfilesMenu()
+
+	// Status of the shared watchlist, if one is configured
+	addWatchlistMenu()
+
+	// "Pair Warnings" menu item, only visible when configured pairs have problems
+	addPairWarningsMenu()
+
+	// "Config problems" menu item, only visible when the config has errors or warnings
+	mConfigProblems = systray.AddMenuItem("Config problems", "Errors and warnings in the config file")
+	updateConfigProblemsMenu()
+
 	// "Pin/Unpin" menu item
 	mPin = systray.AddMenuItem("Pin Current Pair", "Fix the current pair to the menu bar")
 	go func() {
 		for range mPin.ClickedCh {
-			configMutex.Lock()
 			current := getPair()
-			
-			if activeConfig.PinnedPair == current {
-				// Unpin
-				activeConfig.PinnedPair = ""
-				mPin.SetTitle("Pin " + current)
-			} else {
-				// Pin
-				activeConfig.PinnedPair = current
-				mPin.SetTitle("Unpin " + current)
+			pair := current
+			if isPinned(current) {
+				pair = "" // Unpin
 			}
-			
-			// Save config
-			err := saveConfigInternal(activeConfig)
-			configMutex.Unlock()
-			
-			if err != nil {
+			if err := setPinnedPair(pair); err != nil {
 				log.Printf("Error saving config after pin/unpin: %v", err)
-			} else {
-				// Force UI update
-				updatePairsMenu() // Refresh checkmarks if implemented, or just state
 			}
 		}
 	}()
 
 	// "Market Chart" menu item
-	mMarketChart := systray.AddMenuItem("Market Chart", "Open Binance chart for current pair")
+	mMarketChart := systray.AddMenuItem("Market Chart", "Open a chart of the current pair in the browser")
 	go func() {
 		for range mMarketChart.ClickedCh {
 			pair := getPair()
 			if pair == "" {
 				continue
 			}
-			// Simple heuristic to split pair for URL: BTCUSDC -> BTC_USDC
-			// Common quote currencies
-			quotes := []string{"USDT", "USDC", "BUSD", "EUR", "BTC", "ETH", "BNB"}
-			formattedPair := pair
-			for _, q := range quotes {
-				if strings.HasSuffix(pair, q) && len(pair) > len(q) {
-					formattedPair = pair[:len(pair)-len(q)] + "_" + q
-					break
-				}
-			}
 
-			url := fmt.Sprintf("https://www.binance.com/it/trade/%s?type=spot", formattedPair)
+			url, err := marketChartURL(pair)
+			if err != nil {
+				log.Printf("Error building chart URL for %s: %v", pair, err)
+				showErrorAlert("Chart Error", fmt.Sprintf("Could not open chart for %s.\nError: %v", pair, err))
+				continue
+			}
 			log.Printf("Opening chart: %s", url)
-			_ = exec.Command("open", url).Run()
+			if err := openURL(url); err != nil {
+				log.Printf("Error opening chart: %v", err)
+			}
+		}
+	}()
+
+	// "Local Chart" menu item: charts rendered from our own history, with alert levels
+	mLocalChart := systray.AddMenuItem("Local Chart", "Chart of the current pair with your alert levels")
+	mOpenChart := mLocalChart.AddSubMenuItem("Open Chart", "Render and open a chart of the current pair")
+	mSavePNG := mLocalChart.AddSubMenuItem("Save as PNG", "Save a chart of the current pair to Downloads")
+	mSaveSVG := mLocalChart.AddSubMenuItem("Save as SVG", "Save a chart of the current pair to Downloads")
+	go func() {
+		for {
+			var format, dir string
+			var err error
+			select {
+			case <-mOpenChart.ClickedCh:
+				format = "png"
+				dir, err = getChartTempDir()
+			case <-mSavePNG.ClickedCh:
+				format = "png"
+				dir, err = getChartSaveDir()
+			case <-mSaveSVG.ClickedCh:
+				format = "svg"
+				dir, err = getChartSaveDir()
+			}
+			if err != nil {
+				log.Printf("Error getting chart directory: %v", err)
+				continue
+			}
+
+			pair := getPair()
+			path, err := exportChart(pair, format, dir)
+			if err != nil {
+				log.Printf("Error rendering chart for %s: %v", pair, err)
+				showErrorAlert("Chart Error", fmt.Sprintf("Could not render chart for %s.\nError: %v", pair, err))
+				continue
+			}
+			log.Printf("Chart written to %s", path)
+			if err := openURL(path); err != nil {
+				log.Printf("Error opening chart: %v", err)
+			}
 		}
 	}()
 
@@ -106,17 +151,28 @@ func onReady() {
 
 	go func() {
 		for range mEditConfig.ClickedCh {
-			configPath, _ := getConfigFilePath()
-			log.Printf("Opening config file at %s...", configPath)
+			openConfigInEditor()
+		}
+	}()
 
-			cmd := exec.Command("open", "-t", configPath)
-			err := cmd.Run()
-			if err != nil {
-				log.Printf("Error opening config file: %v", err)
-			}
+	// "View Effective Config": the merged result of includes, profile and
+	// environment overrides
+	mEffectiveConfig := systray.AddMenuItem("View Effective Config", "Show the merged settings of all config layers")
+	go func() {
+		for range mEffectiveConfig.ClickedCh {
+			openEffectiveConfig()
 		}
 	}()
 
+	// "Config Location" diagnostics: resolution order and the chosen file
+	addConfigLocationMenu()
+
+	// Result of the last config reload, shown once the file has changed
+	addConfigStatusMenu()
+
+	// "Restore previous config" menu item, listing the automatic backups
+	addRestoreConfigMenu()
+
 	// "Check for Update..." menu item
 	mCheckUpdate := systray.AddMenuItem("Check for Update...", "Check for new releases on GitHub")
 	go func() {
@@ -129,7 +185,7 @@ func onReady() {
 	mAbout := systray.AddMenuItem("About", "Open GitHub project page")
 	go func() {
 		for range mAbout.ClickedCh {
-			_ = exec.Command("open", "https://github.com/antedoro/CriptoMenu-golang").Run()
+			_ = openURL("https://github.com/antedoro/CriptoMenu-golang")
 		}
 	}()
 
@@ -146,22 +202,76 @@ func onReady() {
 	// Start file watcher for config changes
 	go watchConfig()
 
+	// Show fetched prices and triggered alerts in the menu bar
+	addPriceListener(func(pair string, entry PriceEntry) {
+		// Update UI ONLY if this is the currently selected pair
+		if pair == getPair() {
+			refreshTitle(pair)
+			refreshIcon(pair)
+		}
+	})
+	addAlertListener(showAlertNotification)
+
 	// Start price fetching
 	go fetchPrices()
 
 	// Start Pair Rotation (Carousel)
 	go rotatePairs()
 
+	// Load symbol metadata (tick sizes, base/quote assets) used to format prices
+	go watchExchangeInfo()
+	go watchWatchlist()
+
+	// Backfill price history for the configured pairs
+	go runBackfill()
+
+	// Periodically persist the price cache for the next warm start
+	go persistPriceCache()
+
+	// Local HTTP API, when enabled in [api]
+	startAPI()
+
+	// MQTT publishing, when a broker is set in [mqtt]
+	startMQTT()
+
+	// Control socket for "criptomenu ctl" and scripts
+	startControlServer()
+
+	// --select and --pin from the command line
+	applyStartupCommands()
+
 	log.Println("onReady finished.")
 }
 
 func onExit() {
+	stopControlServer()
+	stopMQTT()
+	if err := savePriceCache(); err != nil {
+		log.Printf("Error saving price cache: %v", err)
+	}
 	log.Println("Application exiting.")
 }
 
+// initialPair returns the pair displayed at startup: the pinned pair, else
+// the first configured one.
+func initialPair() string {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+	if activeConfig.PinnedPair != "" {
+		return activeConfig.PinnedPair
+	} else if len(activeConfig.Pairs) > 0 {
+		return activeConfig.Pairs[0]
+	}
+	return "BTCUSDC" // Fallback
+}
+
 func updatePairsMenu() {
+	if mPairs == nil {
+		return
+	}
 	configMutex.RLock()
 	pairs := activeConfig.Pairs
+	own := len(pairs) - activeConfig.SharedPairs
 	configMutex.RUnlock()
 
 	// Ensure we have enough menu items
@@ -182,35 +292,133 @@ func updatePairsMenu() {
 	for i, item := range pairMenuItems {
 		if i < len(pairs) {
 			item.SetTitle(pairs[i])
-			item.SetTooltip("Display " + pairs[i])
+			tooltip := "Display " + displayPair(pairs[i])
+			if i >= own {
+				tooltip += " (from the shared watchlist)"
+			}
+			item.SetTooltip(tooltip)
 			item.Show()
 		} else {
 			item.Hide()
 		}
 	}
+
+	updateManagePairsMenu()
 }
 
 func handlePairClick(index int) {
 	configMutex.RLock()
-	defer configMutex.RUnlock()
-	
-	if index >= 0 && index < len(activeConfig.Pairs) {
-		selectedPair := activeConfig.Pairs[index]
-		log.Printf("Selected pair: %s", selectedPair)
-		setPair(selectedPair)
-		systray.SetTitle(fmt.Sprintf("%s: ...", selectedPair))
-        
-        // Trigger immediate update
-        select {
-        case updateChan <- struct{}{}:
-        default:
-            // Channel full, update already pending
-        }
+	pairs := activeConfig.Pairs
+	configMutex.RUnlock()
+
+	if index >= 0 && index < len(pairs) {
+		selectPair(pairs[index])
+	}
+}
+
+// selectPair displays pair in the menu bar and fetches its price now.
+func selectPair(pair string) {
+	log.Printf("Selected pair: %s", pair)
+	setPair(pair)
+	if trayRunning {
+		refreshTitle(pair)
+		refreshIcon(pair)
+	}
+
+	// Trigger immediate update
+	select {
+	case updateChan <- struct{}{}:
+	default:
+		// Channel full, update already pending
+	}
+}
+
+// setPinnedPair fixes pair to the menu bar, or unpins when pair is empty,
+// and saves it to the config file.
+func setPinnedPair(pair string) error {
+	// Write first, without holding the lock: the file may be slow to write
+	// and the loaded config should not claim a pin that was not saved
+	if err := savePinnedPair(pair); err != nil {
+		return err
+	}
+	configMutex.Lock()
+	activeConfig.PinnedPair = pair
+	configMutex.Unlock()
+
+	if pair != "" && pair != getPair() {
+		selectPair(pair)
+	} else {
+		setPair(getPair()) // Update the Pin menu title
+	}
+	updatePairsMenu()
+	return nil
+}
+
+// openConfigInEditor opens the config file with the default text editor.
+func openConfigInEditor() {
+	configPath, _ := getConfigFilePath()
+	openFileInEditor(configPath)
+}
+
+func openFileInEditor(path string) {
+	log.Printf("Opening config file at %s...", path)
+
+	var err error
+	if runtime.GOOS == "darwin" {
+		// -t: open with the default text editor
+		err = exec.Command("open", "-t", path).Run()
+	} else {
+		err = openURL(path)
+	}
+	if err != nil {
+		log.Printf("Error opening config file: %v", err)
+	}
+}
+
+// showAlertNotification notifies the user of a triggered alert: a dialog on
+// macOS, a desktop notification elsewhere.
+func showAlertNotification(alert Alert, price float64) {
+	msg := alertMessage(alert, price)
+
+	// Icon path is empty to use default or system icon
+	if runtime.GOOS == "darwin" {
+		// Use osascript display alert (modal) for better visibility
+		// Run in goroutine to not block price updates while waiting for user to dismiss
+		go func(message string) {
+			// Escape double quotes in the message to prevent script errors
+			safeMsg := strings.ReplaceAll(message, "\"", "\\\"")
+
+			iconPath := "/Users/antedoro/Desktop/CriptoMenu-golang/icon.png"
+			script := fmt.Sprintf(`
+set iconPath to POSIX file "%s"
+try
+	beep
+	display dialog "%s" with title "CriptoMenu Alert" buttons {"OK"} default button "OK" with icon iconPath
+on error
+	beep
+	display alert "CriptoMenu Alert" message "%s"
+end try`, iconPath, safeMsg, safeMsg)
+
+			err := exec.Command("osascript", "-e", script).Run()
+			recordNotification("osascript", err)
+			if err != nil {
+				log.Printf("Error sending macOS alert: %v", err)
+			}
+		}(msg)
+	} else {
+		err := beeep.Notify("CriptoMenu Alert", msg, "")
+		recordNotification("notify", err)
+		if err != nil {
+			log.Printf("Error sending notification: %v", err)
+		}
 	}
 }
 
 // Helper to show error alerts
 func showErrorAlert(title, message string) {
+	if !trayRunning {
+		return // Headless: the problem has been logged
+	}
 	if runtime.GOOS == "darwin" {
 		go func() {
 			safeMsg := strings.ReplaceAll(message, "\"", "\\\"")
@@ -105,13 +105,13 @@ func checkForUpdates() {
 				script := fmt.Sprintf(`display dialog "%s" with title "Update Available" buttons {"Download", "Cancel"} default button "Download"`, safeMsg)
 				out, err := exec.Command("osascript", "-e", script).Output()
 				if err == nil && strings.Contains(string(out), "button returned:Download") {
-					_ = exec.Command("open", release.HTMLURL).Run()
+					_ = openURL(release.HTMLURL)
 				}
 			}()
 		} else {
 			// Fallback for non-macOS (though this is mac-centric app)
 			beeep.Notify("Update Available", msg, "")
-			_ = exec.Command("open", release.HTMLURL).Run()
+			_ = openURL(release.HTMLURL)
 		}
 	} else {
 		msg := fmt.Sprintf("You are using the latest version (%s).", CurrentVersion)
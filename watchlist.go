@@ -0,0 +1,453 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getlantern/systray"
+	"github.com/pelletier/go-toml/v2"
+)
+
+const (
+	// Default refresh interval of the shared watchlist
+	defaultWatchlistInterval = 15 * time.Minute
+
+	// Limit for watchlist downloads; a watchlist is a few kilobytes
+	maxWatchlistSize = 1 << 20
+)
+
+// Files looked up in a watchlist directory (e.g. a git checkout), in order
+var watchlistFileNames = []string{"watchlist.toml", "watchlist.json"}
+
+// WatchlistConfig is the [watchlist] table: a shared list of pairs and alerts
+// merged read-only into the config
+type WatchlistConfig struct {
+	URL      string `toml:"url,omitempty"`      // http(s) URL of a TOML or JSON watchlist
+	Path     string `toml:"path,omitempty"`     // Local file, or directory (e.g. a git checkout) with watchlist.toml or watchlist.json
+	Interval string `toml:"interval,omitempty"` // How often to fetch it (default "15m")
+	GitPull  bool   `toml:"git_pull,omitempty"` // Run "git pull --ff-only" in the path's checkout before reading
+}
+
+// sharedWatchlist is the content of a watchlist file
+type sharedWatchlist struct {
+	Pairs  []string `toml:"pairs" json:"pairs"`
+	Alerts []Alert  `toml:"alerts" json:"alerts"`
+}
+
+// watchlistCache is the last fetched watchlist, persisted across restarts
+type watchlistCache struct {
+	Source    string          `json:"source"` // URL or path it was read from
+	ETag      string          `json:"etag,omitempty"`
+	FetchedAt time.Time       `json:"fetched_at"`
+	Watchlist sharedWatchlist `json:"watchlist"`
+}
+
+var (
+	// Last fetched watchlist and the error of the last attempt
+	sharedList         *watchlistCache
+	sharedListErr      error
+	sharedListMutex    sync.Mutex
+	sharedListLoadOnce sync.Once
+
+	// Signals watchWatchlist to fetch now, e.g. after the config changed
+	watchlistChan = make(chan struct{}, 1)
+
+	// "Shared watchlist" status menu item
+	mWatchlist *systray.MenuItem
+)
+
+// --- Merging ---
+
+// source returns where the watchlist is read from, "" when none is set.
+func (w *WatchlistConfig) source() string {
+	if w == nil {
+		return ""
+	}
+	if w.URL != "" {
+		return w.URL
+	}
+	return w.Path
+}
+
+// interval returns the refresh interval, falling back to the default.
+func (w *WatchlistConfig) interval() time.Duration {
+	if w != nil && w.Interval != "" {
+		if d, err := time.ParseDuration(w.Interval); err == nil && d > 0 {
+			return d
+		}
+	}
+	return defaultWatchlistInterval
+}
+
+// applyWatchlist appends the pairs and alerts of the last fetched watchlist
+// to cfg. Pairs already in cfg are not repeated, and alerts whose id is used
+// by one of cfg's own alerts are skipped, so personal entries win. The added
+// entries are marked so they are never written to the config file.
+func applyWatchlist(cfg *Config) {
+	sharedListLoadOnce.Do(func() {
+		if err := loadWatchlistCache(); err != nil {
+			log.Printf("Error loading watchlist cache: %v", err)
+		}
+	})
+
+	src := cfg.Watchlist.source()
+	sharedListMutex.Lock()
+	list := sharedList
+	sharedListMutex.Unlock()
+	if src == "" || list == nil || list.Source != src {
+		return
+	}
+
+	for _, p := range list.Watchlist.Pairs {
+		if !slices.Contains(cfg.Pairs, p) {
+			cfg.Pairs = append(cfg.Pairs, p)
+			cfg.SharedPairs++
+		}
+	}
+	for _, a := range list.Watchlist.Alerts {
+		if a.ID != "" && slices.ContainsFunc(cfg.Alerts, func(own Alert) bool { return own.ID == a.ID }) {
+			continue
+		}
+		a.Shared = true
+		cfg.Alerts = append(cfg.Alerts, a)
+	}
+}
+
+// ownPairs returns the pairs of cfg that come from the config file, without
+// those added by the watchlist.
+func ownPairs(cfg *Config) []string {
+	return append([]string(nil), cfg.Pairs[:len(cfg.Pairs)-cfg.SharedPairs]...)
+}
+
+// --- Fetching ---
+
+// watchWatchlist fetches the configured watchlist periodically and whenever
+// triggerWatchlistRefresh is called, reloading the config when it changed.
+func watchWatchlist() {
+	for {
+		configMutex.RLock()
+		var wc *WatchlistConfig
+		if activeConfig != nil && activeConfig.Watchlist != nil {
+			copied := *activeConfig.Watchlist
+			wc = &copied
+		}
+		configMutex.RUnlock()
+
+		if wc.source() != "" {
+			refreshWatchlist(wc)
+		}
+
+		timer := time.NewTimer(wc.interval())
+		select {
+		case <-timer.C:
+		case <-watchlistChan:
+			timer.Stop()
+		}
+	}
+}
+
+// triggerWatchlistRefresh requests a watchlist fetch without blocking.
+func triggerWatchlistRefresh() {
+	select {
+	case watchlistChan <- struct{}{}:
+	default:
+		// Fetch already pending
+	}
+}
+
+// refreshWatchlist fetches the watchlist and applies it when it changed.
+func refreshWatchlist(wc *WatchlistConfig) {
+	sharedListMutex.Lock()
+	cached := sharedList
+	sharedListMutex.Unlock()
+
+	list, err := fetchWatchlist(wc, cached)
+
+	sharedListMutex.Lock()
+	sharedListErr = err
+	changed := false
+	if err == nil {
+		changed = cached == nil || cached.Source != list.Source || !sameWatchlist(cached.Watchlist, list.Watchlist)
+		sharedList = list
+	}
+	sharedListMutex.Unlock()
+
+	if err != nil {
+		log.Printf("Error fetching watchlist from %s: %v", wc.source(), err)
+	} else if err := saveWatchlistCache(list); err != nil {
+		log.Printf("Error saving watchlist cache: %v", err)
+	}
+	if changed {
+		log.Printf("Watchlist from %s changed: %d pairs, %d alerts", list.Source, len(list.Watchlist.Pairs), len(list.Watchlist.Alerts))
+		loadAndSetConfig()
+		updatePairsMenu()
+		triggerBackfill() // Fetch history for newly shared pairs
+	}
+	updateWatchlistMenu()
+}
+
+// fetchWatchlist reads the watchlist from its URL or path. For URLs the
+// cached ETag is sent, and a 304 Not Modified answer keeps the cached list.
+func fetchWatchlist(wc *WatchlistConfig, cached *watchlistCache) (*watchlistCache, error) {
+	src := wc.source()
+	if wc.URL == "" {
+		data, name, err := readWatchlistPath(wc)
+		if err != nil {
+			return nil, err
+		}
+		list, err := parseWatchlist(data, name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		return &watchlistCache{Source: src, FetchedAt: time.Now(), Watchlist: list}, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, wc.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/toml, application/json;q=0.9, */*;q=0.5")
+	if cached != nil && cached.Source == src && cached.ETag != "" {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		if cached == nil || cached.Source != src {
+			return nil, fmt.Errorf("server answered 304 Not Modified without a cached watchlist")
+		}
+		updated := *cached
+		updated.FetchedAt = time.Now()
+		return &updated, nil
+	case http.StatusOK:
+	default:
+		return nil, fmt.Errorf("server returned %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxWatchlistSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxWatchlistSize {
+		return nil, fmt.Errorf("watchlist is larger than %d bytes", maxWatchlistSize)
+	}
+	list, err := parseWatchlist(data, resp.Header.Get("Content-Type")+" "+req.URL.Path)
+	if err != nil {
+		return nil, err
+	}
+	return &watchlistCache{Source: src, ETag: resp.Header.Get("ETag"), FetchedAt: time.Now(), Watchlist: list}, nil
+}
+
+// readWatchlistPath reads the watchlist at wc.Path: the file itself, or the
+// first of watchlistFileNames in a directory, after an optional git pull.
+func readWatchlistPath(wc *WatchlistConfig) ([]byte, string, error) {
+	path := resolveIncludePath(configFileForIncludes(), wc.Path)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, path, err
+	}
+
+	dir := path
+	if !info.IsDir() {
+		dir = filepath.Dir(path)
+	}
+	if wc.GitPull {
+		out, err := exec.Command("git", "-C", dir, "pull", "--ff-only", "--quiet").CombinedOutput()
+		if err != nil {
+			// Still use the checkout as it is, e.g. when offline
+			log.Printf("Error pulling watchlist checkout %s: %v: %s", dir, err, strings.TrimSpace(string(out)))
+		}
+	}
+
+	if info.IsDir() {
+		for _, name := range watchlistFileNames {
+			file := filepath.Join(path, name)
+			if data, err := os.ReadFile(file); err == nil {
+				return data, file, nil
+			}
+		}
+		return nil, path, fmt.Errorf("no %s in %s", strings.Join(watchlistFileNames, " or "), path)
+	}
+	data, err := os.ReadFile(path)
+	return data, path, err
+}
+
+// configFileForIncludes returns the config file path relative watchlist
+// paths are resolved against.
+func configFileForIncludes() string {
+	path, err := getConfigFilePath()
+	if err != nil {
+		return "."
+	}
+	return path
+}
+
+// parseWatchlist decodes a TOML or JSON watchlist. hint is a content type or
+// file name; without a JSON hint, documents starting with "{" are JSON.
+func parseWatchlist(data []byte, hint string) (sharedWatchlist, error) {
+	var list sharedWatchlist
+	var err error
+	if strings.Contains(strings.ToLower(hint), "json") || bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		err = json.Unmarshal(data, &list)
+	} else {
+		err = toml.Unmarshal(data, &list)
+	}
+	if err != nil {
+		return list, err
+	}
+
+	// Drop entries that cannot be monitored instead of failing the whole list
+	pairs := list.Pairs[:0]
+	for _, p := range list.Pairs {
+		p = strings.ToUpper(strings.TrimSpace(p))
+		if !symbolPattern.MatchString(p) {
+			log.Printf("Watchlist: ignoring invalid pair %q", p)
+			continue
+		}
+		if !slices.Contains(pairs, p) {
+			pairs = append(pairs, p)
+		}
+	}
+	list.Pairs = pairs
+
+	alerts := list.Alerts[:0]
+	for _, a := range list.Alerts {
+		a.Pair = strings.ToUpper(strings.TrimSpace(a.Pair))
+		if !symbolPattern.MatchString(a.Pair) || !validTarget(a.Target) || (a.Condition != "above" && a.Condition != "below") {
+			log.Printf("Watchlist: ignoring invalid alert %+v", a)
+			continue
+		}
+		alerts = append(alerts, a)
+	}
+	list.Alerts = alerts
+	return list, nil
+}
+
+func sameWatchlist(a, b sharedWatchlist) bool {
+	return slices.Equal(a.Pairs, b.Pairs) && slices.Equal(a.Alerts, b.Alerts)
+}
+
+// --- Cache ---
+
+func getWatchlistCachePath() (string, error) {
+	dir, err := getCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "watchlist.json"), nil
+}
+
+// loadWatchlistCache restores the watchlist saved by saveWatchlistCache, so
+// shared pairs are available before the first fetch (or when offline).
+func loadWatchlistCache() error {
+	path, err := getWatchlistCachePath()
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("could not read watchlist cache: %w", err)
+	}
+	var cached watchlistCache
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return fmt.Errorf("could not parse watchlist cache: %w", err)
+	}
+
+	sharedListMutex.Lock()
+	if sharedList == nil {
+		sharedList = &cached
+	}
+	sharedListMutex.Unlock()
+	return nil
+}
+
+func saveWatchlistCache(list *watchlistCache) error {
+	path, err := getWatchlistCachePath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// --- Status Menu ---
+
+// addWatchlistMenu adds the shared watchlist status item, hidden when no
+// watchlist is configured. Clicking it fetches the watchlist again.
+func addWatchlistMenu() {
+	mWatchlist = systray.AddMenuItem("", "")
+	mWatchlist.Hide()
+	go func() {
+		for range mWatchlist.ClickedCh {
+			triggerWatchlistRefresh()
+		}
+	}()
+	updateWatchlistMenu()
+}
+
+// updateWatchlistMenu shows the size and age of the shared watchlist, or the
+// last fetch error.
+func updateWatchlistMenu() {
+	if mWatchlist == nil {
+		return
+	}
+	configMutex.RLock()
+	src := ""
+	if activeConfig != nil {
+		src = activeConfig.Watchlist.source()
+	}
+	configMutex.RUnlock()
+	if src == "" {
+		mWatchlist.Hide()
+		return
+	}
+
+	sharedListMutex.Lock()
+	list, fetchErr := sharedList, sharedListErr
+	sharedListMutex.Unlock()
+
+	switch {
+	case list == nil || list.Source != src:
+		if fetchErr != nil {
+			mWatchlist.SetTitle("⚠ Shared watchlist unavailable")
+			mWatchlist.SetTooltip(fmt.Sprintf("%s: %v. Click to retry.", src, fetchErr))
+		} else {
+			mWatchlist.SetTitle("Shared watchlist: loading…")
+			mWatchlist.SetTooltip(src)
+		}
+	default:
+		title := fmt.Sprintf("Shared watchlist: %d pairs, %d alerts (%s)", len(list.Watchlist.Pairs), len(list.Watchlist.Alerts), list.FetchedAt.Format("15:04"))
+		tooltip := fmt.Sprintf("%s. Click to refresh.", src)
+		if fetchErr != nil {
+			title = "⚠ " + title
+			tooltip = fmt.Sprintf("%s. Last fetch failed: %v. Click to retry.", src, fetchErr)
+		}
+		mWatchlist.SetTitle(title)
+		mWatchlist.SetTooltip(tooltip)
+	}
+	mWatchlist.Show()
+}
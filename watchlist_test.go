@@ -0,0 +1,154 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+const testWatchlistTOML = `
+pairs = ["BTCUSDC", "solusdc", "not a pair", "BTCUSDC"]
+
+[[alerts]]
+id = "btc-100k"
+pair = "BTCUSDC"
+target = 100000.0
+condition = "above"
+active = true
+
+[[alerts]]
+pair = "ETHUSDC"
+target = -1.0
+condition = "below"
+active = true
+`
+
+// A local server stands in for the shared watchlist; the second fetch sends
+// the ETag and must keep the cached list on 304 Not Modified.
+func TestFetchWatchlistETag(t *testing.T) {
+	var full, notModified int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const etag = `"v1"`
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full++
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Content-Type", "application/toml")
+		w.Write([]byte(testWatchlistTOML))
+	}))
+	defer server.Close()
+
+	wc := &WatchlistConfig{URL: server.URL + "/watchlist.toml"}
+	first, err := fetchWatchlist(wc, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := first.Watchlist.Pairs; !slices.Equal(got, []string{"BTCUSDC", "SOLUSDC"}) {
+		t.Errorf("pairs = %v", got)
+	}
+	if len(first.Watchlist.Alerts) != 1 || first.Watchlist.Alerts[0].ID != "btc-100k" {
+		t.Errorf("alerts = %+v", first.Watchlist.Alerts)
+	}
+	if first.ETag != `"v1"` {
+		t.Errorf("etag = %q", first.ETag)
+	}
+
+	second, err := fetchWatchlist(wc, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if full != 1 || notModified != 1 {
+		t.Errorf("requests: %d full, %d not modified; want 1 and 1", full, notModified)
+	}
+	if !sameWatchlist(first.Watchlist, second.Watchlist) {
+		t.Errorf("304 changed the watchlist: %+v", second.Watchlist)
+	}
+}
+
+func TestFetchWatchlistJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"pairs": ["ETHUSDC"], "alerts": [{"pair": "ETHUSDC", "target": 2000, "condition": "below", "active": true}]}`))
+	}))
+	defer server.Close()
+
+	list, err := fetchWatchlist(&WatchlistConfig{URL: server.URL}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(list.Watchlist.Pairs, []string{"ETHUSDC"}) || len(list.Watchlist.Alerts) != 1 || list.Watchlist.Alerts[0].Target != 2000 {
+		t.Errorf("watchlist = %+v", list.Watchlist)
+	}
+}
+
+func TestFetchWatchlistErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	if _, err := fetchWatchlist(&WatchlistConfig{URL: server.URL}, nil); err == nil {
+		t.Error("expected an error for 404")
+	}
+}
+
+func TestFetchWatchlistPath(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "watchlist.toml"), []byte(testWatchlistTOML), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := fetchWatchlist(&WatchlistConfig{Path: dir}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list.Source != dir || len(list.Watchlist.Pairs) != 2 {
+		t.Errorf("watchlist = %+v", list)
+	}
+}
+
+func TestApplyWatchlist(t *testing.T) {
+	sharedListLoadOnce.Do(func() {}) // Do not read the user's cache
+	sharedListMutex.Lock()
+	sharedList = &watchlistCache{
+		Source: "https://example.com/watchlist.toml",
+		Watchlist: sharedWatchlist{
+			Pairs: []string{"BTCUSDC", "SOLUSDC"},
+			Alerts: []Alert{
+				{ID: "btc-100k", Pair: "BTCUSDC", Target: 100000, Condition: "above", Active: true},
+				{Pair: "SOLUSDC", Target: 100, Condition: "below", Active: true},
+			},
+		},
+	}
+	sharedListMutex.Unlock()
+	defer func() { sharedList = nil }()
+
+	cfg := &Config{
+		Pairs:     []string{"ETHUSDC", "BTCUSDC"},
+		Alerts:    []Alert{{ID: "btc-100k", Pair: "BTCUSDC", Target: 120000, Condition: "above", Active: true}},
+		Watchlist: &WatchlistConfig{URL: "https://example.com/watchlist.toml"},
+	}
+	applyWatchlist(cfg)
+
+	if !slices.Equal(cfg.Pairs, []string{"ETHUSDC", "BTCUSDC", "SOLUSDC"}) || cfg.SharedPairs != 1 {
+		t.Errorf("pairs = %v, shared = %d", cfg.Pairs, cfg.SharedPairs)
+	}
+	if own := ownPairs(cfg); !slices.Equal(own, []string{"ETHUSDC", "BTCUSDC"}) {
+		t.Errorf("own pairs = %v", own)
+	}
+	// The personal btc-100k alert wins over the shared one
+	if len(cfg.Alerts) != 2 || cfg.Alerts[0].Target != 120000 || cfg.Alerts[0].Shared || !cfg.Alerts[1].Shared {
+		t.Errorf("alerts = %+v", cfg.Alerts)
+	}
+
+	// A different source is not merged
+	other := &Config{Pairs: []string{"ETHUSDC"}, Watchlist: &WatchlistConfig{URL: "https://example.com/other.toml"}}
+	applyWatchlist(other)
+	if len(other.Pairs) != 1 || other.SharedPairs != 0 {
+		t.Errorf("pairs from another source = %v", other.Pairs)
+	}
+}